@@ -0,0 +1,79 @@
+// Copyright 2020 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+	"k8s.io/klog"
+
+	"github.com/antoninbas/antrea-k8s-to-ddlog/pkg/config"
+)
+
+// addClientFlags registers the flags for the connection to the Kubernetes API. The current values
+// of the fields are used as defaults.
+func addClientFlags(fs *flag.FlagSet, c *config.ClientConnection) {
+	fs.StringVar(&c.Kubeconfig, "kubeconfig", c.Kubeconfig, "Path to the kubeconfig file. If empty, the in-cluster configuration is used when running in a Pod, otherwise $KUBECONFIG or ~/.kube/config")
+	fs.StringVar(&c.Context, "context", c.Context, "Name of the kubeconfig context to use, the current context if empty")
+	fs.StringVar(&c.Master, "master", c.Master, "Address of the Kubernetes API server, overrides the value in the kubeconfig file")
+	fs.Float64Var(&c.QPS, "kube-api-qps", c.QPS, "Maximum QPS to the Kubernetes API server")
+	fs.IntVar(&c.Burst, "kube-api-burst", c.Burst, "Maximum burst for throttle to the Kubernetes API server")
+	fs.DurationVar(&c.ResyncPeriod.Duration, "resync-period", c.ResyncPeriod.Duration, "Period at which all the Pods, Namespaces and NetworkPolicies are re-processed, 0 to disable periodic resyncs")
+}
+
+// inCluster returns true if no kubeconfig option was provided and we are running in a Pod.
+func inCluster(c *config.ClientConnection) bool {
+	return c.Kubeconfig == "" && c.Context == "" && c.Master == "" && os.Getenv("KUBERNETES_SERVICE_HOST") != ""
+}
+
+func restConfig(c *config.ClientConnection) (*rest.Config, error) {
+	var restConfig *rest.Config
+	var err error
+	if inCluster(c) {
+		klog.Infof("Using in-cluster configuration")
+		if restConfig, err = rest.InClusterConfig(); err != nil {
+			return nil, fmt.Errorf("error when loading in-cluster configuration: %v", err)
+		}
+	} else {
+		loadingRules := clientcmd.NewDefaultClientConfigLoadingRules()
+		loadingRules.ExplicitPath = c.Kubeconfig
+		overrides := &clientcmd.ConfigOverrides{CurrentContext: c.Context}
+		overrides.ClusterInfo.Server = c.Master
+		restConfig, err = clientcmd.NewNonInteractiveDeferredLoadingClientConfig(loadingRules, overrides).ClientConfig()
+		if err != nil {
+			return nil, fmt.Errorf("error when loading kubeconfig: %v", err)
+		}
+	}
+	restConfig.QPS = float32(c.QPS)
+	restConfig.Burst = c.Burst
+	return restConfig, nil
+}
+
+func newClientset(c *config.ClientConnection) (kubernetes.Interface, error) {
+	restConfig, err := restConfig(c)
+	if err != nil {
+		return nil, err
+	}
+	clientset, err := kubernetes.NewForConfig(restConfig)
+	if err != nil {
+		return nil, fmt.Errorf("error when creating clientset: %v", err)
+	}
+	return clientset, nil
+}
@@ -0,0 +1,105 @@
+// Copyright 2020 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+
+	"github.com/antoninbas/antrea-k8s-to-ddlog/pkg/explain"
+)
+
+// getJSON sends a GET request to the API of a running instance and decodes the JSON response into
+// obj.
+func getJSON(server, path string, obj interface{}) error {
+	client := &http.Client{Timeout: requestTimeout}
+	resp, err := client.Get(strings.TrimSuffix(server, "/") + path)
+	if err != nil {
+		return fmt.Errorf("error when querying API: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("error when reading response: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("query failed with status %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	if err := json.Unmarshal(body, obj); err != nil {
+		return fmt.Errorf("error when decoding response: %v", err)
+	}
+	return nil
+}
+
+// printJSON writes obj to stdout as indented JSON.
+func printJSON(obj interface{}) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(obj)
+}
+
+// runExplain lists the AppliedToGroups, NetworkPolicies and AddressGroups which involve a Pod,
+// either by querying a running instance or by computing the outputs from manifests.
+func runExplain(args []string) error {
+	fs := newFlagSet("explain", "[flags] <namespace>/<name>")
+	server := fs.String("server", defaultServer, "Base URL of the API of the running instance")
+	output := fs.String("o", "text", "Output format, one of 'text' or 'json'")
+	var offline offlineFlags
+	addOfflineFlags(fs, &offline)
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("expected exactly one Pod")
+	}
+	if *output != "text" && *output != "json" {
+		return fmt.Errorf("invalid output format '%s', must be one of 'text' or 'json'", *output)
+	}
+	namespace, name, err := explain.ParsePodKey(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	var e *explain.PodExplanation
+	if len(offline.fileNames) > 0 {
+		outputView, podLister, err := loadOfflineView(&offline)
+		if err != nil {
+			return err
+		}
+		pod, err := podLister.Pods(namespace).Get(name)
+		if errors.IsNotFound(err) {
+			return fmt.Errorf("Pod %s/%s not found in manifests", namespace, name)
+		} else if err != nil {
+			return err
+		}
+		e = explain.ExplainPod(outputView, pod)
+	} else {
+		e = &explain.PodExplanation{}
+		if err := getJSON(*server, explain.PathPrefix+"pods/"+namespace+"/"+name, e); err != nil {
+			return err
+		}
+	}
+
+	if *output == "json" {
+		return printJSON(e)
+	}
+	e.Print(os.Stdout)
+	return nil
+}
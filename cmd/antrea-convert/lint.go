@@ -0,0 +1,106 @@
+// Copyright 2020 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	"github.com/antoninbas/antrea-k8s-to-ddlog/pkg/config"
+	"github.com/antoninbas/antrea-k8s-to-ddlog/pkg/lint"
+	"github.com/antoninbas/antrea-k8s-to-ddlog/pkg/manifest"
+	"github.com/antoninbas/antrea-k8s-to-ddlog/pkg/reference"
+)
+
+// listClusterObjects lists the Pods, Namespaces and NetworkPolicies in the cluster.
+func listClusterObjects(c *config.ClientConnection) (*manifest.Objects, error) {
+	clientset, err := newClientset(c)
+	if err != nil {
+		return nil, err
+	}
+	objects := &manifest.Objects{}
+	pods, err := clientset.CoreV1().Pods(metav1.NamespaceAll).List(metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("error when listing Pods: %v", err)
+	}
+	for i := range pods.Items {
+		objects.Pods = append(objects.Pods, &pods.Items[i])
+	}
+	namespaces, err := clientset.CoreV1().Namespaces().List(metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("error when listing Namespaces: %v", err)
+	}
+	for i := range namespaces.Items {
+		objects.Namespaces = append(objects.Namespaces, &namespaces.Items[i])
+	}
+	networkPolicies, err := clientset.NetworkingV1().NetworkPolicies(metav1.NamespaceAll).List(metav1.ListOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("error when listing NetworkPolicies: %v", err)
+	}
+	for i := range networkPolicies.Items {
+		objects.NetworkPolicies = append(objects.NetworkPolicies, &networkPolicies.Items[i])
+	}
+	return objects, nil
+}
+
+// runLint analyzes the NetworkPolicies defined in manifests, or in the cluster, and reports the
+// likely mistakes.
+func runLint(args []string) error {
+	fs := newFlagSet("lint", "[flags]")
+	output := fs.String("o", "json", "Output format, one of 'json' or 'text'")
+	var offline offlineFlags
+	addOfflineFlags(fs, &offline)
+	cfg := config.NewDefault()
+	c := &cfg.ClientConnection
+	fs.StringVar(&c.Kubeconfig, "kubeconfig", c.Kubeconfig, "Path to the kubeconfig file, used to list the objects from the cluster when -f is not provided")
+	fs.StringVar(&c.Context, "context", c.Context, "Name of the kubeconfig context to use, the current context if empty")
+	fs.StringVar(&c.Master, "master", c.Master, "Address of the Kubernetes API server, overrides the value in the kubeconfig file")
+	fs.Parse(args)
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return fmt.Errorf("unexpected arguments")
+	}
+	if *output != "text" && *output != "json" {
+		return fmt.Errorf("invalid output format '%s', must be one of 'json' or 'text'", *output)
+	}
+
+	var objects *manifest.Objects
+	var err error
+	if len(offline.fileNames) > 0 {
+		objects, err = manifest.LoadFiles(offline.fileNames, manifest.Options{NumNodes: offline.numNodes, PodCIDR: offline.podCIDR})
+	} else {
+		objects, err = listClusterObjects(c)
+	}
+	if err != nil {
+		return err
+	}
+	outputView, err := computeView(objects)
+	if err != nil {
+		return err
+	}
+	report := lint.Analyze(&reference.Inputs{
+		Pods:            objects.Pods,
+		Namespaces:      objects.Namespaces,
+		NetworkPolicies: objects.NetworkPolicies,
+	}, outputView)
+
+	if *output == "json" {
+		return report.WriteJSON(os.Stdout)
+	}
+	report.Print(os.Stdout)
+	return nil
+}
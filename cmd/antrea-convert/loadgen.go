@@ -0,0 +1,97 @@
+// Copyright 2020 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/antoninbas/antrea-k8s-to-ddlog/pkg/config"
+	"github.com/antoninbas/antrea-k8s-to-ddlog/pkg/loadgen"
+	"github.com/vmware/differential-datalog/go/pkg/ddlog"
+)
+
+// runLoadgen drives a synthetic cluster through the controller and reports throughput, commit
+// latency and memory usage.
+func runLoadgen(args []string) error {
+	fs := newFlagSet("loadgen", "[flags]")
+	namespaces := fs.Int("namespaces", 10, "Number of Namespaces")
+	pods := fs.Int("pods", 10, "Number of Pods per Namespace")
+	policies := fs.Int("policies", 10, "Number of NetworkPolicies, distributed among Namespaces")
+	nodes := fs.Int("nodes", 10, "Number of Nodes on which Pods are scheduled")
+	churn := fs.String("churn", "", "Comma-separated list of churn patterns applied after the cluster is populated, among 'rolling-update', 'label-flip' and 'namespace-deletion'")
+	churnOperations := fs.Int("churn-operations", 100, "Number of churn patterns applied")
+	seed := fs.Int64("seed", 1, "Seed of the random generator")
+	settleTime := fs.Duration("settle-time", time.Second, "How long to wait with no DDLog commit before considering that all changes have been processed")
+	timeout := fs.Duration("timeout", 5*time.Minute, "How long to wait for all changes to be processed before failing a run")
+	workers := fs.String("ddlog-workers", "1", "Comma-separated list of numbers of DDLog worker threads, or 'auto' for one worker per available CPU. With more than one value, a run is done for each value and the results are compared")
+	output := fs.String("o", "text", "Output format, one of 'text' or 'json'")
+	fs.Parse(args)
+
+	churnPatterns, err := loadgen.ParseChurnPatterns(*churn)
+	if err != nil {
+		return err
+	}
+	if *output != "text" && *output != "json" {
+		return fmt.Errorf("invalid output format '%s', must be one of 'text' or 'json'", *output)
+	}
+
+	var workerCounts []uint
+	for _, s := range strings.Split(*workers, ",") {
+		w, err := config.ParseWorkers(s)
+		if err != nil {
+			return err
+		}
+		workerCounts = append(workerCounts, config.ResolveWorkers(w))
+	}
+
+	ddlog.SetErrMsgPrinter(k8sLogger)
+	var results []*loadgen.Result
+	for _, w := range workerCounts {
+		result, err := loadgen.Run(loadgen.Config{
+			Namespaces:       *namespaces,
+			PodsPerNamespace: *pods,
+			Policies:         *policies,
+			Nodes:            *nodes,
+			Churn:            churnPatterns,
+			ChurnOperations:  *churnOperations,
+			Seed:             *seed,
+			Workers:          w,
+			SettleTime:       *settleTime,
+			Timeout:          *timeout,
+		})
+		if err != nil {
+			return fmt.Errorf("run with %d worker(s): %v", w, err)
+		}
+		results = append(results, result)
+	}
+	if *output == "json" {
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		if len(results) == 1 {
+			return encoder.Encode(results[0])
+		}
+		return encoder.Encode(results)
+	}
+	if len(results) == 1 {
+		results[0].Print(os.Stdout)
+	} else {
+		loadgen.PrintComparison(os.Stdout, results)
+	}
+	return nil
+}
@@ -16,103 +16,150 @@ package main
 
 import (
 	"flag"
+	"fmt"
+	"io"
 	"os"
-	"path/filepath"
-	"time"
+	"strings"
 
-	"github.com/antoninbas/antrea-k8s-to-ddlog/pkg/controller"
-	"github.com/antoninbas/antrea-k8s-to-ddlog/pkg/signals"
-	"github.com/vmware/differential-datalog/go/pkg/ddlog"
-
-	"k8s.io/client-go/informers"
-	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/tools/clientcmd"
 	"k8s.io/component-base/logs"
 	"k8s.io/klog"
 )
 
-func homeDir() string {
-	if h := os.Getenv("HOME"); h != "" {
-		return h
-	}
-	return os.Getenv("USERPROFILE") // windows
+// command is an antrea-convert subcommand.
+type command struct {
+	name    string
+	summary string
+	run     func(args []string) error
 }
 
-func k8sLogger(msg string) {
-	klog.Errorf(msg)
+// commands is initialized in init() because the commands refer to it to print their usage.
+var commands []command
+
+func init() {
+	commands = []command{
+		{"run", "Watch the cluster and compute the Antrea NetworkPolicy outputs continuously (default)", runController},
+		{"offline", "Compute the outputs for the objects defined in YAML manifests, without a cluster", runOffline},
+		{"replay", "Re-apply the DDLog commands recorded with 'run --record-commands'", runReplay},
+		{"snapshot", "Take a snapshot of the DDLog inputs of a running instance, or restore one", runSnapshot},
+		{"query", "Query the output API of a running instance", runQuery},
+		{"repl", "Apply objects and inspect the DDLog relations interactively, without a cluster", runREPL},
+		{"explain", "List the AppliedToGroups, NetworkPolicies and AddressGroups which involve a Pod", runExplain},
+		{"reachability", "Check whether the NetworkPolicies allow traffic between two Pods", runReachability},
+		{"lint", "Report likely mistakes in the NetworkPolicies defined in manifests or in the cluster", runLint},
+		{"loadgen", "Measure throughput and commit latency with a synthetic cluster and a fake clientset", runLoadgen},
+		{"rbac", "Print the RBAC manifest required to run in a cluster", runRBAC},
+		{"version", "Print version information", runVersion},
+	}
 }
 
-func main() {
-	logs.InitLogs()
-	defer logs.FlushLogs()
-
-	recordCommands := flag.String("record-commands", "", "Provide a file name where to record commands sent to DDLog")
-	dumpChanges := flag.String("dump-changes", "", "Provide a file name where to dump record changes")
+// defaultCommand is used when the first argument is not a command name, for compatibility with
+// previous versions which only supported the "run" command.
+const defaultCommand = "run"
 
-	var kubeconfig *string
-	if home := homeDir(); home != "" {
-		kubeconfig = flag.String("kubeconfig", filepath.Join(home, ".kube", "config"), "(optional) absolute path to the kubeconfig file")
-	} else {
-		kubeconfig = flag.String("kubeconfig", "", "absolute path to the kubeconfig file")
+func findCommand(name string) *command {
+	for i := range commands {
+		if commands[i].name == name {
+			return &commands[i]
+		}
 	}
-	flag.Parse()
+	return nil
+}
 
-	// use the current context in kubeconfig
-	config, err := clientcmd.BuildConfigFromFlags("", *kubeconfig)
-	if err != nil {
-		panic(err.Error())
+func usage(w io.Writer) {
+	fmt.Fprintf(w, "Usage: %s [command] [flags]\n\nCommands:\n", os.Args[0])
+	for _, cmd := range commands {
+		fmt.Fprintf(w, "  %-12s %s\n", cmd.name, cmd.summary)
 	}
+	fmt.Fprintf(w, "\nRun '%s <command> -h' for the flags of each command.\n", os.Args[0])
+}
 
-	// create the clientset
-	clientset, err := kubernetes.NewForConfig(config)
-	if err != nil {
-		panic(err.Error())
+// newFlagSet creates the FlagSet for a command. argsUsage describes the positional arguments. The
+// klog flags, which are registered on flag.CommandLine, are available to all commands.
+func newFlagSet(name, argsUsage string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	flag.CommandLine.VisitAll(func(f *flag.Flag) {
+		fs.Var(f.Value, f.Name, f.Usage)
+	})
+	fs.Usage = func() {
+		fmt.Fprintf(fs.Output(), "Usage: %s %s %s\n", os.Args[0], name, argsUsage)
+		if cmd := findCommand(strings.Fields(name)[0]); cmd != nil {
+			fmt.Fprintf(fs.Output(), "\n%s.\n", cmd.summary)
+		}
+		fmt.Fprintf(fs.Output(), "\nFlags:\n")
+		fs.PrintDefaults()
 	}
+	return fs
+}
 
-	ddlog.SetErrMsgPrinter(k8sLogger)
+// ignoredFlag is a flag.Value which accepts any value without applying it.
+type ignoredFlag struct {
+	flag.Value
+}
 
-	var outRecordHandler ddlog.OutRecordHandler
-	if *dumpChanges == "" {
-		outRecordHandler, _ = ddlog.NewOutRecordSink()
-	} else {
-		outRecordHandler, _ = ddlog.NewOutRecordDumper(*dumpChanges)
-	}
+func (f ignoredFlag) Set(value string) error {
+	return nil
+}
 
-	ddlogProgram, err := ddlog.NewProgram(1, outRecordHandler)
-	if err != nil {
-		klog.Fatalf("Error when creating DDLog program: %v", err)
-	}
-	defer func() {
-		klog.Infof("Stopping DDLog program")
-		if err := ddlogProgram.Stop(); err != nil {
-			klog.Errorf("Error when stopping DDLog program: %v", err)
-		}
-	}()
+// ignoredBoolFlag is an ignoredFlag for boolean flags, which can be set without a value.
+type ignoredBoolFlag struct {
+	ignoredFlag
+}
 
-	if *recordCommands != "" {
-		ddlogProgram.StartRecordingCommands(*recordCommands)
-	}
+func (f ignoredBoolFlag) IsBoolFlag() bool {
+	return true
+}
 
-	informerFactory := informers.NewSharedInformerFactory(clientset, time.Second*30)
-	podInformer := informerFactory.Core().V1().Pods()
-	namespaceInformer := informerFactory.Core().V1().Namespaces()
-	networkPolicyInformer := informerFactory.Networking().V1().NetworkPolicies()
+// ignoreGlobalFlags makes fs accept the global flags added by newFlagSet, e.g. the klog flags,
+// without applying them.
+func ignoreGlobalFlags(fs *flag.FlagSet) {
+	flag.CommandLine.VisitAll(func(f *flag.Flag) {
+		global := fs.Lookup(f.Name)
+		if b, ok := f.Value.(interface{ IsBoolFlag() bool }); ok && b.IsBoolFlag() {
+			global.Value = ignoredBoolFlag{ignoredFlag{f.Value}}
+		} else {
+			global.Value = ignoredFlag{f.Value}
+		}
+	})
+}
 
-	c := controller.NewController(
-		clientset,
-		podInformer,
-		namespaceInformer,
-		networkPolicyInformer,
-		ddlogProgram,
-	)
+func k8sLogger(msg string) {
+	klog.Errorf(msg)
+}
 
-	stopCh := signals.RegisterSignalHandlers()
+// stringSliceFlag is a flag.Value which can be set multiple times.
+type stringSliceFlag []string
 
-	informerFactory.Start(stopCh)
+func (s *stringSliceFlag) String() string {
+	return strings.Join(*s, ",")
+}
 
-	go c.Run(stopCh)
+func (s *stringSliceFlag) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
 
-	<-stopCh
+func main() {
+	logs.InitLogs()
+	defer logs.FlushLogs()
 
-	klog.Infof("Exiting")
+	name, args := defaultCommand, os.Args[1:]
+	if len(args) > 0 {
+		switch {
+		case args[0] == "help" || args[0] == "-h" || args[0] == "--help":
+			usage(os.Stdout)
+			return
+		case !strings.HasPrefix(args[0], "-"):
+			name, args = args[0], args[1:]
+		}
+	}
+	cmd := findCommand(name)
+	if cmd == nil {
+		fmt.Fprintf(os.Stderr, "Unknown command '%s'\n\n", name)
+		usage(os.Stderr)
+		logs.FlushLogs()
+		os.Exit(2)
+	}
+	if err := cmd.run(args); err != nil {
+		klog.Fatalf("Error in '%s' command: %v", name, err)
+	}
 }
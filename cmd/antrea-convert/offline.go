@@ -0,0 +1,132 @@
+// Copyright 2020 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/klog"
+
+	"github.com/antoninbas/antrea-k8s-to-ddlog/pkg/ddlogk8s"
+	"github.com/antoninbas/antrea-k8s-to-ddlog/pkg/manifest"
+	"github.com/antoninbas/antrea-k8s-to-ddlog/pkg/outhandler"
+	"github.com/antoninbas/antrea-k8s-to-ddlog/pkg/program"
+	"github.com/antoninbas/antrea-k8s-to-ddlog/pkg/view"
+	"github.com/vmware/differential-datalog/go/pkg/ddlog"
+)
+
+// runOffline computes the output relations for the objects defined in YAML manifests, in a single
+// DDlog transaction, and prints them.
+func runOffline(args []string) error {
+	fs := newFlagSet("offline", "[flags] [manifest file...]")
+	var fileNames stringSliceFlag
+	fs.Var(&fileNames, "f", "Manifest file with Pods, Deployments, Namespaces and NetworkPolicies ('-' for stdin), can be repeated")
+	numNodes := fs.Int("nodes", manifest.DefaultNumNodes, "Number of synthetic Nodes on which Pods with no Node name are scheduled")
+	podCIDR := fs.String("pod-cidr", manifest.DefaultPodCIDR, "CIDR from which IPs are allocated to Pods with no IP")
+	output := fs.String("o", "text", "Output format, one of 'text' or 'json'")
+	fs.Parse(args)
+	if len(fileNames) == 0 {
+		fileNames = fs.Args()
+	}
+	if len(fileNames) == 0 {
+		return fmt.Errorf("no manifest file provided")
+	}
+
+	objects, err := manifest.LoadFiles(fileNames, manifest.Options{NumNodes: *numNodes, PodCIDR: *podCIDR})
+	if err != nil {
+		return err
+	}
+
+	ddlog.SetErrMsgPrinter(k8sLogger)
+	collector := outhandler.NewCollector()
+	ddlogProgram, err := program.NewProgram(1, collector)
+	if err != nil {
+		return fmt.Errorf("error when creating DDLog program: %v", err)
+	}
+	defer func() {
+		if err := ddlogProgram.Stop(); err != nil {
+			klog.Errorf("Error when stopping DDLog program: %v", err)
+		}
+	}()
+
+	if err := ddlogProgram.ApplyUpdatesAsTransaction(objectsToCommands(objects)...); err != nil {
+		return fmt.Errorf("error when applying updates: %v", err)
+	}
+
+	return collector.Print(os.Stdout, *output)
+}
+
+// objectsToCommands converts the objects loaded from manifests to DDlog commands.
+func objectsToCommands(objects *manifest.Objects) []ddlog.Command {
+	return ddlogk8s.NewInsertOrUpdateCommands(objects.Namespaces, objects.Pods, objects.NetworkPolicies)
+}
+
+// offlineFlags are the flags used by the commands which can compute the outputs from manifests
+// instead of querying a running instance.
+type offlineFlags struct {
+	fileNames stringSliceFlag
+	numNodes  int
+	podCIDR   string
+}
+
+func addOfflineFlags(fs *flag.FlagSet, f *offlineFlags) {
+	fs.Var(&f.fileNames, "f", "Compute the outputs from this manifest file ('-' for stdin) instead of querying a running instance, can be repeated")
+	fs.IntVar(&f.numNodes, "nodes", manifest.DefaultNumNodes, "Number of synthetic Nodes on which Pods with no Node name are scheduled, with -f")
+	fs.StringVar(&f.podCIDR, "pod-cidr", manifest.DefaultPodCIDR, "CIDR from which IPs are allocated to Pods with no IP, with -f")
+}
+
+// loadOfflineView computes the outputs for the objects defined in the manifest files and returns
+// them in a view, along with a lister for the Pods.
+func loadOfflineView(f *offlineFlags) (*view.View, corelisters.PodLister, error) {
+	objects, err := manifest.LoadFiles(f.fileNames, manifest.Options{NumNodes: f.numNodes, PodCIDR: f.podCIDR})
+	if err != nil {
+		return nil, nil, err
+	}
+	outputView, err := computeView(objects)
+	if err != nil {
+		return nil, nil, err
+	}
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	for _, pod := range objects.Pods {
+		if err := indexer.Add(pod); err != nil {
+			return nil, nil, err
+		}
+	}
+	return outputView, corelisters.NewPodLister(indexer), nil
+}
+
+// computeView computes the outputs for the provided objects with a new DDlog program, in a single
+// transaction, and returns them in a view.
+func computeView(objects *manifest.Objects) (*view.View, error) {
+	ddlog.SetErrMsgPrinter(k8sLogger)
+	outputView := view.NewView()
+	ddlogProgram, err := program.NewProgram(1, outputView)
+	if err != nil {
+		return nil, fmt.Errorf("error when creating DDLog program: %v", err)
+	}
+	defer func() {
+		if err := ddlogProgram.Stop(); err != nil {
+			klog.Errorf("Error when stopping DDLog program: %v", err)
+		}
+	}()
+	if err := ddlogProgram.ApplyUpdatesAsTransaction(objectsToCommands(objects)...); err != nil {
+		return nil, fmt.Errorf("error when applying updates: %v", err)
+	}
+	return outputView, nil
+}
@@ -0,0 +1,95 @@
+// Copyright 2020 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+
+	networking "github.com/antoninbas/antrea-k8s-to-ddlog/pkg/apis/networking/v1beta1"
+)
+
+// queryResources are the resources of the controlplane API which can be queried.
+var queryResources = []string{"appliedtogroups", "addressgroups", "networkpolicies"}
+
+// queryURL returns the controlplane API URL for the provided resource, and optionally object name,
+// Namespace (NetworkPolicies only) and Node.
+func queryURL(server, resource, name, namespace, nodeName string) string {
+	path := "/apis/" + networking.APIVersion + "/"
+	if namespace != "" {
+		path += "namespaces/" + namespace + "/"
+	}
+	path += resource
+	if name != "" {
+		path += "/" + name
+	}
+	u := strings.TrimSuffix(server, "/") + path
+	if nodeName != "" {
+		u += "?" + url.Values{"fieldSelector": []string{"nodeName=" + nodeName}}.Encode()
+	}
+	return u
+}
+
+// runQuery gets or lists objects from the controlplane API of a running instance and prints them
+// as JSON.
+func runQuery(args []string) error {
+	fs := newFlagSet("query", "[flags] <"+strings.Join(queryResources, "|")+"> [name]")
+	server := fs.String("server", defaultServer, "Base URL of the API of the running instance")
+	namespace := fs.String("n", "", "Namespace of the NetworkPolicies to query, all Namespaces if empty")
+	nodeName := fs.String("node", "", "Only list the objects which span this Node")
+	fs.Parse(args)
+	if fs.NArg() < 1 || fs.NArg() > 2 {
+		fs.Usage()
+		return fmt.Errorf("expected a resource and an optional name")
+	}
+	resource := fs.Arg(0)
+	valid := false
+	for _, r := range queryResources {
+		valid = valid || r == resource
+	}
+	if !valid {
+		return fmt.Errorf("invalid resource '%s', must be one of %s", resource, strings.Join(queryResources, ", "))
+	}
+	if *namespace != "" && resource != "networkpolicies" {
+		return fmt.Errorf("-n is only supported for networkpolicies")
+	}
+
+	client := &http.Client{Timeout: requestTimeout}
+	resp, err := client.Get(queryURL(*server, resource, fs.Arg(1), *namespace, *nodeName))
+	if err != nil {
+		return fmt.Errorf("error when querying API: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("error when reading response: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("query failed with status %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	var out bytes.Buffer
+	if err := json.Indent(&out, body, "", "  "); err != nil {
+		return fmt.Errorf("error when decoding response: %v", err)
+	}
+	out.WriteString("\n")
+	_, err = out.WriteTo(os.Stdout)
+	return err
+}
@@ -0,0 +1,75 @@
+// Copyright 2020 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	corev1 "k8s.io/api/core/v1"
+	rbacv1 "k8s.io/api/rbac/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/yaml"
+)
+
+// rbacObjects returns the ServiceAccount, ClusterRole and ClusterRoleBinding required to run the
+// "run" command in a Pod using the ServiceAccount. The ClusterRole grants read access to the
+// resources watched by the controller.
+func rbacObjects(name, namespace string) []runtime.Object {
+	readVerbs := []string{"get", "list", "watch"}
+	return []runtime.Object{
+		&corev1.ServiceAccount{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "ServiceAccount"},
+			ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: namespace},
+		},
+		&rbacv1.ClusterRole{
+			TypeMeta:   metav1.TypeMeta{APIVersion: rbacv1.SchemeGroupVersion.String(), Kind: "ClusterRole"},
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			Rules: []rbacv1.PolicyRule{
+				{APIGroups: []string{""}, Resources: []string{"pods", "namespaces"}, Verbs: readVerbs},
+				{APIGroups: []string{"networking.k8s.io"}, Resources: []string{"networkpolicies"}, Verbs: readVerbs},
+			},
+		},
+		&rbacv1.ClusterRoleBinding{
+			TypeMeta:   metav1.TypeMeta{APIVersion: rbacv1.SchemeGroupVersion.String(), Kind: "ClusterRoleBinding"},
+			ObjectMeta: metav1.ObjectMeta{Name: name},
+			RoleRef:    rbacv1.RoleRef{APIGroup: rbacv1.GroupName, Kind: "ClusterRole", Name: name},
+			Subjects: []rbacv1.Subject{
+				{Kind: rbacv1.ServiceAccountKind, Name: name, Namespace: namespace},
+			},
+		},
+	}
+}
+
+// runRBAC prints the RBAC manifest, which can be applied with "kubectl apply -f -".
+func runRBAC(args []string) error {
+	fs := newFlagSet("rbac", "[flags]")
+	name := fs.String("name", "antrea-convert", "Name of the ServiceAccount, ClusterRole and ClusterRoleBinding")
+	namespace := fs.String("namespace", "kube-system", "Namespace of the ServiceAccount")
+	fs.Parse(args)
+
+	for i, obj := range rbacObjects(*name, *namespace) {
+		b, err := yaml.Marshal(obj)
+		if err != nil {
+			return fmt.Errorf("error when marshalling %s: %v", obj.GetObjectKind().GroupVersionKind().Kind, err)
+		}
+		if i > 0 {
+			fmt.Println("---")
+		}
+		os.Stdout.Write(b)
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+// Copyright 2020 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+
+	"github.com/antoninbas/antrea-k8s-to-ddlog/pkg/explain"
+)
+
+// runReachability evaluates whether traffic between two Pods is allowed by the NetworkPolicies,
+// either by querying a running instance or by computing the outputs from manifests.
+func runReachability(args []string) error {
+	fs := newFlagSet("reachability", "[flags] <source namespace>/<name> <destination namespace>/<name>")
+	server := fs.String("server", defaultServer, "Base URL of the API of the running instance")
+	output := fs.String("o", "text", "Output format, one of 'text' or 'json'")
+	protocol := fs.String("protocol", string(v1.ProtocolTCP), "Protocol of the traffic, one of TCP, UDP or SCTP")
+	port := fs.String("port", "", "Destination port, as a number or as the name of a container port of the destination Pod")
+	var offline offlineFlags
+	addOfflineFlags(fs, &offline)
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return fmt.Errorf("expected a source and a destination Pod")
+	}
+	if *port == "" {
+		return fmt.Errorf("-port is required")
+	}
+	if *output != "text" && *output != "json" {
+		return fmt.Errorf("invalid output format '%s', must be one of 'text' or 'json'", *output)
+	}
+	var keys [2][2]string
+	for i := range keys {
+		namespace, name, err := explain.ParsePodKey(fs.Arg(i))
+		if err != nil {
+			return err
+		}
+		keys[i] = [2]string{namespace, name}
+	}
+	proto := v1.Protocol(strings.ToUpper(*protocol))
+
+	var result *explain.Reachability
+	if len(offline.fileNames) > 0 {
+		outputView, podLister, err := loadOfflineView(&offline)
+		if err != nil {
+			return err
+		}
+		var pods [2]*v1.Pod
+		for i, key := range keys {
+			pods[i], err = podLister.Pods(key[0]).Get(key[1])
+			if errors.IsNotFound(err) {
+				return fmt.Errorf("Pod %s/%s not found in manifests", key[0], key[1])
+			} else if err != nil {
+				return err
+			}
+		}
+		if result, err = explain.CheckReachability(outputView, pods[0], pods[1], proto, explain.ParsePort(*port)); err != nil {
+			return err
+		}
+	} else {
+		result = &explain.Reachability{}
+		if err := getJSON(*server, explain.ReachabilityPath(fs.Arg(0), fs.Arg(1), proto, *port), result); err != nil {
+			return err
+		}
+	}
+
+	if *output == "json" {
+		return printJSON(result)
+	}
+	result.Print(os.Stdout)
+	return nil
+}
@@ -0,0 +1,105 @@
+// Copyright 2020 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"reflect"
+	"strconv"
+
+	"k8s.io/klog"
+
+	"github.com/antoninbas/antrea-k8s-to-ddlog/pkg/config"
+	"github.com/antoninbas/antrea-k8s-to-ddlog/pkg/controller"
+)
+
+// verbosityLevels are the klog verbosity levels cycled through on SIGUSR2.
+var verbosityLevels = []int32{0, 2, 4}
+
+func getVerbosity() int32 {
+	v, err := strconv.ParseInt(flag.CommandLine.Lookup("v").Value.String(), 10, 32)
+	if err != nil {
+		return 0
+	}
+	return int32(v)
+}
+
+func setVerbosity(level int32) {
+	if err := flag.CommandLine.Lookup("v").Value.Set(strconv.Itoa(int(level))); err != nil {
+		klog.Errorf("Error when setting log verbosity: %v", err)
+		return
+	}
+	klog.Infof("Log verbosity set to %d", level)
+}
+
+// cycleVerbosity sets the verbosity to the next level in verbosityLevels, wrapping around to the
+// first one.
+func cycleVerbosity() {
+	current := getVerbosity()
+	for _, level := range verbosityLevels {
+		if level > current {
+			setVerbosity(level)
+			return
+		}
+	}
+	setVerbosity(verbosityLevels[0])
+}
+
+// configVerbosity returns the verbosity to set from the logging section of the configuration, or
+// nil if the verbosity must not be changed. The -v flag takes precedence over the configuration
+// file, so nothing is set if verbositySet is true. previous is the logging section of the
+// configuration loaded previously, or nil at startup: when reloading, the verbosity is only set if
+// it changed, so that a verbosity selected with SIGUSR2 is preserved otherwise.
+func configVerbosity(logging, previous *config.Logging, verbositySet bool) *int32 {
+	if verbositySet || logging.Verbosity == nil {
+		return nil
+	}
+	if previous != nil && previous.Verbosity != nil && *previous.Verbosity == *logging.Verbosity {
+		return nil
+	}
+	return logging.Verbosity
+}
+
+func applyLogging(logging, previous *config.Logging, verbositySet bool) {
+	if verbosity := configVerbosity(logging, previous, verbositySet); verbosity != nil && *verbosity != getVerbosity() {
+		setVerbosity(*verbosity)
+	}
+}
+
+// reloadConfig parses the configuration file and the "run" flags again, and applies the fields
+// which can be changed at runtime: the controller batching options and the logging section. The
+// klog flags are not applied again, so that the verbosity is not reset to the value of the -v flag,
+// and the verbosity from the logging section is only applied if it changed (see configVerbosity).
+// It returns the new configuration, or current if the new
+// configuration is invalid.
+func reloadConfig(args []string, current *config.Configuration, c *controller.Controller) *config.Configuration {
+	cfg, opts, err := parseRunFlags(args, false)
+	if err != nil {
+		klog.Errorf("Error when reloading configuration, keeping the current one: %v", err)
+		return current
+	}
+	c.SetBatchingOptions(cfg.Controller.MaxUpdatesPerTransaction, cfg.Controller.MaxTransactionDelay.Duration)
+	applyLogging(&cfg.Logging, &current.Logging, opts.verbositySet)
+	klog.Infof("Configuration reloaded: maxUpdatesPerTransaction=%d, maxTransactionDelay=%v", cfg.Controller.MaxUpdatesPerTransaction, cfg.Controller.MaxTransactionDelay.Duration)
+
+	ignored := *cfg
+	ignored.Controller.MaxUpdatesPerTransaction = current.Controller.MaxUpdatesPerTransaction
+	ignored.Controller.MaxTransactionDelay = current.Controller.MaxTransactionDelay
+	ignored.Logging = current.Logging
+	if !reflect.DeepEqual(&ignored, current) {
+		klog.Warningf("Some configuration changes can only be applied by restarting antrea-convert")
+	}
+	return cfg
+}
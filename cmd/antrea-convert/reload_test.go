@@ -0,0 +1,66 @@
+// Copyright 2020 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/antoninbas/antrea-k8s-to-ddlog/pkg/config"
+)
+
+func TestConfigVerbosity(t *testing.T) {
+	verbosity := func(v int32) *int32 { return &v }
+	for _, tc := range []struct {
+		name         string
+		logging      config.Logging
+		previous     *config.Logging
+		verbositySet bool
+		expected     *int32
+	}{
+		{"startup", config.Logging{Verbosity: verbosity(2)}, nil, false, verbosity(2)},
+		{"startup with -v", config.Logging{Verbosity: verbosity(2)}, nil, true, nil},
+		{"startup without verbosity", config.Logging{}, nil, false, nil},
+		{"reload unchanged", config.Logging{Verbosity: verbosity(2)}, &config.Logging{Verbosity: verbosity(2)}, false, nil},
+		{"reload changed", config.Logging{Verbosity: verbosity(4)}, &config.Logging{Verbosity: verbosity(2)}, false, verbosity(4)},
+		{"reload added", config.Logging{Verbosity: verbosity(4)}, &config.Logging{}, false, verbosity(4)},
+		{"reload changed with -v", config.Logging{Verbosity: verbosity(4)}, &config.Logging{Verbosity: verbosity(2)}, true, nil},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.expected, configVerbosity(&tc.logging, tc.previous, tc.verbositySet))
+		})
+	}
+}
+
+func TestParseRunFlagsVerbositySet(t *testing.T) {
+	dir, err := ioutil.TempDir("", "antrea-convert")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	configFile := filepath.Join(dir, "config.yml")
+	require.NoError(t, ioutil.WriteFile(configFile, []byte("apiVersion: antrea-convert.antrea.io/v1alpha1\nkind: Configuration\nlogging:\n  verbosity: 4\n"), 0644))
+
+	_, opts, err := parseRunFlags([]string{"--config", configFile}, false)
+	require.NoError(t, err)
+	assert.False(t, opts.verbositySet)
+
+	_, opts, err = parseRunFlags([]string{"--config", configFile, "-v", "2"}, false)
+	require.NoError(t, err)
+	assert.True(t, opts.verbositySet)
+}
@@ -0,0 +1,90 @@
+// Copyright 2020 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"k8s.io/klog"
+
+	"github.com/antoninbas/antrea-k8s-to-ddlog/pkg/manifest"
+	"github.com/antoninbas/antrea-k8s-to-ddlog/pkg/outhandler"
+	"github.com/antoninbas/antrea-k8s-to-ddlog/pkg/program"
+	"github.com/antoninbas/antrea-k8s-to-ddlog/pkg/repl"
+	"github.com/vmware/differential-datalog/go/pkg/ddlog"
+)
+
+const historyFileName = ".antrea-convert_history"
+
+func defaultHistoryFile() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, historyFileName)
+}
+
+// runREPL starts an interactive session in which objects can be applied to the DDlog program and
+// relations can be inspected, without a cluster.
+func runREPL(args []string) error {
+	fs := newFlagSet("repl", "[flags]")
+	script := fs.String("f", "", "Run the commands from this file instead of reading them from stdin, and stop at the first error")
+	historyFile := fs.String("history-file", defaultHistoryFile(), "File in which the command history is persisted, empty to disable")
+	numNodes := fs.Int("nodes", manifest.DefaultNumNodes, "Number of synthetic Nodes on which Pods with no Node name are scheduled")
+	podCIDR := fs.String("pod-cidr", manifest.DefaultPodCIDR, "CIDR from which IPs are allocated to Pods with no IP")
+	fs.Parse(args)
+	if fs.NArg() != 0 {
+		fs.Usage()
+		return fmt.Errorf("unexpected arguments")
+	}
+
+	loader, err := manifest.NewLoader(manifest.Options{NumNodes: *numNodes, PodCIDR: *podCIDR})
+	if err != nil {
+		return err
+	}
+
+	ddlog.SetErrMsgPrinter(k8sLogger)
+	collector := outhandler.NewCollector()
+	ddlogProgram, err := program.NewProgram(1, collector)
+	if err != nil {
+		return fmt.Errorf("error when creating DDLog program: %v", err)
+	}
+	defer func() {
+		if err := ddlogProgram.Stop(); err != nil {
+			klog.Errorf("Error when stopping DDLog program: %v", err)
+		}
+	}()
+
+	session := repl.NewSession(ddlogProgram, collector, loader, os.Stdout)
+	if *script != "" {
+		f, err := os.Open(*script)
+		if err != nil {
+			return fmt.Errorf("error when opening script: %v", err)
+		}
+		defer f.Close()
+		return session.Run(f, false)
+	}
+	if *historyFile != "" {
+		closeHistory, err := session.SetHistoryFile(*historyFile)
+		if err != nil {
+			return err
+		}
+		defer closeHistory()
+	}
+	fmt.Fprintln(os.Stdout, "Type 'help' for the list of commands.")
+	return session.Run(os.Stdin, true)
+}
@@ -0,0 +1,121 @@
+// Copyright 2020 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"k8s.io/klog"
+
+	"github.com/antoninbas/antrea-k8s-to-ddlog/pkg/outhandler"
+	"github.com/antoninbas/antrea-k8s-to-ddlog/pkg/program"
+	"github.com/antoninbas/antrea-k8s-to-ddlog/pkg/replay"
+	"github.com/vmware/differential-datalog/go/pkg/ddlog"
+)
+
+// stepper prompts the user before each transaction in step-by-step mode.
+type stepper struct {
+	in      *bufio.Reader
+	out     io.Writer
+	enabled bool
+}
+
+// next returns false if the user asked to quit.
+func (s *stepper) next(idx int, txn *replay.Transaction) bool {
+	if !s.enabled {
+		return true
+	}
+	fmt.Fprintf(s.out, "Transaction %d (line %d, %d updates): press Enter to apply, 'c' to apply all remaining transactions, 'q' to quit: ", idx, txn.Line, len(txn.Updates))
+	answer, err := s.in.ReadString('\n')
+	if err != nil && answer == "" {
+		// EOF on stdin: stop stepping rather than quitting.
+		s.enabled = false
+		return true
+	}
+	switch strings.TrimSpace(answer) {
+	case "c":
+		s.enabled = false
+	case "q":
+		return false
+	}
+	return true
+}
+
+// runReplay re-applies the commands recorded with --record-commands to a new DDlog program.
+func runReplay(args []string) error {
+	fs := newFlagSet("replay", "[flags] <recorded commands file>")
+	step := fs.Bool("step", false, "Prompt before applying each transaction")
+	dump := fs.Bool("dump", false, "Print the contents of the output relations after each transaction")
+	output := fs.String("o", "text", "Output format used with --dump, one of 'text' or 'json'")
+	dumpChanges := fs.String("dump-changes", "", "Provide a file name where to dump record changes")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("expected exactly one recorded commands file")
+	}
+
+	transactions, err := replay.ParseFile(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	ddlog.SetErrMsgPrinter(k8sLogger)
+	collector := outhandler.NewCollector()
+	var outRecordHandler ddlog.OutRecordHandler = collector
+	if *dumpChanges != "" {
+		dumper, err := ddlog.NewOutRecordDumper(*dumpChanges)
+		if err != nil {
+			return fmt.Errorf("error when creating DDLog output dumper: %v", err)
+		}
+		outRecordHandler = outhandler.NewMulti(collector, dumper)
+	}
+	ddlogProgram, err := program.NewProgram(1, outRecordHandler)
+	if err != nil {
+		return fmt.Errorf("error when creating DDLog program: %v", err)
+	}
+	defer func() {
+		if err := ddlogProgram.Stop(); err != nil {
+			klog.Errorf("Error when stopping DDLog program: %v", err)
+		}
+	}()
+
+	s := &stepper{in: bufio.NewReader(os.Stdin), out: os.Stderr, enabled: *step}
+	for i, txn := range transactions {
+		if !s.next(i, txn) {
+			return nil
+		}
+		if err := txn.Apply(ddlogProgram); err != nil {
+			return fmt.Errorf("transaction %d (line %d): %v", i, txn.Line, err)
+		}
+		outcome := "committed"
+		if txn.Rollback {
+			outcome = "rolled back"
+		}
+		fmt.Fprintf(os.Stderr, "Transaction %d (line %d, %d updates) %s\n", i, txn.Line, len(txn.Updates), outcome)
+		if *dump {
+			if err := collector.Print(os.Stdout, *output); err != nil {
+				return err
+			}
+		}
+	}
+	if !*dump {
+		return collector.Print(os.Stdout, *output)
+	}
+	return nil
+}
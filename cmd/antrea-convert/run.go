@@ -0,0 +1,331 @@
+// Copyright 2020 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"syscall"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/informers"
+	"k8s.io/klog"
+
+	"github.com/antoninbas/antrea-k8s-to-ddlog/pkg/apiserver"
+	"github.com/antoninbas/antrea-k8s-to-ddlog/pkg/checker"
+	"github.com/antoninbas/antrea-k8s-to-ddlog/pkg/config"
+	"github.com/antoninbas/antrea-k8s-to-ddlog/pkg/controller"
+	"github.com/antoninbas/antrea-k8s-to-ddlog/pkg/explain"
+	"github.com/antoninbas/antrea-k8s-to-ddlog/pkg/outhandler"
+	"github.com/antoninbas/antrea-k8s-to-ddlog/pkg/profiling"
+	"github.com/antoninbas/antrea-k8s-to-ddlog/pkg/program"
+	"github.com/antoninbas/antrea-k8s-to-ddlog/pkg/signals"
+	"github.com/antoninbas/antrea-k8s-to-ddlog/pkg/snapshot"
+	"github.com/antoninbas/antrea-k8s-to-ddlog/pkg/view"
+	"github.com/vmware/differential-datalog/go/pkg/ddlog"
+)
+
+// runOptions are the options of the "run" command which are not part of the configuration file.
+type runOptions struct {
+	configFile        string
+	recordCommands    string
+	dumpChanges       string
+	dumpFormat        string
+	checkEquivalence  bool
+	checkInterval     time.Duration
+	webhookURLs       stringSliceFlag
+	webhookQueueSize  int
+	webhookMaxRetries int
+	profiling         bool
+	// verbositySet is true if the klog -v flag is set on the command line.
+	verbositySet bool
+}
+
+// workersFlag is a flag.Value for a number of DDlog workers.
+type workersFlag struct {
+	workers *intstr.IntOrString
+}
+
+func (f workersFlag) String() string {
+	if f.workers == nil {
+		return ""
+	}
+	return f.workers.String()
+}
+
+func (f workersFlag) Set(value string) error {
+	workers, err := config.ParseWorkers(value)
+	if err != nil {
+		return err
+	}
+	*f.workers = workers
+	return nil
+}
+
+// newRunFlagSet creates the FlagSet of the "run" command. Flags which correspond to configuration
+// fields are bound to cfg, and use the current values as defaults.
+func newRunFlagSet(cfg *config.Configuration, opts *runOptions) *flag.FlagSet {
+	fs := newFlagSet("run", "[flags]")
+	fs.StringVar(&opts.configFile, "config", "", "Path to a configuration file, see pkg/config for the format. Flags set on the command line take precedence over the configuration file")
+	fs.StringVar(&opts.recordCommands, "record-commands", "", "Provide a file name where to record commands sent to DDLog")
+	fs.StringVar(&opts.dumpChanges, "dump-changes", "", "Provide a file name where to dump record changes")
+	fs.StringVar(&opts.dumpFormat, "dump-format", "text", "Format used to dump record changes, one of 'text' or 'jsonl'")
+	fs.StringVar(&cfg.APIBindAddress, "api-bind-address", cfg.APIBindAddress, "Address on which to serve the output API (e.g. ':10349'), disabled if empty")
+	fs.BoolVar(&opts.checkEquivalence, "check-equivalence", false, "Continuously compare the DDLog output with a Go reference implementation and report divergences")
+	fs.DurationVar(&opts.checkInterval, "check-interval", 10*time.Second, "Interval between equivalence checks")
+	fs.Var(&opts.webhookURLs, "webhook-url", "URL to which output changes are POSTed as JSON after each transaction, can be repeated. Delivery metrics are served on GET /webhooks with --api-bind-address")
+	fs.IntVar(&opts.webhookQueueSize, "webhook-queue-size", 100, "Maximum number of transactions queued for each webhook URL")
+	fs.IntVar(&opts.webhookMaxRetries, "webhook-max-retries", 5, "Maximum number of retries when delivering to a webhook URL, or -1 to disable retries")
+	fs.StringVar(&cfg.SnapshotDir, "snapshot-dir", cfg.SnapshotDir, "Directory where snapshots of the DDLog input relations are written on SIGUSR1 or on POST /snapshot, disabled if empty")
+	fs.Var(workersFlag{&cfg.DDlog.Workers}, "ddlog-workers", "Number of DDLog worker threads, or 'auto' for one worker per available CPU, taking the CPU limit of the container into account")
+	fs.BoolVar(&opts.profiling, "profiling", false, "Serve the DDLog profiling and Go pprof endpoints under /debug/, requires --api-bind-address")
+	addClientFlags(fs, &cfg.ClientConnection)
+	return fs
+}
+
+// parseRunFlags returns the configuration and options of the "run" command. Configuration values
+// are taken from the flags set on the command line, then from the configuration file, then from
+// the defaults. When reloading the configuration, applyGlobalFlags must be false, so that the
+// global flags, e.g. the klog verbosity, are not reset to their values on the command line.
+func parseRunFlags(args []string, applyGlobalFlags bool) (*config.Configuration, *runOptions, error) {
+	parse := func(cfg *config.Configuration, opts *runOptions) {
+		fs := newRunFlagSet(cfg, opts)
+		if !applyGlobalFlags {
+			ignoreGlobalFlags(fs)
+		}
+		fs.Parse(args)
+		fs.Visit(func(f *flag.Flag) {
+			if f.Name == "v" {
+				opts.verbositySet = true
+			}
+		})
+	}
+	cfg := config.NewDefault()
+	opts := &runOptions{}
+	parse(cfg, opts)
+	if opts.configFile != "" {
+		var err error
+		if cfg, err = config.LoadFile(opts.configFile); err != nil {
+			return nil, nil, err
+		}
+		// Parse the flags again on top of the configuration file, so that only the flags set
+		// on the command line override it.
+		opts = &runOptions{}
+		parse(cfg, opts)
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, nil, fmt.Errorf("invalid configuration: %v", err)
+	}
+	if opts.profiling && cfg.APIBindAddress == "" {
+		return nil, nil, fmt.Errorf("--profiling requires --api-bind-address")
+	}
+	return cfg, opts, nil
+}
+
+// runController watches the cluster and computes the output relations continuously. This is the
+// default command.
+func runController(args []string) error {
+	cfg, opts, err := parseRunFlags(args, true)
+	if err != nil {
+		return err
+	}
+
+	applyLogging(&cfg.Logging, nil, opts.verbositySet)
+
+	clientset, err := newClientset(&cfg.ClientConnection)
+	if err != nil {
+		return err
+	}
+
+	ddlog.SetErrMsgPrinter(k8sLogger)
+
+	var outRecordHandlers []ddlog.OutRecordHandler
+	if opts.dumpChanges != "" {
+		switch opts.dumpFormat {
+		case "text":
+			dumper, err := ddlog.NewOutRecordDumper(opts.dumpChanges)
+			if err != nil {
+				return fmt.Errorf("error when creating DDLog output dumper: %v", err)
+			}
+			outRecordHandlers = append(outRecordHandlers, dumper)
+		case "jsonl":
+			dumper, err := outhandler.NewJSONLFileDumper(opts.dumpChanges)
+			if err != nil {
+				return fmt.Errorf("error when creating DDLog output dumper: %v", err)
+			}
+			defer dumper.Close()
+			outRecordHandlers = append(outRecordHandlers, dumper)
+		default:
+			return fmt.Errorf("invalid dump format '%s', must be one of 'text' or 'jsonl'", opts.dumpFormat)
+		}
+	}
+	var outputView *view.View
+	if cfg.APIBindAddress != "" || opts.checkEquivalence {
+		outputView = view.NewView()
+		outRecordHandlers = append(outRecordHandlers, outputView)
+	}
+	var webhook *outhandler.Webhook
+	if len(opts.webhookURLs) > 0 {
+		webhook = outhandler.NewWebhook(outhandler.WebhookOptions{
+			URLs:       opts.webhookURLs,
+			QueueSize:  opts.webhookQueueSize,
+			MaxRetries: opts.webhookMaxRetries,
+		})
+		outRecordHandlers = append(outRecordHandlers, webhook)
+	}
+	outRecordHandler := outhandler.NewMulti(outRecordHandlers...)
+
+	workers := config.ResolveWorkers(cfg.DDlog.Workers)
+	klog.Infof("Using %d DDLog worker(s)", workers)
+	ddlogProgram, err := program.NewProgram(workers, outRecordHandler)
+	if err != nil {
+		return fmt.Errorf("error when creating DDLog program: %v", err)
+	}
+	defer func() {
+		klog.Infof("Stopping DDLog program")
+		if err := ddlogProgram.Stop(); err != nil {
+			klog.Errorf("Error when stopping DDLog program: %v", err)
+		}
+	}()
+
+	if opts.recordCommands != "" {
+		ddlogProgram.StartRecordingCommands(opts.recordCommands)
+	}
+
+	informerFactory := informers.NewSharedInformerFactory(clientset, cfg.ClientConnection.ResyncPeriod.Duration)
+	podInformer := informerFactory.Core().V1().Pods()
+	namespaceInformer := informerFactory.Core().V1().Namespaces()
+	networkPolicyInformer := informerFactory.Networking().V1().NetworkPolicies()
+
+	c := controller.NewController(
+		clientset,
+		podInformer,
+		namespaceInformer,
+		networkPolicyInformer,
+		controller.NewProgramSink(ddlogProgram),
+		controller.Options{
+			ResyncPeriod:             cfg.ClientConnection.ResyncPeriod.Duration,
+			InputWorkers:             cfg.Controller.InputWorkers,
+			MaxUpdatesPerTransaction: cfg.Controller.MaxUpdatesPerTransaction,
+			MaxTransactionDelay:      cfg.Controller.MaxTransactionDelay.Duration,
+			MinRetryDelay:            cfg.Controller.MinRetryDelay.Duration,
+			MaxRetryDelay:            cfg.Controller.MaxRetryDelay.Duration,
+			ShutdownTimeout:          cfg.Controller.ShutdownTimeout.Duration,
+		},
+	)
+
+	stopCh := signals.RegisterSignalHandlers()
+	dispatcher := signals.NewDispatcher()
+	// The handlers are called from the dispatcher goroutine, which owns currentCfg.
+	currentCfg := cfg
+	dispatcher.Handle(syscall.SIGHUP, func() {
+		if opts.configFile == "" {
+			klog.Warningf("No configuration file, ignoring SIGHUP")
+			return
+		}
+		currentCfg = reloadConfig(args, currentCfg, c)
+	})
+	dispatcher.Handle(syscall.SIGUSR2, cycleVerbosity)
+
+	var snapshotter *snapshot.Snapshotter
+	if cfg.SnapshotDir != "" {
+		snapshotter = snapshot.NewSnapshotter(ddlogProgram, cfg.SnapshotDir)
+		dispatcher.Handle(syscall.SIGUSR1, func() {
+			if _, err := snapshotter.Take(); err != nil {
+				klog.Errorf("Error when taking snapshot: %v", err)
+			}
+		})
+	}
+
+	// The API server listens before anything is started, so that antrea-convert fails right away
+	// if the bind address is not available.
+	var server *apiserver.Server
+	if cfg.APIBindAddress != "" {
+		server = apiserver.NewServer(cfg.APIBindAddress, outputView)
+		if snapshotter != nil {
+			server.Handle("/snapshot", snapshotter)
+		}
+		if webhook != nil {
+			server.Handle("/webhooks", webhook)
+		}
+		server.Handle(explain.PathPrefix, explain.NewHandler(outputView, podInformer.Lister()))
+		if opts.profiling {
+			server.Handle(profiling.PathPrefix, profiling.NewHandler(ddlogProgram))
+		}
+		if err := server.Listen(); err != nil {
+			return err
+		}
+	}
+
+	informerFactory.Start(stopCh)
+
+	controllerDone := make(chan struct{})
+	go func() {
+		defer close(controllerDone)
+		c.Run(stopCh)
+	}()
+
+	// The webhook is stopped separately, after the controller has drained the pending updates,
+	// so that the batches for the last transactions can still be delivered.
+	webhookStopCh := make(chan struct{})
+	if webhook != nil {
+		go webhook.Run(webhookStopCh)
+	}
+
+	if opts.checkEquivalence {
+		go checker.NewChecker(
+			podInformer.Lister(),
+			namespaceInformer.Lister(),
+			networkPolicyInformer.Lister(),
+			outputView,
+			opts.checkInterval,
+		).Run(stopCh)
+	}
+
+	dispatcherDone := make(chan struct{})
+	go func() {
+		defer close(dispatcherDone)
+		dispatcher.Run(stopCh)
+	}()
+
+	serverDone := make(chan struct{})
+	if server != nil {
+		go func() {
+			defer close(serverDone)
+			if err := server.Run(stopCh); err != nil {
+				klog.Errorf("Error when running API server: %v", err)
+			}
+		}()
+	} else {
+		close(serverDone)
+	}
+
+	<-stopCh
+
+	shutdownDeadline := time.Now().Add(cfg.Controller.ShutdownTimeout.Duration)
+	<-controllerDone
+	if webhook != nil {
+		webhook.Flush(time.Until(shutdownDeadline))
+	}
+	close(webhookStopCh)
+	// The signal handlers and the API handlers can still use the DDlog program (snapshots,
+	// profiles), which is only stopped once they have returned.
+	<-dispatcherDone
+	<-serverDone
+
+	klog.Infof("Exiting")
+	return nil
+}
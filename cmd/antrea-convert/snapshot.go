@@ -0,0 +1,122 @@
+// Copyright 2020 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"k8s.io/klog"
+
+	"github.com/antoninbas/antrea-k8s-to-ddlog/pkg/outhandler"
+	"github.com/antoninbas/antrea-k8s-to-ddlog/pkg/program"
+	"github.com/antoninbas/antrea-k8s-to-ddlog/pkg/snapshot"
+	"github.com/vmware/differential-datalog/go/pkg/ddlog"
+)
+
+const (
+	// defaultServer is the default address of a running instance for commands which use its
+	// API.
+	defaultServer = "http://localhost:10349"
+	// requestTimeout is the timeout for requests to a running instance.
+	requestTimeout = 30 * time.Second
+)
+
+func runSnapshot(args []string) error {
+	fs := newFlagSet("snapshot", "take|restore [flags]")
+	fs.Parse(args)
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return fmt.Errorf("expected 'take' or 'restore'")
+	}
+	switch fs.Arg(0) {
+	case "take":
+		return runSnapshotTake(fs.Args()[1:])
+	case "restore":
+		return runSnapshotRestore(fs.Args()[1:])
+	}
+	fs.Usage()
+	return fmt.Errorf("unknown snapshot command '%s', expected 'take' or 'restore'", fs.Arg(0))
+}
+
+// runSnapshotTake asks a running instance, started with --snapshot-dir and --api-bind-address, to
+// write a snapshot of its inputs, and prints the path of the snapshot file.
+func runSnapshotTake(args []string) error {
+	fs := newFlagSet("snapshot take", "[flags]")
+	server := fs.String("server", defaultServer, "Base URL of the API of the running instance")
+	fs.Parse(args)
+
+	client := &http.Client{Timeout: requestTimeout}
+	resp, err := client.Post(strings.TrimSuffix(*server, "/")+"/snapshot", "", nil)
+	if err != nil {
+		return fmt.Errorf("error when requesting snapshot: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("error when reading response: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("snapshot request failed with status %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	var response struct {
+		Path string `json:"path"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return fmt.Errorf("error when decoding response: %v", err)
+	}
+	fmt.Println(response.Path)
+	return nil
+}
+
+// runSnapshotRestore loads an input snapshot written with --snapshot-dir into a new DDlog program
+// and prints the resulting output relations.
+func runSnapshotRestore(args []string) error {
+	fs := newFlagSet("snapshot restore", "[flags] <snapshot file>")
+	output := fs.String("o", "text", "Output format, one of 'text' or 'json'")
+	recordCommands := fs.String("record-commands", "", "Provide a file name where to record commands sent to DDLog, e.g. to replay them later")
+	fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("expected exactly one snapshot file")
+	}
+
+	ddlog.SetErrMsgPrinter(k8sLogger)
+	collector := outhandler.NewCollector()
+	ddlogProgram, err := program.NewProgram(1, collector)
+	if err != nil {
+		return fmt.Errorf("error when creating DDLog program: %v", err)
+	}
+	defer func() {
+		if err := ddlogProgram.Stop(); err != nil {
+			klog.Errorf("Error when stopping DDLog program: %v", err)
+		}
+	}()
+	if *recordCommands != "" {
+		if err := ddlogProgram.StartRecordingCommands(*recordCommands); err != nil {
+			return fmt.Errorf("error when starting to record commands: %v", err)
+		}
+	}
+
+	if err := snapshot.Restore(ddlogProgram, fs.Arg(0)); err != nil {
+		return err
+	}
+	return collector.Print(os.Stdout, *output)
+}
@@ -0,0 +1,18 @@
+// Copyright 2020 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package v1beta1 mirrors the Antrea controlplane API types (networking.antrea.tanzu.vmware.com
+// v1beta1), so that the DDlog outputs can be served in the exact same JSON shapes as the ones
+// produced by the Antrea controller, without depending on the Antrea code base.
+package v1beta1
@@ -0,0 +1,172 @@
+// Copyright 2020 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1beta1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+const (
+	GroupName = "networking.antrea.tanzu.vmware.com"
+	Version   = "v1beta1"
+)
+
+// APIVersion is the value of the apiVersion field for all the types in this package.
+var APIVersion = GroupName + "/" + Version
+
+// AppliedToGroup is the message format of antrea/pkg/controller/types.AppliedToGroup in an API response.
+type AppliedToGroup struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	// Pods is a list of Pods selected by this group.
+	Pods []GroupMemberPod `json:"pods,omitempty"`
+}
+
+// PodReference represents a Pod Reference.
+type PodReference struct {
+	// The name of this pod.
+	Name string `json:"name,omitempty"`
+	// The namespace of this pod.
+	Namespace string `json:"namespace,omitempty"`
+}
+
+// NamedPort represents a Port with a name on Pod.
+type NamedPort struct {
+	// Port represents the Port number.
+	Port int32 `json:"port,omitempty"`
+	// Name represents the associated name with this Port number.
+	Name string `json:"name,omitempty"`
+	// Protocol for port. Must be UDP, TCP, or SCTP.
+	Protocol Protocol `json:"protocol,omitempty"`
+}
+
+// GroupMemberPod represents a Pod related member to be populated in Groups.
+type GroupMemberPod struct {
+	// Pod maintains the reference to the Pod.
+	Pod *PodReference `json:"pod,omitempty"`
+	// IP maintains the IPAddress of the Pod.
+	IP IPAddress `json:"ip,omitempty"`
+	// Ports maintain the list of named port associated with this Pod member.
+	Ports []NamedPort `json:"ports,omitempty"`
+}
+
+// AppliedToGroupList is a list of AppliedToGroup objects.
+type AppliedToGroupList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []AppliedToGroup `json:"items"`
+}
+
+// AddressGroup is the message format of antrea/pkg/controller/types.AddressGroup in an API response.
+type AddressGroup struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Pods              []GroupMemberPod `json:"pods,omitempty"`
+}
+
+// AddressGroupList is a list of AddressGroup objects.
+type AddressGroupList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []AddressGroup `json:"items"`
+}
+
+// NetworkPolicy is the message format of antrea/pkg/controller/types.NetworkPolicy in an API response.
+type NetworkPolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	// Rules is a list of rules to be applied to the selected Pods.
+	Rules []NetworkPolicyRule `json:"rules,omitempty"`
+	// AppliedToGroups is a list of names of AppliedToGroups to which this policy applies.
+	AppliedToGroups []string `json:"appliedToGroups,omitempty"`
+}
+
+// Direction defines traffic direction of NetworkPolicyRule.
+type Direction string
+
+const (
+	DirectionIn  Direction = "In"
+	DirectionOut Direction = "Out"
+)
+
+// NetworkPolicyRule describes a particular set of traffic that is allowed.
+type NetworkPolicyRule struct {
+	// The direction of this rule.
+	// If it's set to In, From must be set and To must not be set.
+	// If it's set to Out, To must be set and From must not be set.
+	Direction Direction `json:"direction,omitempty"`
+	// From represents sources which should be able to access the pods selected by the policy.
+	From NetworkPolicyPeer `json:"from,omitempty"`
+	// To represents destinations which should be able to be accessed by the pods selected by the policy.
+	To NetworkPolicyPeer `json:"to,omitempty"`
+	// Services is a list of services which should be matched.
+	Services []Service `json:"services,omitempty"`
+}
+
+// Protocol defines network protocols supported for things like container ports.
+type Protocol string
+
+const (
+	ProtocolTCP  Protocol = "TCP"
+	ProtocolUDP  Protocol = "UDP"
+	ProtocolSCTP Protocol = "SCTP"
+)
+
+// Service describes a port to allow traffic on.
+type Service struct {
+	// The protocol (TCP, UDP, or SCTP) which traffic must match. If not specified, this
+	// field defaults to TCP.
+	Protocol *Protocol `json:"protocol,omitempty"`
+	// The port name or number on the given protocol. If not specified, this matches all port numbers.
+	Port *intstr.IntOrString `json:"port,omitempty"`
+}
+
+// NetworkPolicyPeer describes a peer of NetworkPolicyRules.
+// It could be a list of names of AddressGroups and/or a list of IPBlock.
+type NetworkPolicyPeer struct {
+	// A list of names of AddressGroups.
+	AddressGroups []string `json:"addressGroups,omitempty"`
+	// A list of IPBlock.
+	IPBlocks []IPBlock `json:"ipBlocks,omitempty"`
+}
+
+// IPBlock describes a particular CIDR (Ex. "192.168.1.1/24") that is allowed
+// or denied to/from the workloads matched by a Spec.AppliedTo.
+type IPBlock struct {
+	// CIDR is a string representing the IP Block
+	// Valid examples are "192.168.1.1/24".
+	CIDR IPNet `json:"cidr"`
+	// Except is a slice of CIDRs that should not be included within an IP Block.
+	// Valid examples are "192.168.1.1/24".
+	// Except values will be rejected if they are outside the CIDR range.
+	Except []IPNet `json:"except,omitempty"`
+}
+
+// IPNet describes an IP network.
+type IPNet struct {
+	IP           IPAddress `json:"ip,omitempty"`
+	PrefixLength int32     `json:"prefixLength,omitempty"`
+}
+
+// IPAddress describes a single IP address. Either an IPv4 or IPv6 address must be set.
+type IPAddress []byte
+
+// NetworkPolicyList is a list of NetworkPolicy objects.
+type NetworkPolicyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []NetworkPolicy `json:"items"`
+}
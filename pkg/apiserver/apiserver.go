@@ -0,0 +1,119 @@
+// Copyright 2020 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package apiserver provides an HTTP server exposing the DDlog output relations, as maintained by a
+// view.View, to clients such as Antrea agents.
+package apiserver
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"k8s.io/klog"
+
+	"github.com/antoninbas/antrea-k8s-to-ddlog/pkg/view"
+)
+
+const (
+	// How long to wait for in-flight requests to complete when stopping the server.
+	shutdownTimeout = 5 * time.Second
+)
+
+// Server serves the HTTP API.
+type Server struct {
+	bindAddress string
+	view        *view.View
+	mux         *http.ServeMux
+	listener    net.Listener
+}
+
+// NewServer creates a new Server which will listen on bindAddress and serve the contents of the
+// provided view.
+func NewServer(bindAddress string, v *view.View) *Server {
+	s := &Server{
+		bindAddress: bindAddress,
+		view:        v,
+		mux:         http.NewServeMux(),
+	}
+	s.mux.HandleFunc("/watch", s.handleWatch)
+	s.mux.HandleFunc(controlplanePrefix, s.handleControlplane)
+	s.mux.HandleFunc(strings.TrimSuffix(controlplanePrefix, "/"), s.handleControlplane)
+	return s
+}
+
+// Handle registers an additional handler for the given pattern, e.g. for debugging endpoints. It
+// must be called before Run.
+func (s *Server) Handle(pattern string, handler http.Handler) {
+	s.mux.Handle(pattern, handler)
+}
+
+// ServeHTTP implements the http.Handler interface.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+// Listen binds the listening socket, so that a failure can be reported before the server is run
+// in its own goroutine. Calling it is optional, Run calls it if needed.
+func (s *Server) Listen() error {
+	listener, err := net.Listen("tcp", s.bindAddress)
+	if err != nil {
+		return fmt.Errorf("error when listening on '%s': %v", s.bindAddress, err)
+	}
+	s.listener = listener
+	return nil
+}
+
+// Run starts serving requests and blocks until stopCh is closed and the requests in progress have
+// completed, or until the shutdown timeout expires. It returns an error if the server cannot listen
+// on its bind address or fails to serve requests.
+func (s *Server) Run(stopCh <-chan struct{}) error {
+	if s.listener == nil {
+		if err := s.Listen(); err != nil {
+			return err
+		}
+	}
+	listener := s.listener
+	// Request contexts are derived from baseCtx, which is cancelled when stopCh is closed. This
+	// is required to terminate long-running watch requests, which would otherwise block
+	// Shutdown.
+	baseCtx, baseCancel := context.WithCancel(context.Background())
+	httpServer := &http.Server{
+		Handler:     s,
+		BaseContext: func(net.Listener) context.Context { return baseCtx },
+	}
+
+	shutdownDone := make(chan struct{})
+	go func() {
+		defer close(shutdownDone)
+		<-stopCh
+		baseCancel()
+		ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := httpServer.Shutdown(ctx); err != nil {
+			klog.Errorf("Error when shutting down API server: %v", err)
+		}
+	}()
+
+	klog.Infof("Serving API on %s", listener.Addr())
+	if err := httpServer.Serve(listener); err != http.ErrServerClosed {
+		return fmt.Errorf("error when serving API: %v", err)
+	}
+	// Serve returns as soon as Shutdown is called, without waiting for the requests in progress.
+	<-shutdownDone
+	return nil
+}
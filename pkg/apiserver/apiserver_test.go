@@ -0,0 +1,70 @@
+// Copyright 2020 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apiserver
+
+import (
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/antoninbas/antrea-k8s-to-ddlog/pkg/view"
+)
+
+func TestRunListenError(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	s := NewServer(listener.Addr().String(), view.NewView())
+	assert.Error(t, s.Listen())
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	assert.Error(t, s.Run(stopCh))
+}
+
+func TestRunWaitsForRequests(t *testing.T) {
+	s := NewServer("127.0.0.1:0", view.NewView())
+	started := make(chan struct{})
+	release := make(chan struct{})
+	s.Handle("/block", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	}))
+	require.NoError(t, s.Listen())
+	stopCh := make(chan struct{})
+	runDone := make(chan error)
+	go func() { runDone <- s.Run(stopCh) }()
+
+	go http.Get("http://" + s.listener.Addr().String() + "/block")
+	<-started
+	close(stopCh)
+	// Run must not return while the request is in progress.
+	select {
+	case <-runDone:
+		t.Fatalf("Run returned before the request completed")
+	case <-time.After(100 * time.Millisecond):
+	}
+	close(release)
+	select {
+	case err := <-runDone:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Timeout when waiting for Run to return")
+	}
+}
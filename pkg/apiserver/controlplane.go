@@ -0,0 +1,400 @@
+// Copyright 2020 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apiserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/fields"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/klog"
+
+	networking "github.com/antoninbas/antrea-k8s-to-ddlog/pkg/apis/networking/v1beta1"
+	"github.com/antoninbas/antrea-k8s-to-ddlog/pkg/ddlogk8s"
+	"github.com/antoninbas/antrea-k8s-to-ddlog/pkg/view"
+)
+
+// controlplanePrefix is the path prefix for the controlplane API, which follows the Kubernetes API
+// conventions, so that "kubectl get --raw" can be used to query it.
+var controlplanePrefix = "/apis/" + networking.APIVersion + "/"
+
+const (
+	appliedToGroupsResource  = "appliedtogroups"
+	addressGroupsResource    = "addressgroups"
+	networkPoliciesResource  = "networkpolicies"
+	nodeNameField            = "nodeName"
+	watchParam               = "watch"
+	fieldSelectorParam       = "fieldSelector"
+	controlplaneContentType  = "application/json"
+	controlplaneStatusFailed = "Failure"
+)
+
+var resourceKinds = map[string]view.Kind{
+	appliedToGroupsResource: view.KindAppliedToGroup,
+	addressGroupsResource:   view.KindAddressGroup,
+	networkPoliciesResource: view.KindNetworkPolicy,
+}
+
+func ipStrToIPAddress(ip string) networking.IPAddress {
+	return networking.IPAddress(net.ParseIP(ip))
+}
+
+func cidrStrToIPNet(cidr string) (networking.IPNet, error) {
+	s := strings.Split(cidr, "/")
+	if len(s) != 2 {
+		return networking.IPNet{}, fmt.Errorf("invalid CIDR '%s'", cidr)
+	}
+	prefixLength, err := strconv.Atoi(s[1])
+	if err != nil {
+		return networking.IPNet{}, fmt.Errorf("invalid prefix length in CIDR '%s': %v", cidr, err)
+	}
+	return networking.IPNet{IP: ipStrToIPAddress(s[0]), PrefixLength: int32(prefixLength)}, nil
+}
+
+func toAppliedToGroup(group *view.AppliedToGroup) *networking.AppliedToGroup {
+	var pods []networking.GroupMemberPod
+	for _, podsByNode := range group.PodsByNode {
+		for _, pod := range podsByNode {
+			pods = append(pods, networking.GroupMemberPod{
+				Pod: &networking.PodReference{Name: pod.Name, Namespace: pod.Namespace},
+			})
+		}
+	}
+	sort.Slice(pods, func(i, j int) bool {
+		if pods[i].Pod.Namespace != pods[j].Pod.Namespace {
+			return pods[i].Pod.Namespace < pods[j].Pod.Namespace
+		}
+		return pods[i].Pod.Name < pods[j].Pod.Name
+	})
+	return &networking.AppliedToGroup{
+		TypeMeta:   metav1.TypeMeta{Kind: "AppliedToGroup", APIVersion: networking.APIVersion},
+		ObjectMeta: metav1.ObjectMeta{Name: group.Name},
+		Pods:       pods,
+	}
+}
+
+func toAddressGroup(group *view.AddressGroup) *networking.AddressGroup {
+	var pods []networking.GroupMemberPod
+	for _, address := range group.Addresses {
+		pods = append(pods, networking.GroupMemberPod{IP: ipStrToIPAddress(address)})
+	}
+	return &networking.AddressGroup{
+		TypeMeta:   metav1.TypeMeta{Kind: "AddressGroup", APIVersion: networking.APIVersion},
+		ObjectMeta: metav1.ObjectMeta{Name: group.Name},
+		Pods:       pods,
+	}
+}
+
+func toNetworkPolicyPeer(peer *ddlogk8s.InternalNetworkPolicyPeer) (networking.NetworkPolicyPeer, error) {
+	result := networking.NetworkPolicyPeer{AddressGroups: peer.AddressGroups}
+	for _, ipBlock := range peer.IPBlocks {
+		cidr, err := cidrStrToIPNet(ipBlock.CIDR)
+		if err != nil {
+			return result, err
+		}
+		block := networking.IPBlock{CIDR: cidr}
+		for _, except := range ipBlock.Except {
+			exceptNet, err := cidrStrToIPNet(except)
+			if err != nil {
+				return result, err
+			}
+			block.Except = append(block.Except, exceptNet)
+		}
+		result.IPBlocks = append(result.IPBlocks, block)
+	}
+	return result, nil
+}
+
+func toNetworkPolicy(policy *view.NetworkPolicy) (*networking.NetworkPolicy, error) {
+	result := &networking.NetworkPolicy{
+		TypeMeta: metav1.TypeMeta{Kind: "NetworkPolicy", APIVersion: networking.APIVersion},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      policy.Name,
+			Namespace: policy.Namespace,
+			UID:       policy.UID,
+		},
+		AppliedToGroups: policy.AppliedToGroups,
+	}
+	for i := range policy.Rules {
+		rule := &policy.Rules[i]
+		from, err := toNetworkPolicyPeer(&rule.From)
+		if err != nil {
+			return nil, err
+		}
+		to, err := toNetworkPolicyPeer(&rule.To)
+		if err != nil {
+			return nil, err
+		}
+		var services []networking.Service
+		for _, port := range rule.Services {
+			service := networking.Service{Port: port.Port}
+			if port.Protocol != nil {
+				protocol := networking.Protocol(*port.Protocol)
+				service.Protocol = &protocol
+			}
+			services = append(services, service)
+		}
+		result.Rules = append(result.Rules, networking.NetworkPolicyRule{
+			Direction: networking.Direction(rule.Direction),
+			From:      from,
+			To:        to,
+			Services:  services,
+		})
+	}
+	return result, nil
+}
+
+// toControlplaneObject converts an object from the view to the corresponding controlplane type.
+func toControlplaneObject(obj interface{}) (interface{}, error) {
+	switch o := obj.(type) {
+	case *view.AppliedToGroup:
+		return toAppliedToGroup(o), nil
+	case *view.AddressGroup:
+		return toAddressGroup(o), nil
+	case *view.NetworkPolicy:
+		return toNetworkPolicy(o)
+	}
+	return nil, fmt.Errorf("unexpected object type %T", obj)
+}
+
+func writeJSON(w http.ResponseWriter, code int, obj interface{}) {
+	w.Header().Set("Content-Type", controlplaneContentType)
+	w.WriteHeader(code)
+	if err := json.NewEncoder(w).Encode(obj); err != nil {
+		klog.Errorf("Error when writing response: %v", err)
+	}
+}
+
+// writeStatus writes an error as a metav1.Status object, like the Kubernetes apiserver does.
+func writeStatus(w http.ResponseWriter, code int, reason metav1.StatusReason, message string) {
+	writeJSON(w, code, &metav1.Status{
+		TypeMeta: metav1.TypeMeta{Kind: "Status", APIVersion: "v1"},
+		Status:   controlplaneStatusFailed,
+		Message:  message,
+		Reason:   reason,
+		Code:     int32(code),
+	})
+}
+
+// parseNodeName extracts the Node name from the fieldSelector query parameter, which is the only
+// field selector supported by the Antrea controlplane API.
+func parseNodeName(r *http.Request) (string, error) {
+	fieldSelector := r.URL.Query().Get(fieldSelectorParam)
+	if fieldSelector == "" {
+		return "", nil
+	}
+	selector, err := fields.ParseSelector(fieldSelector)
+	if err != nil {
+		return "", err
+	}
+	nodeName, ok := selector.RequiresExactMatch(nodeNameField)
+	if !ok || len(selector.Requirements()) != 1 {
+		return "", fmt.Errorf("only the '%s' field selector is supported", nodeNameField)
+	}
+	return nodeName, nil
+}
+
+func isWatch(r *http.Request) bool {
+	watch := r.URL.Query().Get(watchParam)
+	return watch == "true" || watch == "1"
+}
+
+func (s *Server) handleDiscovery(w http.ResponseWriter) {
+	verbs := metav1.Verbs{"get", "list", "watch"}
+	writeJSON(w, http.StatusOK, &metav1.APIResourceList{
+		TypeMeta:     metav1.TypeMeta{Kind: "APIResourceList", APIVersion: "v1"},
+		GroupVersion: networking.APIVersion,
+		APIResources: []metav1.APIResource{
+			{Name: appliedToGroupsResource, Namespaced: false, Kind: "AppliedToGroup", Verbs: verbs},
+			{Name: addressGroupsResource, Namespaced: false, Kind: "AddressGroup", Verbs: verbs},
+			{Name: networkPoliciesResource, Namespaced: true, Kind: "NetworkPolicy", Verbs: verbs},
+		},
+	})
+}
+
+// handleControlplane serves the controlplane API under /apis/networking.antrea.tanzu.vmware.com/v1beta1.
+// AppliedToGroups and AddressGroups are cluster-scoped, NetworkPolicies can be listed for all
+// Namespaces or under namespaces/<namespace>/, but can only be retrieved by name under
+// namespaces/<namespace>/, since names are only unique within a Namespace. Collections support the "watch" and
+// "fieldSelector=nodeName=<nodeName>" query parameters.
+func (s *Server) handleControlplane(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeStatus(w, http.StatusMethodNotAllowed, metav1.StatusReasonMethodNotAllowed, "only GET is supported")
+		return
+	}
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path, controlplanePrefix), "/")
+	if path == "" {
+		s.handleDiscovery(w)
+		return
+	}
+	parts := strings.Split(path, "/")
+
+	var namespace string
+	if parts[0] == "namespaces" {
+		if len(parts) < 3 || parts[2] != networkPoliciesResource {
+			writeStatus(w, http.StatusNotFound, metav1.StatusReasonNotFound, "the server could not find the requested resource")
+			return
+		}
+		namespace = parts[1]
+		parts = parts[2:]
+	}
+	kind, ok := resourceKinds[parts[0]]
+	if !ok || len(parts) > 2 {
+		writeStatus(w, http.StatusNotFound, metav1.StatusReasonNotFound, "the server could not find the requested resource")
+		return
+	}
+	nodeName, err := parseNodeName(r)
+	if err != nil {
+		writeStatus(w, http.StatusBadRequest, metav1.StatusReasonBadRequest, err.Error())
+		return
+	}
+
+	if len(parts) == 2 {
+		if kind == view.KindNetworkPolicy && namespace == "" {
+			writeStatus(w, http.StatusNotFound, metav1.StatusReasonNotFound,
+				fmt.Sprintf("%s \"%s\" must be requested under namespaces/<namespace>/", kind, parts[1]))
+			return
+		}
+		s.getObject(w, kind, namespace, parts[1])
+		return
+	}
+	if isWatch(r) {
+		s.watchObjects(w, r, kind, namespace, nodeName)
+		return
+	}
+	s.listObjects(w, kind, namespace, nodeName)
+}
+
+// objectKeys returns the keys of all the objects of the provided kind, optionally restricted to a
+// Namespace for NetworkPolicies.
+func (s *Server) objectKeys(kind view.Kind, namespace string) []objectKey {
+	var keys []objectKey
+	switch kind {
+	case view.KindAppliedToGroup:
+		for _, group := range s.view.ListAppliedToGroups() {
+			keys = append(keys, objectKey{kind: kind, name: group.Name})
+		}
+	case view.KindAddressGroup:
+		for _, group := range s.view.ListAddressGroups() {
+			keys = append(keys, objectKey{kind: kind, name: group.Name})
+		}
+	case view.KindNetworkPolicy:
+		for _, policy := range s.view.ListNetworkPolicies() {
+			if namespace == "" || policy.Namespace == namespace {
+				keys = append(keys, objectKey{kind: kind, name: string(policy.UID)})
+			}
+		}
+	}
+	return keys
+}
+
+func (s *Server) getObject(w http.ResponseWriter, kind view.Kind, namespace, name string) {
+	key := objectKey{kind: kind, name: name}
+	if kind == view.KindNetworkPolicy {
+		// NetworkPolicies are stored by UID in the view.
+		key.name = ""
+		for _, policy := range s.view.ListNetworkPolicies() {
+			if policy.Name == name && policy.Namespace == namespace {
+				key.name = string(policy.UID)
+				break
+			}
+		}
+	}
+	obj := newWatcher(s.view, "", kind).getObject(key)
+	if obj == nil {
+		writeStatus(w, http.StatusNotFound, metav1.StatusReasonNotFound, fmt.Sprintf("%s \"%s\" not found", kind, name))
+		return
+	}
+	result, err := toControlplaneObject(obj)
+	if err != nil {
+		writeStatus(w, http.StatusInternalServerError, metav1.StatusReasonInternalError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+func (s *Server) listObjects(w http.ResponseWriter, kind view.Kind, namespace, nodeName string) {
+	watcher := newWatcher(s.view, nodeName, kind)
+	listMeta := metav1.ListMeta{}
+	var list interface{}
+	switch kind {
+	case view.KindAppliedToGroup:
+		l := &networking.AppliedToGroupList{Items: []networking.AppliedToGroup{}}
+		l.TypeMeta = metav1.TypeMeta{Kind: "AppliedToGroupList", APIVersion: networking.APIVersion}
+		l.ListMeta = listMeta
+		for _, key := range s.objectKeys(kind, namespace) {
+			if obj := watcher.getObject(key); obj != nil {
+				l.Items = append(l.Items, *toAppliedToGroup(obj.(*view.AppliedToGroup)))
+			}
+		}
+		list = l
+	case view.KindAddressGroup:
+		l := &networking.AddressGroupList{Items: []networking.AddressGroup{}}
+		l.TypeMeta = metav1.TypeMeta{Kind: "AddressGroupList", APIVersion: networking.APIVersion}
+		l.ListMeta = listMeta
+		for _, key := range s.objectKeys(kind, namespace) {
+			if obj := watcher.getObject(key); obj != nil {
+				l.Items = append(l.Items, *toAddressGroup(obj.(*view.AddressGroup)))
+			}
+		}
+		list = l
+	case view.KindNetworkPolicy:
+		l := &networking.NetworkPolicyList{Items: []networking.NetworkPolicy{}}
+		l.TypeMeta = metav1.TypeMeta{Kind: "NetworkPolicyList", APIVersion: networking.APIVersion}
+		l.ListMeta = listMeta
+		for _, key := range s.objectKeys(kind, namespace) {
+			obj := watcher.getObject(key)
+			if obj == nil {
+				continue
+			}
+			policy, err := toNetworkPolicy(obj.(*view.NetworkPolicy))
+			if err != nil {
+				writeStatus(w, http.StatusInternalServerError, metav1.StatusReasonInternalError, err.Error())
+				return
+			}
+			l.Items = append(l.Items, *policy)
+		}
+		list = l
+	}
+	writeJSON(w, http.StatusOK, list)
+}
+
+// watchObjects streams metav1.WatchEvent objects, in the same format as the Kubernetes apiserver.
+func (s *Server) watchObjects(w http.ResponseWriter, r *http.Request, kind view.Kind, namespace, nodeName string) {
+	newWatcher(s.view, nodeName, kind).stream(w, r, func(event *watchEvent) (interface{}, error) {
+		if policy, ok := event.object.(*view.NetworkPolicy); ok && namespace != "" && policy.Namespace != namespace {
+			return nil, nil
+		}
+		obj, err := toControlplaneObject(event.object)
+		if err != nil {
+			return nil, err
+		}
+		raw, err := json.Marshal(obj)
+		if err != nil {
+			return nil, err
+		}
+		return &metav1.WatchEvent{
+			Type:   string(event.eventType),
+			Object: runtime.RawExtension{Raw: raw},
+		}, nil
+	})
+}
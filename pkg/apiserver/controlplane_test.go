@@ -0,0 +1,131 @@
+// Copyright 2020 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apiserver
+
+import (
+	"bufio"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	networkingv1 "k8s.io/api/networking/v1"
+
+	networking "github.com/antoninbas/antrea-k8s-to-ddlog/pkg/apis/networking/v1beta1"
+	"github.com/antoninbas/antrea-k8s-to-ddlog/pkg/ddlogk8s"
+	"github.com/antoninbas/antrea-k8s-to-ddlog/pkg/view"
+)
+
+func newTestView() *view.View {
+	v := view.NewView()
+	v.HandleOutput(&ddlogk8s.AppliedToGroup{Name: "appliedToGroup1"}, true)
+	v.HandleOutput(&ddlogk8s.AppliedToGroupSpan{AppliedToGroup: "appliedToGroup1", NodeName: "node-1"}, true)
+	v.HandleOutput(&ddlogk8s.AppliedToGroupPodsByNode{
+		AppliedToGroup: "appliedToGroup1",
+		NodeName:       "node-1",
+		Pods:           []ddlogk8s.PodReference{{Name: "pod1", Namespace: "ns1"}},
+	}, true)
+	v.HandleOutput(&ddlogk8s.AddressGroup{Name: "addressGroup1"}, true)
+	v.HandleOutput(&ddlogk8s.AddressGroupAddress{AddressGroup: "addressGroup1", Address: "10.0.0.1"}, true)
+	v.HandleOutput(&ddlogk8s.AddressGroupSpan{AddressGroup: "addressGroup1", NodeName: "node-1"}, true)
+	v.HandleOutput(&ddlogk8s.InternalNetworkPolicy{
+		UID:       "uid1",
+		Name:      "np1",
+		Namespace: "ns1",
+		Rules: []ddlogk8s.InternalNetworkPolicyRule{{
+			Direction: ddlogk8s.DirectionIn,
+			From: ddlogk8s.InternalNetworkPolicyPeer{
+				AddressGroups: []string{"addressGroup1"},
+				IPBlocks:      []networkingv1.IPBlock{{CIDR: "192.168.0.0/16", Except: []string{"192.168.1.0/24"}}},
+			},
+		}},
+		AppliedToGroups: []string{"appliedToGroup1"},
+	}, true)
+	v.HandleOutput(&ddlogk8s.NetworkPolicySpan{NetworkPolicy: "uid1", NodeName: "node-1"}, true)
+	return v
+}
+
+func getJSON(t *testing.T, url string, obj interface{}) int {
+	resp, err := http.Get(url)
+	require.Nil(t, err)
+	defer resp.Body.Close()
+	require.Nil(t, json.NewDecoder(resp.Body).Decode(obj))
+	return resp.StatusCode
+}
+
+func TestControlplaneGetAndList(t *testing.T) {
+	server := httptest.NewServer(NewServer("", newTestView()))
+	defer server.Close()
+	prefix := server.URL + "/apis/networking.antrea.tanzu.vmware.com/v1beta1"
+
+	var group networking.AppliedToGroup
+	require.Equal(t, http.StatusOK, getJSON(t, prefix+"/appliedtogroups/appliedToGroup1", &group))
+	assert.Equal(t, "AppliedToGroup", group.Kind)
+	assert.Equal(t, []networking.GroupMemberPod{{Pod: &networking.PodReference{Name: "pod1", Namespace: "ns1"}}}, group.Pods)
+
+	var addressGroups networking.AddressGroupList
+	require.Equal(t, http.StatusOK, getJSON(t, prefix+"/addressgroups", &addressGroups))
+	require.Len(t, addressGroups.Items, 1)
+	assert.True(t, net.ParseIP("10.0.0.1").Equal(net.IP(addressGroups.Items[0].Pods[0].IP)))
+
+	var policy networking.NetworkPolicy
+	require.Equal(t, http.StatusOK, getJSON(t, prefix+"/namespaces/ns1/networkpolicies/np1", &policy))
+	require.Len(t, policy.Rules, 1)
+	ipBlocks := policy.Rules[0].From.IPBlocks
+	require.Len(t, ipBlocks, 1)
+	assert.Equal(t, int32(16), ipBlocks[0].CIDR.PrefixLength)
+	assert.Equal(t, int32(24), ipBlocks[0].Except[0].PrefixLength)
+
+	var policies networking.NetworkPolicyList
+	require.Equal(t, http.StatusOK, getJSON(t, prefix+"/networkpolicies?fieldSelector=nodeName%3Dnode-2", &policies))
+	assert.Empty(t, policies.Items)
+
+	var status map[string]interface{}
+	assert.Equal(t, http.StatusNotFound, getJSON(t, prefix+"/namespaces/ns2/networkpolicies/np1", &status))
+	assert.Equal(t, http.StatusNotFound, getJSON(t, prefix+"/networkpolicies/np1", &status))
+	assert.Equal(t, http.StatusNotFound, getJSON(t, prefix+"/namespaces/ns1/appliedtogroups/appliedToGroup1", &status))
+	assert.Equal(t, http.StatusBadRequest, getJSON(t, prefix+"/networkpolicies?fieldSelector=foo%3Dbar", &status))
+}
+
+func TestControlplaneWatch(t *testing.T) {
+	v := newTestView()
+	server := httptest.NewServer(NewServer("", v))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/apis/networking.antrea.tanzu.vmware.com/v1beta1/addressgroups?watch=true&fieldSelector=nodeName%3Dnode-1")
+	require.Nil(t, err)
+	defer resp.Body.Close()
+	scanner := bufio.NewScanner(resp.Body)
+
+	type event struct {
+		Type   string                  `json:"type"`
+		Object networking.AddressGroup `json:"object"`
+	}
+	var e event
+	require.True(t, scanner.Scan())
+	require.Nil(t, json.Unmarshal(scanner.Bytes(), &e))
+	assert.Equal(t, "ADDED", e.Type)
+	assert.Equal(t, "addressGroup1", e.Object.Name)
+
+	v.HandleOutput(&ddlogk8s.AddressGroupSpan{AddressGroup: "addressGroup1", NodeName: "node-1"}, false)
+	require.True(t, scanner.Scan())
+	require.Nil(t, json.Unmarshal(scanner.Bytes(), &e))
+	assert.Equal(t, "DELETED", e.Type)
+	assert.Equal(t, "addressGroup1", e.Object.Name)
+}
@@ -0,0 +1,276 @@
+// Copyright 2020 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apiserver
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/workqueue"
+	"k8s.io/klog"
+
+	"github.com/antoninbas/antrea-k8s-to-ddlog/pkg/ddlogk8s"
+	"github.com/antoninbas/antrea-k8s-to-ddlog/pkg/view"
+)
+
+// EventType is the type of a watch event.
+type EventType string
+
+const (
+	EventAdded    EventType = "ADDED"
+	EventModified EventType = "MODIFIED"
+	EventDeleted  EventType = "DELETED"
+)
+
+// WatchEvent is sent to watch clients, as a stream of JSON objects, every time an object spanning
+// their Node is added, modified or deleted.
+type WatchEvent struct {
+	Type EventType `json:"type"`
+	Kind view.Kind `json:"kind"`
+	// Name is the object name for groups and the UID for NetworkPolicies.
+	Name string `json:"name"`
+	// Object is omitted for DELETED events.
+	Object interface{} `json:"object,omitempty"`
+}
+
+type objectKey struct {
+	kind view.Kind
+	name string
+}
+
+// watcher computes the events for a single watch client. Keys of objects which may have changed
+// are added to a workqueue from the view event handler, which means that the DDlog commit path is
+// never blocked by a slow client, and that changes to the same object are coalesced.
+type watcher struct {
+	view *view.View
+	// nodeName restricts the watch to the objects spanning that Node, unless it is empty.
+	nodeName string
+	// kind restricts the watch to a single kind of objects, unless it is empty.
+	kind  view.Kind
+	queue workqueue.Interface
+	// sent stores the last version of each object sent to the client, which lets us decide
+	// whether an event needs to be sent when an object changes.
+	sent map[objectKey]interface{}
+}
+
+// watchEvent is the internal representation of an event, before it is encoded for the client.
+type watchEvent struct {
+	eventType EventType
+	key       objectKey
+	// object is the new version of the object, or the last version sent to the client for
+	// DELETED events.
+	object interface{}
+}
+
+func newWatcher(v *view.View, nodeName string, kind view.Kind) *watcher {
+	return &watcher{
+		view:     v,
+		nodeName: nodeName,
+		kind:     kind,
+		queue:    workqueue.New(),
+		sent:     make(map[objectKey]interface{}),
+	}
+}
+
+func (w *watcher) enqueue(kind view.Kind, name string) {
+	if w.kind != "" && w.kind != kind {
+		return
+	}
+	w.queue.Add(objectKey{kind: kind, name: name})
+}
+
+// enqueueAll enqueues all the existing objects, to generate the initial ADDED events.
+func (w *watcher) enqueueAll() {
+	for _, group := range w.view.ListAppliedToGroups() {
+		w.enqueue(view.KindAppliedToGroup, group.Name)
+	}
+	for _, group := range w.view.ListAddressGroups() {
+		w.enqueue(view.KindAddressGroup, group.Name)
+	}
+	for _, policy := range w.view.ListNetworkPolicies() {
+		w.enqueue(view.KindNetworkPolicy, string(policy.UID))
+	}
+}
+
+// start registers the watcher with the view and enqueues all the existing objects. The returned
+// function must be called to unregister the watcher.
+func (w *watcher) start() func() {
+	// The handler must be registered before listing the existing objects, to ensure that no
+	// change is missed.
+	removeHandler := w.view.AddEventHandler(w.enqueue)
+	w.enqueueAll()
+	return func() {
+		removeHandler()
+		w.queue.ShutDown()
+	}
+}
+
+func spansNode(span []string, nodeName string) bool {
+	if nodeName == "" {
+		return true
+	}
+	for _, n := range span {
+		if n == nodeName {
+			return true
+		}
+	}
+	return false
+}
+
+// filterAppliedToGroup only keeps the Pods which are scheduled on the provided Node, if any.
+func filterAppliedToGroup(group *view.AppliedToGroup, nodeName string) *view.AppliedToGroup {
+	if nodeName == "" {
+		return group
+	}
+	pods := group.PodsByNode[nodeName]
+	group.PodsByNode = map[string][]ddlogk8s.PodReference{}
+	if len(pods) > 0 {
+		group.PodsByNode[nodeName] = pods
+	}
+	return group
+}
+
+// getObject returns the current version of the object as it should be seen by the client, or nil
+// if the object does not exist or does not span the watched Node. When watching a Node,
+// AppliedToGroups only include the Pods which are scheduled on the Node.
+func (w *watcher) getObject(key objectKey) interface{} {
+	switch key.kind {
+	case view.KindAppliedToGroup:
+		group, ok := w.view.GetAppliedToGroup(key.name)
+		if !ok || !spansNode(group.Span, w.nodeName) {
+			return nil
+		}
+		return filterAppliedToGroup(group, w.nodeName)
+	case view.KindAddressGroup:
+		group, ok := w.view.GetAddressGroup(key.name)
+		if !ok || !spansNode(group.Span, w.nodeName) {
+			return nil
+		}
+		return group
+	case view.KindNetworkPolicy:
+		policy, ok := w.view.GetNetworkPolicy(types.UID(key.name))
+		if !ok || !spansNode(policy.Span, w.nodeName) {
+			return nil
+		}
+		return policy
+	}
+	return nil
+}
+
+// nextEvent blocks until an object has changed and returns the corresponding event. It returns nil
+// if the object change is not relevant to the client, and false when the watcher is shut down.
+func (w *watcher) nextEvent() (*watchEvent, bool) {
+	item, quit := w.queue.Get()
+	if quit {
+		return nil, false
+	}
+	defer w.queue.Done(item)
+	key := item.(objectKey)
+
+	obj := w.getObject(key)
+	lastObj, sent := w.sent[key]
+	event := &watchEvent{key: key, object: obj}
+	switch {
+	case obj == nil && !sent:
+		return nil, true
+	case obj == nil && sent:
+		event.eventType = EventDeleted
+		event.object = lastObj
+		delete(w.sent, key)
+	case !sent:
+		event.eventType = EventAdded
+		w.sent[key] = obj
+	case !reflect.DeepEqual(obj, lastObj):
+		event.eventType = EventModified
+		w.sent[key] = obj
+	default:
+		return nil, true
+	}
+	return event, true
+}
+
+// stream runs the watcher until the request is terminated, and writes each event to the response
+// after encoding it with encodeEvent. Each encoded event is followed by a newline and the response
+// is flushed after each event. Events for which encodeEvent returns nil are skipped.
+func (w *watcher) stream(rw http.ResponseWriter, r *http.Request, encodeEvent func(*watchEvent) (interface{}, error)) {
+	flusher, ok := rw.(http.Flusher)
+	if !ok {
+		http.Error(rw, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	stop := w.start()
+	defer stop()
+	go func() {
+		<-r.Context().Done()
+		w.queue.ShutDown()
+	}()
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	encoder := json.NewEncoder(rw)
+	for {
+		event, ok := w.nextEvent()
+		if !ok {
+			return
+		}
+		if event == nil {
+			continue
+		}
+		encoded, err := encodeEvent(event)
+		if err != nil {
+			klog.Errorf("Error when encoding watch event: %v", err)
+			continue
+		}
+		if encoded == nil {
+			continue
+		}
+		if err := encoder.Encode(encoded); err != nil {
+			klog.Errorf("Error when sending watch event: %v", err)
+			return
+		}
+		flusher.Flush()
+	}
+}
+
+// handleWatch serves "GET /watch?node=<nodeName>". The response is a never-ending stream of JSON
+// WatchEvent objects, one per line, which starts with an ADDED event for each object currently
+// spanning the Node.
+func (s *Server) handleWatch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	nodeName := r.URL.Query().Get("node")
+	if nodeName == "" {
+		http.Error(w, "missing 'node' query parameter", http.StatusBadRequest)
+		return
+	}
+
+	klog.Infof("Starting watch for Node '%s'", nodeName)
+	defer klog.Infof("Stopping watch for Node '%s'", nodeName)
+
+	newWatcher(s.view, nodeName, "").stream(w, r, func(event *watchEvent) (interface{}, error) {
+		e := &WatchEvent{Type: event.eventType, Kind: event.key.kind, Name: event.key.name}
+		if event.eventType != EventDeleted {
+			e.Object = event.object
+		}
+		return e, nil
+	})
+}
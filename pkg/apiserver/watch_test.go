@@ -0,0 +1,145 @@
+// Copyright 2020 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package apiserver
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/antoninbas/antrea-k8s-to-ddlog/pkg/ddlogk8s"
+	"github.com/antoninbas/antrea-k8s-to-ddlog/pkg/view"
+)
+
+type rawWatchEvent struct {
+	Type   EventType       `json:"type"`
+	Kind   view.Kind       `json:"kind"`
+	Name   string          `json:"name"`
+	Object json.RawMessage `json:"object"`
+}
+
+func readEvent(t *testing.T, scanner *bufio.Scanner) *rawWatchEvent {
+	eventCh := make(chan *rawWatchEvent)
+	go func() {
+		if !scanner.Scan() {
+			close(eventCh)
+			return
+		}
+		var event rawWatchEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err == nil {
+			eventCh <- &event
+		}
+	}()
+	select {
+	case event, ok := <-eventCh:
+		require.True(t, ok, "watch stream closed")
+		return event
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "timeout when waiting for watch event")
+	}
+	return nil
+}
+
+func TestWatch(t *testing.T) {
+	v := view.NewView()
+	v.HandleOutput(&ddlogk8s.AddressGroup{Name: "addressGroup1"}, true)
+	v.HandleOutput(&ddlogk8s.AddressGroupSpan{AddressGroup: "addressGroup1", NodeName: "node-1"}, true)
+	// does not span node-1
+	v.HandleOutput(&ddlogk8s.AddressGroup{Name: "addressGroup2"}, true)
+	v.HandleOutput(&ddlogk8s.AddressGroupSpan{AddressGroup: "addressGroup2", NodeName: "node-2"}, true)
+
+	server := httptest.NewServer(NewServer("", v))
+	defer server.Close()
+
+	resp, err := http.Get(server.URL + "/watch?node=node-1")
+	require.Nil(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	scanner := bufio.NewScanner(resp.Body)
+
+	event := readEvent(t, scanner)
+	assert.Equal(t, EventAdded, event.Type)
+	assert.Equal(t, view.KindAddressGroup, event.Kind)
+	assert.Equal(t, "addressGroup1", event.Name)
+
+	v.HandleOutput(&ddlogk8s.AddressGroupAddress{AddressGroup: "addressGroup1", Address: "10.0.0.1"}, true)
+	event = readEvent(t, scanner)
+	assert.Equal(t, EventModified, event.Type)
+	var group view.AddressGroup
+	require.Nil(t, json.Unmarshal(event.Object, &group))
+	assert.Equal(t, []string{"10.0.0.1"}, group.Addresses)
+
+	// addressGroup2 now spans node-1
+	v.HandleOutput(&ddlogk8s.AddressGroupSpan{AddressGroup: "addressGroup2", NodeName: "node-1"}, true)
+	event = readEvent(t, scanner)
+	assert.Equal(t, EventAdded, event.Type)
+	assert.Equal(t, "addressGroup2", event.Name)
+
+	v.HandleOutput(&ddlogk8s.AddressGroupSpan{AddressGroup: "addressGroup1", NodeName: "node-1"}, false)
+	event = readEvent(t, scanner)
+	assert.Equal(t, EventDeleted, event.Type)
+	assert.Equal(t, "addressGroup1", event.Name)
+}
+
+func TestWatchAppliedToGroupPodsFiltered(t *testing.T) {
+	v := view.NewView()
+	v.HandleOutput(&ddlogk8s.AppliedToGroup{Name: "group1"}, true)
+	v.HandleOutput(&ddlogk8s.AppliedToGroupSpan{AppliedToGroup: "group1", NodeName: "node-1"}, true)
+	v.HandleOutput(&ddlogk8s.AppliedToGroupPodsByNode{
+		AppliedToGroup: "group1",
+		NodeName:       "node-1",
+		Pods:           []ddlogk8s.PodReference{{Name: "pod1", Namespace: "ns1"}},
+	}, true)
+	v.HandleOutput(&ddlogk8s.AppliedToGroupPodsByNode{
+		AppliedToGroup: "group1",
+		NodeName:       "node-2",
+		Pods:           []ddlogk8s.PodReference{{Name: "pod2", Namespace: "ns1"}},
+	}, true)
+
+	w := newWatcher(v, "node-1", "")
+	defer w.start()()
+	event, ok := w.nextEvent()
+	require.True(t, ok)
+	require.NotNil(t, event)
+	group := event.object.(*view.AppliedToGroup)
+	assert.Equal(t, map[string][]ddlogk8s.PodReference{
+		"node-1": {{Name: "pod1", Namespace: "ns1"}},
+	}, group.PodsByNode)
+
+	// A change to the Pods on another Node is not relevant.
+	v.HandleOutput(&ddlogk8s.AppliedToGroupPodsByNode{
+		AppliedToGroup: "group1",
+		NodeName:       "node-2",
+		Pods:           []ddlogk8s.PodReference{},
+	}, true)
+	event, ok = w.nextEvent()
+	require.True(t, ok)
+	assert.Nil(t, event)
+}
+
+func TestWatchMissingNode(t *testing.T) {
+	server := httptest.NewServer(NewServer("", view.NewView()))
+	defer server.Close()
+	resp, err := http.Get(server.URL + "/watch")
+	require.Nil(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
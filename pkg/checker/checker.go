@@ -0,0 +1,563 @@
+// Copyright 2020 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package checker continuously compares the DDlog output relations with the reference computation
+// from package reference, and reports all the divergences.
+package checker
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"time"
+
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/apimachinery/pkg/util/wait"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	networkinglisters "k8s.io/client-go/listers/networking/v1"
+	"k8s.io/klog"
+
+	"github.com/antoninbas/antrea-k8s-to-ddlog/pkg/ddlogk8s"
+	"github.com/antoninbas/antrea-k8s-to-ddlog/pkg/reference"
+	"github.com/antoninbas/antrea-k8s-to-ddlog/pkg/view"
+)
+
+// Divergence is a difference between the reference computation and the DDlog output for a given
+// NetworkPolicy.
+type Divergence struct {
+	UID       types.UID `json:"uid"`
+	Namespace string    `json:"namespace"`
+	Name      string    `json:"name"`
+	// Field is the part of the resolved NetworkPolicy which differs, e.g. "podsByNode" or
+	// "rules[1]". It is empty if the NetworkPolicy is missing on one side.
+	Field    string      `json:"field,omitempty"`
+	Expected interface{} `json:"expected"`
+	Actual   interface{} `json:"actual"`
+	// Inputs are the K8s objects involved in the divergence: the K8s NetworkPolicy, and the Pods
+	// (and their Namespaces) which are present on one side only.
+	Inputs *reference.Inputs `json:"inputs,omitempty"`
+}
+
+func (d *Divergence) key() string {
+	b, _ := json.Marshal(struct {
+		UID      types.UID
+		Field    string
+		Expected interface{}
+		Actual   interface{}
+	}{d.UID, d.Field, d.Expected, d.Actual})
+	return string(b)
+}
+
+func (d *Divergence) String() string {
+	b, err := json.Marshal(d)
+	if err != nil {
+		return fmt.Sprintf("divergence for NetworkPolicy %s/%s (%s): %v", d.Namespace, d.Name, d.UID, err)
+	}
+	return string(b)
+}
+
+// ResolveView returns the internal NetworkPolicies in the view, with their AppliedToGroups and
+// AddressGroups resolved, so that they can be compared with the reference computation.
+func ResolveView(v *view.View) map[types.UID]*reference.NetworkPolicy {
+	policies := make(map[types.UID]*reference.NetworkPolicy)
+	for _, np := range v.ListNetworkPolicies() {
+		podsByNode := make(map[string][]reference.PodReference)
+		for _, groupName := range np.AppliedToGroups {
+			group, ok := v.GetAppliedToGroup(groupName)
+			if !ok {
+				continue
+			}
+			for nodeName, pods := range group.PodsByNode {
+				for _, pod := range pods {
+					podsByNode[nodeName] = append(podsByNode[nodeName], reference.PodReference{Name: pod.Name, Namespace: pod.Namespace})
+				}
+			}
+		}
+		for _, pods := range podsByNode {
+			reference.SortPodReferences(pods)
+		}
+		rules := make([]reference.Rule, len(np.Rules))
+		for i := range np.Rules {
+			rules[i] = resolveRule(v, &np.Rules[i])
+		}
+		policies[np.UID] = &reference.NetworkPolicy{
+			UID:         np.UID,
+			Name:        np.Name,
+			Namespace:   np.Namespace,
+			PodsByNode:  podsByNode,
+			Span:        np.Span,
+			Rules:       rules,
+			PolicyTypes: np.PolicyTypes,
+		}
+	}
+	return policies
+}
+
+func resolveRule(v *view.View, rule *ddlogk8s.InternalNetworkPolicyRule) reference.Rule {
+	peer := &rule.From
+	if rule.Direction == ddlogk8s.DirectionOut {
+		peer = &rule.To
+	}
+	addresses := sets.NewString()
+	for _, groupName := range peer.AddressGroups {
+		groupAddresses, _ := v.ListAddressGroupAddresses(groupName)
+		addresses.Insert(groupAddresses...)
+	}
+	resolved := reference.Rule{
+		Direction: reference.Direction(rule.Direction),
+		IPBlocks:  peer.IPBlocks,
+		Services:  rule.Services,
+	}
+	if addresses.Len() > 0 {
+		resolved.Addresses = addresses.List()
+	}
+	return resolved
+}
+
+// normalize replaces empty slices with nil slices, so that both sides of the comparison can be
+// compared with reflect.DeepEqual.
+func normalize(np *reference.NetworkPolicy) {
+	if len(np.PodsByNode) == 0 {
+		np.PodsByNode = nil
+	}
+	if len(np.Span) == 0 {
+		np.Span = nil
+	}
+	if len(np.Rules) == 0 {
+		np.Rules = nil
+	}
+	if len(np.PolicyTypes) == 0 {
+		np.PolicyTypes = nil
+	}
+	for i := range np.Rules {
+		rule := &np.Rules[i]
+		if len(rule.Addresses) == 0 {
+			rule.Addresses = nil
+		}
+		if len(rule.IPBlocks) == 0 {
+			rule.IPBlocks = nil
+		}
+		for j := range rule.IPBlocks {
+			if len(rule.IPBlocks[j].Except) == 0 {
+				rule.IPBlocks[j].Except = nil
+			}
+		}
+		if len(rule.Services) == 0 {
+			rule.Services = nil
+		}
+	}
+}
+
+// Compare returns all the differences between the expected NetworkPolicies (computed by the
+// reference) and the actual ones (computed by DDlog). The Inputs field of the returned divergences
+// is not set. Divergences are sorted by UID.
+func Compare(expected, actual map[types.UID]*reference.NetworkPolicy) []*Divergence {
+	uids := make([]string, 0, len(expected)+len(actual))
+	for uid := range expected {
+		uids = append(uids, string(uid))
+	}
+	for uid := range actual {
+		if _, ok := expected[uid]; !ok {
+			uids = append(uids, string(uid))
+		}
+	}
+	sort.Strings(uids)
+
+	var divergences []*Divergence
+	for _, uid := range uids {
+		e, a := expected[types.UID(uid)], actual[types.UID(uid)]
+		if e == nil || a == nil {
+			np := e
+			if np == nil {
+				np = a
+			}
+			d := &Divergence{UID: np.UID, Namespace: np.Namespace, Name: np.Name}
+			// Assign explicitly to avoid storing typed nil pointers in the interfaces.
+			if e != nil {
+				d.Expected = e
+			}
+			if a != nil {
+				d.Actual = a
+			}
+			divergences = append(divergences, d)
+			continue
+		}
+		divergences = append(divergences, compareNetworkPolicies(e, a)...)
+	}
+	return divergences
+}
+
+func compareNetworkPolicies(e, a *reference.NetworkPolicy) []*Divergence {
+	normalize(e)
+	normalize(a)
+	var divergences []*Divergence
+	add := func(field string, expected, actual interface{}) {
+		if reflect.DeepEqual(expected, actual) {
+			return
+		}
+		divergences = append(divergences, &Divergence{
+			UID:       e.UID,
+			Namespace: e.Namespace,
+			Name:      e.Name,
+			Field:     field,
+			Expected:  expected,
+			Actual:    actual,
+		})
+	}
+	add("name", e.Name, a.Name)
+	add("namespace", e.Namespace, a.Namespace)
+	add("podsByNode", e.PodsByNode, a.PodsByNode)
+	add("span", e.Span, a.Span)
+	if len(e.Rules) != len(a.Rules) {
+		add("rules", e.Rules, a.Rules)
+	} else {
+		for i := range e.Rules {
+			add(fmt.Sprintf("rules[%d]", i), e.Rules[i], a.Rules[i])
+		}
+	}
+	add("policyTypes", e.PolicyTypes, a.PolicyTypes)
+	return divergences
+}
+
+// groupUse records how a DDlog group is used by the internal NetworkPolicies.
+type groupUse struct {
+	// policy is the first NetworkPolicy which uses the group, to which divergences are
+	// attributed.
+	policy *view.NetworkPolicy
+	// keys are the reference keys the group may correspond to. For an AppliedToGroup, it is the
+	// set of keys of the NetworkPolicies which use the group, and all of them must match. For an
+	// AddressGroup, it is the intersection of the keys of the rules which use the group, and one
+	// of them must match.
+	keys sets.String
+}
+
+func normalizeAppliedToGroup(g *reference.AppliedToGroup) *reference.AppliedToGroup {
+	if len(g.PodsByNode) == 0 {
+		g.PodsByNode = nil
+	}
+	if len(g.Span) == 0 {
+		g.Span = nil
+	}
+	return g
+}
+
+func normalizeAddressGroup(g *reference.AddressGroup) *reference.AddressGroup {
+	if len(g.Addresses) == 0 {
+		g.Addresses = nil
+	}
+	if len(g.Span) == 0 {
+		g.Span = nil
+	}
+	return g
+}
+
+func resolveAppliedToGroup(v *view.View, name string) *reference.AppliedToGroup {
+	group, ok := v.GetAppliedToGroup(name)
+	if !ok {
+		return nil
+	}
+	podsByNode := make(map[string][]reference.PodReference, len(group.PodsByNode))
+	for nodeName, pods := range group.PodsByNode {
+		for _, pod := range pods {
+			podsByNode[nodeName] = append(podsByNode[nodeName], reference.PodReference{Name: pod.Name, Namespace: pod.Namespace})
+		}
+	}
+	return normalizeAppliedToGroup(&reference.AppliedToGroup{PodsByNode: podsByNode, Span: group.Span})
+}
+
+func resolveAddressGroup(v *view.View, name string) *reference.AddressGroup {
+	group, ok := v.GetAddressGroup(name)
+	if !ok {
+		return nil
+	}
+	return normalizeAddressGroup(&reference.AddressGroup{Addresses: group.Addresses, Span: group.Span})
+}
+
+// isNil returns true if v is nil or holds a nil pointer, map, slice, function, channel or interface.
+func isNil(v interface{}) bool {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Invalid:
+		return true
+	case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Func, reflect.Chan, reflect.Interface:
+		return rv.IsNil()
+	}
+	return false
+}
+
+// CompareGroups returns all the differences between the expected groups (computed by the
+// reference) and the AppliedToGroups and AddressGroups in the view: their members and their span.
+// Since group names are chosen by DDlog, each group in the view is matched with the reference
+// through the NetworkPolicies which use it. Groups used by NetworkPolicies which are missing on
+// one side are ignored, since Compare already reports these NetworkPolicies. The Inputs field of
+// the returned divergences is not set.
+func CompareGroups(expected *reference.Groups, v *view.View) []*Divergence {
+	var divergences []*Divergence
+	add := func(np *view.NetworkPolicy, field string, expected, actual interface{}) {
+		d := &Divergence{UID: np.UID, Namespace: np.Namespace, Name: np.Name, Field: field, Expected: expected}
+		// Assign explicitly to avoid storing typed nil pointers in the interface.
+		if !isNil(actual) {
+			d.Actual = actual
+		}
+		divergences = append(divergences, d)
+	}
+	appliedToGroupUses := make(map[string]*groupUse)
+	addressGroupUses := make(map[string]*groupUse)
+	for _, np := range v.ListNetworkPolicies() {
+		policyGroups, ok := expected.Policies[np.UID]
+		if !ok {
+			continue
+		}
+		if len(np.AppliedToGroups) != 1 {
+			add(np, "appliedToGroups", []string{policyGroups.AppliedToGroup}, np.AppliedToGroups)
+		}
+		for _, name := range np.AppliedToGroups {
+			use, ok := appliedToGroupUses[name]
+			if !ok {
+				use = &groupUse{policy: np, keys: sets.NewString()}
+				appliedToGroupUses[name] = use
+			}
+			use.keys.Insert(policyGroups.AppliedToGroup)
+		}
+		if len(np.Rules) != len(policyGroups.AddressGroups) {
+			continue
+		}
+		for i := range np.Rules {
+			rule := &np.Rules[i]
+			peer := &rule.From
+			if rule.Direction == ddlogk8s.DirectionOut {
+				peer = &rule.To
+			}
+			names := sets.NewString(peer.AddressGroups...)
+			keys := policyGroups.AddressGroups[i]
+			if names.Len() != len(keys) {
+				add(np, fmt.Sprintf("rules[%d].addressGroups", i), keys, names.List())
+			}
+			for _, name := range names.List() {
+				use, ok := addressGroupUses[name]
+				if !ok {
+					addressGroupUses[name] = &groupUse{policy: np, keys: sets.NewString(keys...)}
+					continue
+				}
+				use.keys = use.keys.Intersection(sets.NewString(keys...))
+			}
+		}
+	}
+
+	for _, name := range sets.StringKeySet(appliedToGroupUses).List() {
+		use := appliedToGroupUses[name]
+		actual := resolveAppliedToGroup(v, name)
+		for _, key := range use.keys.List() {
+			e := normalizeAppliedToGroup(expected.AppliedToGroups[key])
+			if !reflect.DeepEqual(e, actual) {
+				add(use.policy, fmt.Sprintf("appliedToGroups[%s]", name), e, actual)
+			}
+		}
+	}
+	for _, name := range sets.StringKeySet(addressGroupUses).List() {
+		use := addressGroupUses[name]
+		actual := resolveAddressGroup(v, name)
+		candidates := make(map[string]*reference.AddressGroup, use.keys.Len())
+		matched := false
+		for _, key := range use.keys.List() {
+			e := normalizeAddressGroup(expected.AddressGroups[key])
+			candidates[key] = e
+			if reflect.DeepEqual(e, actual) {
+				matched = true
+			}
+		}
+		if !matched {
+			// If the group is used by rules with different peers, there is no candidate.
+			add(use.policy, fmt.Sprintf("addressGroups[%s]", name), candidates, actual)
+		}
+	}
+	return divergences
+}
+
+// Checker periodically compares the output of DDlog, as exposed by a view.View, with the reference
+// computation for the current K8s objects, as seen by the informers which feed DDlog.
+//
+// Since DDlog lags behind the informers, a divergence is only reported once it has been observed by
+// 2 consecutive checks. The check interval should therefore be large compared to the DDlog
+// transaction delay.
+type Checker struct {
+	podLister           corelisters.PodLister
+	namespaceLister     corelisters.NamespaceLister
+	networkPolicyLister networkinglisters.NetworkPolicyLister
+	view                *view.View
+	interval            time.Duration
+	// pending is the set of divergences observed by the last check.
+	pending map[string]bool
+	// reported is the set of divergences which have already been reported, so that each
+	// divergence is only reported once for as long as it persists.
+	reported map[string]bool
+}
+
+// NewChecker creates a new Checker.
+func NewChecker(
+	podLister corelisters.PodLister,
+	namespaceLister corelisters.NamespaceLister,
+	networkPolicyLister networkinglisters.NetworkPolicyLister,
+	v *view.View,
+	interval time.Duration,
+) *Checker {
+	return &Checker{
+		podLister:           podLister,
+		namespaceLister:     namespaceLister,
+		networkPolicyLister: networkPolicyLister,
+		view:                v,
+		interval:            interval,
+		pending:             make(map[string]bool),
+		reported:            make(map[string]bool),
+	}
+}
+
+func (c *Checker) listInputs() (*reference.Inputs, error) {
+	pods, err := c.podLister.List(labels.Everything())
+	if err != nil {
+		return nil, fmt.Errorf("error when listing Pods: %v", err)
+	}
+	namespaces, err := c.namespaceLister.List(labels.Everything())
+	if err != nil {
+		return nil, fmt.Errorf("error when listing Namespaces: %v", err)
+	}
+	networkPolicies, err := c.networkPolicyLister.List(labels.Everything())
+	if err != nil {
+		return nil, fmt.Errorf("error when listing NetworkPolicies: %v", err)
+	}
+	return &reference.Inputs{Pods: pods, Namespaces: namespaces, NetworkPolicies: networkPolicies}, nil
+}
+
+// Check runs the reference computation and compares it with the DDlog output. It returns all the
+// divergences found, with their offending inputs.
+func (c *Checker) Check() ([]*Divergence, error) {
+	inputs, err := c.listInputs()
+	if err != nil {
+		return nil, err
+	}
+	divergences := Compare(reference.Compute(inputs), ResolveView(c.view))
+	divergences = append(divergences, CompareGroups(reference.ComputeGroups(inputs), c.view)...)
+	for _, d := range divergences {
+		d.Inputs = offendingInputs(inputs, d)
+	}
+	return divergences, nil
+}
+
+// offendingInputs returns the subset of inputs involved in a divergence.
+func offendingInputs(inputs *reference.Inputs, d *Divergence) *reference.Inputs {
+	offending := &reference.Inputs{}
+	for _, np := range inputs.NetworkPolicies {
+		if np.UID == d.UID {
+			offending.NetworkPolicies = append(offending.NetworkPolicies, np)
+		}
+	}
+	podRefs, addresses := sets.NewString(), sets.NewString()
+	diffPods(d.Expected, d.Actual, podRefs, addresses)
+	namespaces := sets.NewString()
+	for _, pod := range inputs.Pods {
+		if podRefs.Has(pod.Namespace+"/"+pod.Name) || (pod.Status.PodIP != "" && addresses.Has(pod.Status.PodIP)) {
+			offending.Pods = append(offending.Pods, pod)
+			namespaces.Insert(pod.Namespace)
+		}
+	}
+	for _, ns := range inputs.Namespaces {
+		if namespaces.Has(ns.Name) {
+			offending.Namespaces = append(offending.Namespaces, ns)
+		}
+	}
+	return offending
+}
+
+// diffPods collects the Pod references and the addresses which appear on one side of a divergence
+// only.
+func diffPods(expected, actual interface{}, podRefs, addresses sets.String) {
+	collect := func(obj interface{}) (sets.String, sets.String) {
+		refs, addrs := sets.NewString(), sets.NewString()
+		addRule := func(rule *reference.Rule) {
+			addrs.Insert(rule.Addresses...)
+		}
+		addPodsByNode := func(podsByNode map[string][]reference.PodReference) {
+			for _, pods := range podsByNode {
+				for _, pod := range pods {
+					refs.Insert(pod.Namespace + "/" + pod.Name)
+				}
+			}
+		}
+		switch o := obj.(type) {
+		case *reference.NetworkPolicy:
+			addPodsByNode(o.PodsByNode)
+			for i := range o.Rules {
+				addRule(&o.Rules[i])
+			}
+		case map[string][]reference.PodReference:
+			addPodsByNode(o)
+		case []reference.Rule:
+			for i := range o {
+				addRule(&o[i])
+			}
+		case reference.Rule:
+			addRule(&o)
+		case *reference.AppliedToGroup:
+			addPodsByNode(o.PodsByNode)
+		case *reference.AddressGroup:
+			addrs.Insert(o.Addresses...)
+		case map[string]*reference.AddressGroup:
+			for _, group := range o {
+				addrs.Insert(group.Addresses...)
+			}
+		}
+		return refs, addrs
+	}
+	eRefs, eAddrs := collect(expected)
+	aRefs, aAddrs := collect(actual)
+	podRefs.Insert(eRefs.Difference(aRefs).Union(aRefs.Difference(eRefs)).UnsortedList()...)
+	addresses.Insert(eAddrs.Difference(aAddrs).Union(aAddrs.Difference(eAddrs)).UnsortedList()...)
+}
+
+func (c *Checker) runCheck() {
+	divergences, err := c.Check()
+	if err != nil {
+		klog.Errorf("Error when running equivalence check: %v", err)
+		return
+	}
+	current := make(map[string]bool, len(divergences))
+	for _, d := range divergences {
+		key := d.key()
+		current[key] = true
+		if !c.pending[key] || c.reported[key] {
+			continue
+		}
+		c.reported[key] = true
+		klog.Errorf("DDlog output diverges from reference: %s", d)
+	}
+	for key := range c.reported {
+		if !current[key] {
+			delete(c.reported, key)
+		}
+	}
+	c.pending = current
+	if len(divergences) == 0 {
+		klog.V(2).Infof("DDlog output matches reference")
+	}
+}
+
+// Run runs the equivalence check every interval until stopCh is closed.
+func (c *Checker) Run(stopCh <-chan struct{}) {
+	klog.Infof("Starting equivalence checker")
+	defer klog.Infof("Shutting down equivalence checker")
+	wait.Until(c.runCheck, c.interval, stopCh)
+}
@@ -0,0 +1,169 @@
+// Copyright 2020 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package checker
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+
+	"github.com/antoninbas/antrea-k8s-to-ddlog/pkg/ddlogk8s"
+	"github.com/antoninbas/antrea-k8s-to-ddlog/pkg/reference"
+	"github.com/antoninbas/antrea-k8s-to-ddlog/pkg/view"
+)
+
+func newTestChecker(t *testing.T, v *view.View, objects ...interface{}) *Checker {
+	informerFactory := informers.NewSharedInformerFactory(fake.NewSimpleClientset(), 0)
+	podInformer := informerFactory.Core().V1().Pods()
+	namespaceInformer := informerFactory.Core().V1().Namespaces()
+	networkPolicyInformer := informerFactory.Networking().V1().NetworkPolicies()
+	for _, obj := range objects {
+		var err error
+		switch obj.(type) {
+		case *v1.Pod:
+			err = podInformer.Informer().GetIndexer().Add(obj)
+		case *v1.Namespace:
+			err = namespaceInformer.Informer().GetIndexer().Add(obj)
+		case *networkingv1.NetworkPolicy:
+			err = networkPolicyInformer.Informer().GetIndexer().Add(obj)
+		}
+		require.NoError(t, err)
+	}
+	return NewChecker(podInformer.Lister(), namespaceInformer.Lister(), networkPolicyInformer.Lister(), v, 0)
+}
+
+func TestCheck(t *testing.T) {
+	pod1 := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns1", Name: "pod1", Labels: map[string]string{"app": "web"}},
+		Spec:       v1.PodSpec{NodeName: "node-1"},
+		Status:     v1.PodStatus{PodIP: "10.0.0.1"},
+	}
+	pod2 := &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns1", Name: "pod2", Labels: map[string]string{"app": "client"}},
+		Spec:       v1.PodSpec{NodeName: "node-1"},
+		Status:     v1.PodStatus{PodIP: "10.0.0.2"},
+	}
+	ns1 := &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: "ns1"}}
+	np1 := &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns1", Name: "np1", UID: "uid1"},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}},
+			Ingress: []networkingv1.NetworkPolicyIngressRule{{
+				From: []networkingv1.NetworkPolicyPeer{{PodSelector: &metav1.LabelSelector{}}},
+			}},
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+		},
+	}
+
+	v := view.NewView()
+	c := newTestChecker(t, v, pod1, pod2, ns1, np1)
+
+	// The view is empty: the policy is missing.
+	divergences, err := c.Check()
+	require.NoError(t, err)
+	require.Len(t, divergences, 1)
+	assert.Equal(t, "", divergences[0].Field)
+	assert.Nil(t, divergences[0].Actual)
+	assert.Equal(t, []*networkingv1.NetworkPolicy{np1}, divergences[0].Inputs.NetworkPolicies)
+
+	v.HandleOutput(&ddlogk8s.AppliedToGroup{Name: "atg1"}, true)
+	v.HandleOutput(&ddlogk8s.AppliedToGroupPodsByNode{
+		AppliedToGroup: "atg1",
+		NodeName:       "node-1",
+		Pods:           []ddlogk8s.PodReference{{Name: "pod1", Namespace: "ns1"}},
+	}, true)
+	v.HandleOutput(&ddlogk8s.AppliedToGroupSpan{AppliedToGroup: "atg1", NodeName: "node-1"}, true)
+	v.HandleOutput(&ddlogk8s.AddressGroup{Name: "ag1"}, true)
+	v.HandleOutput(&ddlogk8s.AddressGroupSpan{AddressGroup: "ag1", NodeName: "node-1"}, true)
+	// pod2 is missing from the AddressGroup.
+	v.HandleOutput(&ddlogk8s.AddressGroupAddress{AddressGroup: "ag1", Address: "10.0.0.1"}, true)
+	v.HandleOutput(&ddlogk8s.InternalNetworkPolicy{
+		UID:       "uid1",
+		Name:      "np1",
+		Namespace: "ns1",
+		Rules: []ddlogk8s.InternalNetworkPolicyRule{{
+			Direction: ddlogk8s.DirectionIn,
+			From:      ddlogk8s.InternalNetworkPolicyPeer{AddressGroups: []string{"ag1"}},
+		}},
+		AppliedToGroups: []string{"atg1"},
+		PolicyTypes:     []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+	}, true)
+	v.HandleOutput(&ddlogk8s.NetworkPolicySpan{NetworkPolicy: "uid1", NodeName: "node-1"}, true)
+
+	divergences, err = c.Check()
+	require.NoError(t, err)
+	require.Len(t, divergences, 2)
+	d := divergences[0]
+	assert.Equal(t, "rules[0]", d.Field)
+	assert.Equal(t, reference.Rule{Direction: reference.DirectionIn, Addresses: []string{"10.0.0.1", "10.0.0.2"}}, d.Expected)
+	assert.Equal(t, reference.Rule{Direction: reference.DirectionIn, Addresses: []string{"10.0.0.1"}}, d.Actual)
+	assert.Equal(t, []*v1.Pod{pod2}, d.Inputs.Pods)
+	assert.Equal(t, []*v1.Namespace{ns1}, d.Inputs.Namespaces)
+	d = divergences[1]
+	assert.Equal(t, "addressGroups[ag1]", d.Field)
+	assert.Equal(t, map[string]*reference.AddressGroup{
+		"namespace=ns1,pods=[]": {Addresses: []string{"10.0.0.1", "10.0.0.2"}, Span: []string{"node-1"}},
+	}, d.Expected)
+	assert.Equal(t, &reference.AddressGroup{Addresses: []string{"10.0.0.1"}, Span: []string{"node-1"}}, d.Actual)
+	assert.Equal(t, []*v1.Pod{pod2}, d.Inputs.Pods)
+
+	v.HandleOutput(&ddlogk8s.AddressGroupAddress{AddressGroup: "ag1", Address: "10.0.0.2"}, true)
+	divergences, err = c.Check()
+	require.NoError(t, err)
+	assert.Empty(t, divergences)
+
+	// The AppliedToGroup spans a Node on which none of its Pods run.
+	v.HandleOutput(&ddlogk8s.AppliedToGroupSpan{AppliedToGroup: "atg1", NodeName: "node-2"}, true)
+	divergences, err = c.Check()
+	require.NoError(t, err)
+	require.Len(t, divergences, 1)
+	d = divergences[0]
+	assert.Equal(t, "appliedToGroups[atg1]", d.Field)
+	assert.Equal(t, []string{"node-1"}, d.Expected.(*reference.AppliedToGroup).Span)
+	assert.Equal(t, []string{"node-1", "node-2"}, d.Actual.(*reference.AppliedToGroup).Span)
+}
+
+func TestRunCheckReportsPersistentDivergences(t *testing.T) {
+	np1 := &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "ns1", Name: "np1", UID: "uid1"},
+	}
+	c := newTestChecker(t, view.NewView(), np1)
+
+	c.runCheck()
+	assert.Len(t, c.pending, 1)
+	assert.Empty(t, c.reported)
+	c.runCheck()
+	assert.Len(t, c.reported, 1)
+}
+
+func TestIsNil(t *testing.T) {
+	var group *reference.AppliedToGroup
+	var names []string
+	assert.True(t, isNil(nil))
+	assert.True(t, isNil(group))
+	assert.True(t, isNil(names))
+	assert.False(t, isNil([]string{}))
+	assert.False(t, isNil(&reference.AppliedToGroup{}))
+	// Values of non-nillable kinds must not make isNil panic.
+	assert.False(t, isNil(0))
+	assert.False(t, isNil("group"))
+	assert.False(t, isNil(reference.AppliedToGroup{}))
+}
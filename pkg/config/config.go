@@ -0,0 +1,197 @@
+// Copyright 2020 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package config defines the versioned configuration file of antrea-convert. A configuration file
+// looks like this, and all the fields except apiVersion and kind are optional:
+//
+//	apiVersion: antrea-convert.antrea.io/v1alpha1
+//	kind: Configuration
+//	clientConnection:
+//	  kubeconfig: /path/to/kubeconfig
+//	  qps: 20
+//	  burst: 40
+//	controller:
+//	  maxUpdatesPerTransaction: 64
+//	  maxTransactionDelay: 200ms
+//	ddlog:
+//	  workers: auto
+//	logging:
+//	  verbosity: 2
+//	apiBindAddress: ":10349"
+//
+// The controller batching options (maxUpdatesPerTransaction and maxTransactionDelay) and the
+// logging section can be reloaded at runtime by sending SIGHUP to antrea-convert.
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/yaml"
+)
+
+const (
+	// APIVersion is the only supported version of the configuration file format.
+	APIVersion = "antrea-convert.antrea.io/v1alpha1"
+	// Kind is the kind of the configuration object.
+	Kind = "Configuration"
+)
+
+// Configuration is the configuration of the "run" command.
+type Configuration struct {
+	metav1.TypeMeta `json:",inline"`
+
+	ClientConnection ClientConnection `json:"clientConnection"`
+	Controller       Controller       `json:"controller"`
+	DDlog            DDlog            `json:"ddlog"`
+	Logging          Logging          `json:"logging"`
+
+	// APIBindAddress is the address on which to serve the output API. The API is disabled if
+	// empty.
+	APIBindAddress string `json:"apiBindAddress,omitempty"`
+	// SnapshotDir is the directory where snapshots of the DDlog input relations are written.
+	// Snapshots are disabled if empty.
+	SnapshotDir string `json:"snapshotDir,omitempty"`
+}
+
+// ClientConnection configures the connection to the Kubernetes API.
+type ClientConnection struct {
+	// Kubeconfig is the path to the kubeconfig file. If empty, the in-cluster configuration is
+	// used when running in a Pod.
+	Kubeconfig string `json:"kubeconfig,omitempty"`
+	// Context is the name of the kubeconfig context to use.
+	Context string `json:"context,omitempty"`
+	// Master overrides the address of the Kubernetes API server.
+	Master string  `json:"master,omitempty"`
+	QPS    float64 `json:"qps"`
+	Burst  int     `json:"burst"`
+	// ResyncPeriod is the period at which all the Pods, Namespaces and NetworkPolicies are
+	// re-processed. It defaults to 30s, and periodic resyncs are disabled if it is set to 0.
+	ResyncPeriod metav1.Duration `json:"resyncPeriod"`
+}
+
+// Controller configures the batching of input updates into DDlog transactions.
+type Controller struct {
+	InputWorkers             int             `json:"inputWorkers"`
+	MaxUpdatesPerTransaction int             `json:"maxUpdatesPerTransaction"`
+	MaxTransactionDelay      metav1.Duration `json:"maxTransactionDelay"`
+	MinRetryDelay            metav1.Duration `json:"minRetryDelay"`
+	MaxRetryDelay            metav1.Duration `json:"maxRetryDelay"`
+	// ShutdownTimeout bounds the time spent draining pending updates on shutdown, after which
+	// the in-flight transaction is rolled back.
+	ShutdownTimeout metav1.Duration `json:"shutdownTimeout"`
+}
+
+// DDlog configures the DDlog program.
+type DDlog struct {
+	// Workers is the number of DDlog worker threads, or "auto" to use one worker per available
+	// CPU, taking the CPU quota of the container into account.
+	Workers intstr.IntOrString `json:"workers"`
+}
+
+// Logging configures the klog logs.
+type Logging struct {
+	// Verbosity is the klog verbosity level. The -v flag takes precedence over it. When the
+	// configuration is reloaded, it is only applied if it changed, so that a verbosity selected
+	// with SIGUSR2 is preserved otherwise.
+	Verbosity *int32 `json:"verbosity,omitempty"`
+}
+
+// WorkersAuto selects one DDlog worker per available CPU.
+const WorkersAuto = "auto"
+
+// ParseWorkers parses a number of DDlog workers, which is either a positive integer or "auto".
+func ParseWorkers(s string) (intstr.IntOrString, error) {
+	if s == WorkersAuto {
+		return intstr.FromString(s), nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || n <= 0 {
+		return intstr.IntOrString{}, fmt.Errorf("invalid number of workers '%s', must be a positive integer or '%s'", s, WorkersAuto)
+	}
+	return intstr.FromInt(n), nil
+}
+
+// ResolveWorkers returns the number of DDlog workers, using the number of available CPUs for
+// "auto": the number of CPUs of the host, capped by the CPU quota of the cgroup if there is one.
+// workers must be valid.
+func ResolveWorkers(workers intstr.IntOrString) uint {
+	if workers.Type == intstr.String {
+		return uint(availableCPUs())
+	}
+	return uint(workers.IntVal)
+}
+
+// NewDefault returns a Configuration with all the fields set to their default value.
+func NewDefault() *Configuration {
+	return &Configuration{
+		TypeMeta: metav1.TypeMeta{APIVersion: APIVersion, Kind: Kind},
+		ClientConnection: ClientConnection{
+			QPS:          5,
+			Burst:        10,
+			ResyncPeriod: metav1.Duration{Duration: 30 * time.Second},
+		},
+		Controller: Controller{
+			InputWorkers:             1,
+			MaxUpdatesPerTransaction: 32,
+			MaxTransactionDelay:      metav1.Duration{Duration: 100 * time.Millisecond},
+			MinRetryDelay:            metav1.Duration{Duration: 1 * time.Second},
+			MaxRetryDelay:            metav1.Duration{Duration: 300 * time.Second},
+			ShutdownTimeout:          metav1.Duration{Duration: 10 * time.Second},
+		},
+		DDlog: DDlog{
+			Workers: intstr.FromInt(1),
+		},
+	}
+}
+
+// LoadFile reads a configuration file. Fields which are not set in the file have their default
+// value. The field values are not validated, so that they can be overridden first, see Validate.
+func LoadFile(name string) (*Configuration, error) {
+	b, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, fmt.Errorf("error when reading configuration file '%s': %v", name, err)
+	}
+	c, err := Load(b)
+	if err != nil {
+		return nil, fmt.Errorf("invalid configuration file '%s': %v", name, err)
+	}
+	return c, nil
+}
+
+// Load decodes a configuration. Unknown fields and unsupported versions are rejected.
+func Load(data []byte) (*Configuration, error) {
+	// The version is checked first, since the rest of the format depends on it.
+	var typeMeta metav1.TypeMeta
+	if err := yaml.Unmarshal(data, &typeMeta); err != nil {
+		return nil, err
+	}
+	if err := validateTypeMeta(&typeMeta).ToAggregate(); err != nil {
+		return nil, err
+	}
+	c := NewDefault()
+	if err := yaml.UnmarshalStrict(data, c); err != nil {
+		return nil, err
+	}
+	// IntOrString only overwrites the field for the type which is decoded, clear the default
+	// value of the other one.
+	if c.DDlog.Workers.Type == intstr.String {
+		c.DDlog.Workers = intstr.FromString(c.DDlog.Workers.StrVal)
+	}
+	return c, nil
+}
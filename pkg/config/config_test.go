@@ -0,0 +1,175 @@
+// Copyright 2020 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+func TestLoad(t *testing.T) {
+	c, err := Load([]byte(`
+apiVersion: antrea-convert.antrea.io/v1alpha1
+kind: Configuration
+clientConnection:
+  context: kind-kind
+  qps: 20
+controller:
+  maxTransactionDelay: 250ms
+ddlog:
+  workers: auto
+logging:
+  verbosity: 4
+apiBindAddress: ":10349"
+`))
+	require.NoError(t, err)
+	require.NoError(t, c.Validate())
+	expected := NewDefault()
+	expected.ClientConnection.Context = "kind-kind"
+	expected.ClientConnection.QPS = 20
+	expected.Controller.MaxTransactionDelay.Duration = 250 * time.Millisecond
+	expected.DDlog.Workers = intstr.FromString(WorkersAuto)
+	verbosity := int32(4)
+	expected.Logging.Verbosity = &verbosity
+	expected.APIBindAddress = ":10349"
+	assert.Equal(t, expected, c)
+}
+
+func TestLoadResyncPeriod(t *testing.T) {
+	c, err := Load([]byte("apiVersion: antrea-convert.antrea.io/v1alpha1\nkind: Configuration"))
+	require.NoError(t, err)
+	assert.Equal(t, 30*time.Second, c.ClientConnection.ResyncPeriod.Duration)
+	// 0 disables periodic resyncs explicitly.
+	c, err = Load([]byte("apiVersion: antrea-convert.antrea.io/v1alpha1\nkind: Configuration\nclientConnection:\n  resyncPeriod: 0s"))
+	require.NoError(t, err)
+	require.NoError(t, c.Validate())
+	assert.Equal(t, time.Duration(0), c.ClientConnection.ResyncPeriod.Duration)
+}
+
+func TestLoadAndValidateInvalid(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		input    string
+		expected []string
+	}{
+		{
+			name:     "missing version",
+			input:    "kind: Configuration",
+			expected: []string{"apiVersion: Required value"},
+		},
+		{
+			name:     "unsupported version",
+			input:    "apiVersion: antrea-convert.antrea.io/v1\nkind: Configuration",
+			expected: []string{`apiVersion: Unsupported value: "antrea-convert.antrea.io/v1"`},
+		},
+		{
+			name:     "unknown field",
+			input:    "apiVersion: antrea-convert.antrea.io/v1alpha1\nkind: Configuration\ncontroller:\n  maxUpdates: 10",
+			expected: []string{`unknown field "maxUpdates"`},
+		},
+		{
+			name: "invalid values",
+			input: `
+apiVersion: antrea-convert.antrea.io/v1alpha1
+kind: Configuration
+clientConnection:
+  burst: 0
+controller:
+  maxUpdatesPerTransaction: -1
+  minRetryDelay: 10s
+  maxRetryDelay: 5s
+ddlog:
+  workers: many
+logging:
+  verbosity: -1
+apiBindAddress: "10349"
+`,
+			expected: []string{
+				"clientConnection.burst: Invalid value: 0: must be greater than 0",
+				"controller.maxUpdatesPerTransaction: Invalid value: -1: must be greater than 0",
+				`controller.maxRetryDelay: Invalid value: "5s": must be greater than or equal to minRetryDelay`,
+				`ddlog.workers: Invalid value: "many": must be a positive integer or 'auto'`,
+				"logging.verbosity: Invalid value: -1: must be greater than or equal to 0",
+				`apiBindAddress: Invalid value: "10349"`,
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c, err := Load([]byte(tc.input))
+			if err == nil {
+				err = c.Validate()
+			}
+			require.Error(t, err)
+			for _, msg := range tc.expected {
+				assert.Contains(t, err.Error(), msg)
+			}
+		})
+	}
+}
+
+func TestWorkers(t *testing.T) {
+	dir, err := ioutil.TempDir("", "cgroup")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	defer func(root string) { cgroupRoot = root }(cgroupRoot)
+	// No CPU quota.
+	cgroupRoot = dir
+
+	workers, err := ParseWorkers("4")
+	require.NoError(t, err)
+	assert.Equal(t, uint(4), ResolveWorkers(workers))
+	workers, err = ParseWorkers(WorkersAuto)
+	require.NoError(t, err)
+	assert.Equal(t, uint(runtime.NumCPU()), ResolveWorkers(workers))
+	for _, s := range []string{"0", "-1", "many"} {
+		_, err := ParseWorkers(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestCgroupCPULimit(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		files    map[string]string
+		expected int
+	}{
+		{"no cgroup", nil, 0},
+		{"v2 no limit", map[string]string{"cpu.max": "max 100000\n"}, 0},
+		{"v2 limit", map[string]string{"cpu.max": "250000 100000\n"}, 3},
+		{"v1 no limit", map[string]string{"cpu/cpu.cfs_quota_us": "-1\n", "cpu/cpu.cfs_period_us": "100000\n"}, 0},
+		{"v1 limit", map[string]string{"cpu/cpu.cfs_quota_us": "200000\n", "cpu/cpu.cfs_period_us": "100000\n"}, 2},
+		{"v1 fraction", map[string]string{"cpu/cpu.cfs_quota_us": "50000\n", "cpu/cpu.cfs_period_us": "100000\n"}, 1},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			dir, err := ioutil.TempDir("", "cgroup")
+			require.NoError(t, err)
+			defer os.RemoveAll(dir)
+			for name, contents := range tc.files {
+				path := filepath.Join(dir, name)
+				require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+				require.NoError(t, ioutil.WriteFile(path, []byte(contents), 0644))
+			}
+			assert.Equal(t, tc.expected, cgroupCPULimit(dir))
+		})
+	}
+}
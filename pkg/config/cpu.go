@@ -0,0 +1,73 @@
+// Copyright 2020 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// cgroupRoot is where the cgroup filesystem is mounted. In a container, it only shows the cgroup
+// of the container, so the CPU quota can be read from the files at its root.
+var cgroupRoot = "/sys/fs/cgroup"
+
+// availableCPUs returns the number of CPUs available to the process: the number of CPUs, capped by
+// the CPU quota of the cgroup if there is one, e.g. when running in a Pod with a CPU limit.
+func availableCPUs() int {
+	cpus := runtime.NumCPU()
+	if limit := cgroupCPULimit(cgroupRoot); limit > 0 && limit < cpus {
+		cpus = limit
+	}
+	return cpus
+}
+
+// cgroupCPULimit returns the CPU quota of the cgroup, as a number of CPUs rounded up, or 0 if
+// there is no quota or it cannot be read. Both cgroup v2 (cpu.max) and cgroup v1
+// (cpu.cfs_quota_us and cpu.cfs_period_us) are supported.
+func cgroupCPULimit(root string) int {
+	if b, err := ioutil.ReadFile(filepath.Join(root, "cpu.max")); err == nil {
+		// The format is "<quota> <period>", where the quota is "max" if there is no limit.
+		fields := strings.Fields(string(b))
+		if len(fields) != 2 {
+			return 0
+		}
+		return cpuLimit(fields[0], fields[1])
+	}
+	quota, err := ioutil.ReadFile(filepath.Join(root, "cpu", "cpu.cfs_quota_us"))
+	if err != nil {
+		return 0
+	}
+	period, err := ioutil.ReadFile(filepath.Join(root, "cpu", "cpu.cfs_period_us"))
+	if err != nil {
+		return 0
+	}
+	return cpuLimit(strings.TrimSpace(string(quota)), strings.TrimSpace(string(period)))
+}
+
+// cpuLimit returns quota / period rounded up, or 0 if there is no quota ("max" or -1).
+func cpuLimit(quota, period string) int {
+	q, err := strconv.ParseInt(quota, 10, 64)
+	if err != nil || q <= 0 {
+		return 0
+	}
+	p, err := strconv.ParseInt(period, 10, 64)
+	if err != nil || p <= 0 {
+		return 0
+	}
+	return int((q + p - 1) / p)
+}
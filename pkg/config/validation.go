@@ -0,0 +1,106 @@
+// Copyright 2020 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"fmt"
+	"net"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+)
+
+func validateTypeMeta(typeMeta *metav1.TypeMeta) field.ErrorList {
+	var allErrs field.ErrorList
+	if typeMeta.APIVersion == "" {
+		allErrs = append(allErrs, field.Required(field.NewPath("apiVersion"), ""))
+	} else if typeMeta.APIVersion != APIVersion {
+		allErrs = append(allErrs, field.NotSupported(field.NewPath("apiVersion"), typeMeta.APIVersion, []string{APIVersion}))
+	}
+	if typeMeta.Kind == "" {
+		allErrs = append(allErrs, field.Required(field.NewPath("kind"), ""))
+	} else if typeMeta.Kind != Kind {
+		allErrs = append(allErrs, field.NotSupported(field.NewPath("kind"), typeMeta.Kind, []string{Kind}))
+	}
+	return allErrs
+}
+
+func validatePositive(path *field.Path, value int) field.ErrorList {
+	if value <= 0 {
+		return field.ErrorList{field.Invalid(path, value, "must be greater than 0")}
+	}
+	return nil
+}
+
+func validatePositiveDuration(path *field.Path, value metav1.Duration) field.ErrorList {
+	if value.Duration <= 0 {
+		return field.ErrorList{field.Invalid(path, value.Duration.String(), "must be greater than 0")}
+	}
+	return nil
+}
+
+func validateWorkers(path *field.Path, workers intstr.IntOrString) field.ErrorList {
+	if workers.Type == intstr.String {
+		if workers.StrVal != WorkersAuto {
+			return field.ErrorList{field.Invalid(path, workers.StrVal, fmt.Sprintf("must be a positive integer or '%s'", WorkersAuto))}
+		}
+		return nil
+	}
+	return validatePositive(path, int(workers.IntVal))
+}
+
+func validateClientConnection(c *ClientConnection, path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	if c.QPS <= 0 {
+		allErrs = append(allErrs, field.Invalid(path.Child("qps"), c.QPS, "must be greater than 0"))
+	}
+	allErrs = append(allErrs, validatePositive(path.Child("burst"), c.Burst)...)
+	if c.ResyncPeriod.Duration < 0 {
+		allErrs = append(allErrs, field.Invalid(path.Child("resyncPeriod"), c.ResyncPeriod.Duration.String(), "must be greater than or equal to 0"))
+	}
+	return allErrs
+}
+
+func validateController(c *Controller, path *field.Path) field.ErrorList {
+	var allErrs field.ErrorList
+	allErrs = append(allErrs, validatePositive(path.Child("inputWorkers"), c.InputWorkers)...)
+	allErrs = append(allErrs, validatePositive(path.Child("maxUpdatesPerTransaction"), c.MaxUpdatesPerTransaction)...)
+	allErrs = append(allErrs, validatePositiveDuration(path.Child("maxTransactionDelay"), c.MaxTransactionDelay)...)
+	allErrs = append(allErrs, validatePositiveDuration(path.Child("minRetryDelay"), c.MinRetryDelay)...)
+	allErrs = append(allErrs, validatePositiveDuration(path.Child("maxRetryDelay"), c.MaxRetryDelay)...)
+	allErrs = append(allErrs, validatePositiveDuration(path.Child("shutdownTimeout"), c.ShutdownTimeout)...)
+	if c.MaxRetryDelay.Duration < c.MinRetryDelay.Duration {
+		allErrs = append(allErrs, field.Invalid(path.Child("maxRetryDelay"), c.MaxRetryDelay.Duration.String(), "must be greater than or equal to minRetryDelay"))
+	}
+	return allErrs
+}
+
+// Validate returns an error listing all the invalid fields, or nil if the configuration is valid.
+func (c *Configuration) Validate() error {
+	allErrs := validateTypeMeta(&c.TypeMeta)
+	allErrs = append(allErrs, validateClientConnection(&c.ClientConnection, field.NewPath("clientConnection"))...)
+	allErrs = append(allErrs, validateController(&c.Controller, field.NewPath("controller"))...)
+	allErrs = append(allErrs, validateWorkers(field.NewPath("ddlog", "workers"), c.DDlog.Workers)...)
+	if c.Logging.Verbosity != nil && *c.Logging.Verbosity < 0 {
+		allErrs = append(allErrs, field.Invalid(field.NewPath("logging", "verbosity"), *c.Logging.Verbosity, "must be greater than or equal to 0"))
+	}
+	if c.APIBindAddress != "" {
+		if _, _, err := net.SplitHostPort(c.APIBindAddress); err != nil {
+			allErrs = append(allErrs, field.Invalid(field.NewPath("apiBindAddress"), c.APIBindAddress, err.Error()))
+		}
+	}
+	return allErrs.ToAggregate()
+}
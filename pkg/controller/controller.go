@@ -21,9 +21,10 @@ package controller
 import (
 	"context"
 	"fmt"
+	"sync"
 	"time"
 
-	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/apimachinery/pkg/api/errors"
 	coreinformers "k8s.io/client-go/informers/core/v1"
 	networkinginformers "k8s.io/client-go/informers/networking/v1"
 	clientset "k8s.io/client-go/kubernetes"
@@ -38,19 +39,120 @@ import (
 )
 
 const (
-	// Interval of synchronizing status from apiserver.
-	syncPeriod = 0
-
 	// How long to wait before retrying the processing of a change.
-	minRetryDelay = 1 * time.Second
-	maxRetryDelay = 300 * time.Second
+	defaultMinRetryDelay = 1 * time.Second
+	defaultMaxRetryDelay = 300 * time.Second
 
 	defaultInputWorkers = 1
 
-	maxUpdatesPerTransaction = 32
-	maxTransactionDelay      = 100 * time.Millisecond
+	defaultMaxUpdatesPerTransaction = 32
+	defaultMaxTransactionDelay      = 100 * time.Millisecond
+
+	defaultShutdownTimeout = 10 * time.Second
 )
 
+// Options are the tuning options of the Controller. The zero value of each field selects the
+// default value.
+type Options struct {
+	// ResyncPeriod is the period at which all the Pods, Namespaces and NetworkPolicies are
+	// re-processed. Periodic resyncs are disabled if it is 0.
+	ResyncPeriod time.Duration
+	// InputWorkers is the number of workers processing each of the Pod, Namespace and
+	// NetworkPolicy queues.
+	InputWorkers int
+	// MaxUpdatesPerTransaction is the maximum number of updates in a DDlog transaction.
+	MaxUpdatesPerTransaction int
+	// MaxTransactionDelay is the maximum time for which updates are batched before the DDlog
+	// transaction is committed.
+	MaxTransactionDelay time.Duration
+	// MinRetryDelay and MaxRetryDelay bound the exponential backoff used to retry the
+	// processing of a change.
+	MinRetryDelay time.Duration
+	MaxRetryDelay time.Duration
+	// ShutdownTimeout is the maximum time spent processing the queued changes and committing the
+	// pending updates once the Controller is stopped. When it expires, the in-flight transaction
+	// is rolled back and the remaining changes are dropped.
+	ShutdownTimeout time.Duration
+}
+
+func (o *Options) setDefaults() {
+	if o.InputWorkers == 0 {
+		o.InputWorkers = defaultInputWorkers
+	}
+	if o.MaxUpdatesPerTransaction == 0 {
+		o.MaxUpdatesPerTransaction = defaultMaxUpdatesPerTransaction
+	}
+	if o.MaxTransactionDelay == 0 {
+		o.MaxTransactionDelay = defaultMaxTransactionDelay
+	}
+	if o.MinRetryDelay == 0 {
+		o.MinRetryDelay = defaultMinRetryDelay
+	}
+	if o.MaxRetryDelay == 0 {
+		o.MaxRetryDelay = defaultMaxRetryDelay
+	}
+	if o.ShutdownTimeout == 0 {
+		o.ShutdownTimeout = defaultShutdownTimeout
+	}
+}
+
+// Update is a change to one of the DDlog input relations, for a Pod, Namespace or NetworkPolicy.
+type Update struct {
+	// Kind is the kind of the object: "Pod", "Namespace" or "NetworkPolicy".
+	Kind string
+	// Key is the namespace/name key of the object.
+	Key string
+	// Deleted is true if the object was deleted, in which case Command is a delete-by-key
+	// command. Otherwise, Command is an insert-or-update command.
+	Deleted bool
+	Command ddlog.Command
+	// Dump is the text representation of the record in Command, since Command itself cannot be
+	// inspected.
+	Dump string
+}
+
+func (u *Update) String() string {
+	if u.Deleted {
+		return fmt.Sprintf("DELETE %s %s", u.Kind, u.Key)
+	}
+	return fmt.Sprintf("UPDATE %s %s", u.Kind, u.Key)
+}
+
+// Sink receives the updates generated by the Controller, grouped in transactions. The methods are
+// always called from the same goroutine.
+type Sink interface {
+	StartTransaction() error
+	ApplyUpdates(updates ...Update) error
+	CommitTransaction() error
+	RollbackTransaction() error
+}
+
+// Program is the subset of the program.Program methods used by the Sink returned by
+// NewProgramSink.
+type Program interface {
+	StartTransaction() error
+	ApplyUpdates(commands ...ddlog.Command) error
+	CommitTransaction() error
+	RollbackTransaction() error
+}
+
+type programSink struct {
+	Program
+}
+
+// NewProgramSink returns a Sink which applies the updates to a DDlog program.
+func NewProgramSink(program Program) Sink {
+	return programSink{program}
+}
+
+func (s programSink) ApplyUpdates(updates ...Update) error {
+	commands := make([]ddlog.Command, len(updates))
+	for i := range updates {
+		commands[i] = updates[i].Command
+	}
+	return s.Program.ApplyUpdates(commands...)
+}
+
 // Controller is responsible for synchronizing the Namespaces and Pods
 // affected by a Network Policy.
 type Controller struct {
@@ -88,9 +190,17 @@ type Controller struct {
 
 	networkPolicyQueue workqueue.RateLimitingInterface
 
-	ddlogProgram *ddlog.Program
+	sink Sink
+
+	ddlogUpdatesCh chan Update
 
-	ddlogUpdatesCh chan ddlog.Command
+	// abortCh is closed when the shutdown timeout expires, to stop processing updates.
+	abortCh chan struct{}
+
+	// optionsMutex protects the batching options, which can be updated at runtime, see
+	// SetBatchingOptions.
+	optionsMutex sync.Mutex
+	options      Options
 }
 
 // NewController returns a new *Controller.
@@ -99,8 +209,13 @@ func NewController(
 	podInformer coreinformers.PodInformer,
 	namespaceInformer coreinformers.NamespaceInformer,
 	networkPolicyInformer networkinginformers.NetworkPolicyInformer,
-	ddlogProgram *ddlog.Program,
+	sink Sink,
+	options Options,
 ) *Controller {
+	options.setDefaults()
+	newQueue := func(name string) workqueue.RateLimitingInterface {
+		return workqueue.NewNamedRateLimitingQueue(workqueue.NewItemExponentialFailureRateLimiter(options.MinRetryDelay, options.MaxRetryDelay), name)
+	}
 	c := &Controller{
 		kubeClient:                kubeClient,
 		podInformer:               podInformer,
@@ -112,11 +227,13 @@ func NewController(
 		networkPolicyInformer:     networkPolicyInformer,
 		networkPolicyLister:       networkPolicyInformer.Lister(),
 		networkPolicyListerSynced: networkPolicyInformer.Informer().HasSynced,
-		ddlogProgram:              ddlogProgram,
-		podQueue:                  workqueue.NewNamedRateLimitingQueue(workqueue.NewItemExponentialFailureRateLimiter(minRetryDelay, maxRetryDelay), "pods"),
-		namespaceQueue:            workqueue.NewNamedRateLimitingQueue(workqueue.NewItemExponentialFailureRateLimiter(minRetryDelay, maxRetryDelay), "namespaces"),
-		networkPolicyQueue:        workqueue.NewNamedRateLimitingQueue(workqueue.NewItemExponentialFailureRateLimiter(minRetryDelay, maxRetryDelay), "networkPolicies"),
-		ddlogUpdatesCh:            make(chan ddlog.Command, maxUpdatesPerTransaction),
+		sink:                      sink,
+		podQueue:                  newQueue("pods"),
+		namespaceQueue:            newQueue("namespaces"),
+		networkPolicyQueue:        newQueue("networkPolicies"),
+		ddlogUpdatesCh:            make(chan Update, options.MaxUpdatesPerTransaction),
+		abortCh:                   make(chan struct{}),
+		options:                   options,
 	}
 	// Add handlers for Pod events.
 	podInformer.Informer().AddEventHandlerWithResyncPeriod(
@@ -125,7 +242,7 @@ func NewController(
 			UpdateFunc: func(oldObj, curObj interface{}) { c.enqueuePod(curObj) },
 			DeleteFunc: c.enqueuePod,
 		},
-		syncPeriod,
+		options.ResyncPeriod,
 	)
 	// Add handlers for Namespace events.
 	namespaceInformer.Informer().AddEventHandlerWithResyncPeriod(
@@ -134,7 +251,7 @@ func NewController(
 			UpdateFunc: func(oldObj, curObj interface{}) { c.enqueueNamespace(curObj) },
 			DeleteFunc: c.enqueueNamespace,
 		},
-		syncPeriod,
+		options.ResyncPeriod,
 	)
 	// Add handlers for NetworkPolicy events.
 	networkPolicyInformer.Informer().AddEventHandlerWithResyncPeriod(
@@ -143,13 +260,13 @@ func NewController(
 			UpdateFunc: func(oldObj, curObj interface{}) { c.enqueueNetworkPolicy(curObj) },
 			DeleteFunc: c.enqueueNetworkPolicy,
 		},
-		syncPeriod,
+		options.ResyncPeriod,
 	)
 	return c
 }
 
 func (c *Controller) enqueuePod(obj interface{}) {
-	key, err := cache.MetaNamespaceKeyFunc(obj)
+	key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
 	if err != nil {
 		klog.Errorf("Error when generating key for Pod: %v", err)
 		return
@@ -158,7 +275,7 @@ func (c *Controller) enqueuePod(obj interface{}) {
 }
 
 func (c *Controller) enqueueNamespace(obj interface{}) {
-	key, err := cache.MetaNamespaceKeyFunc(obj)
+	key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
 	if err != nil {
 		klog.Errorf("Error when generating key for Namespace: %v", err)
 		return
@@ -167,7 +284,7 @@ func (c *Controller) enqueueNamespace(obj interface{}) {
 }
 
 func (c *Controller) enqueueNetworkPolicy(obj interface{}) {
-	key, err := cache.MetaNamespaceKeyFunc(obj)
+	key, err := cache.DeletionHandlingMetaNamespaceKeyFunc(obj)
 	if err != nil {
 		klog.Errorf("Error when generating key for NetworkPolicy: %v", err)
 		return
@@ -175,64 +292,138 @@ func (c *Controller) enqueueNetworkPolicy(obj interface{}) {
 	c.networkPolicyQueue.Add(key)
 }
 
-func (c *Controller) Run(stopCh <-chan struct{}) {
-	defer c.podQueue.ShutDown()
-	defer c.namespaceQueue.ShutDown()
-	defer c.networkPolicyQueue.ShutDown()
+// SetBatchingOptions updates the maximum number of updates and the maximum delay of a DDlog
+// transaction. The new values are used starting with the next transaction. Zero values select the
+// defaults, as in Options.
+func (c *Controller) SetBatchingOptions(maxUpdatesPerTransaction int, maxTransactionDelay time.Duration) {
+	options := Options{MaxUpdatesPerTransaction: maxUpdatesPerTransaction, MaxTransactionDelay: maxTransactionDelay}
+	options.setDefaults()
+	c.optionsMutex.Lock()
+	defer c.optionsMutex.Unlock()
+	c.options.MaxUpdatesPerTransaction = options.MaxUpdatesPerTransaction
+	c.options.MaxTransactionDelay = options.MaxTransactionDelay
+}
 
+func (c *Controller) getBatchingOptions() (int, time.Duration) {
+	c.optionsMutex.Lock()
+	defer c.optionsMutex.Unlock()
+	return c.options.MaxUpdatesPerTransaction, c.options.MaxTransactionDelay
+}
+
+// Run starts the workers and blocks until stopCh is closed and the pending updates have been
+// drained, see Options.ShutdownTimeout. Changes received after stopCh is closed are ignored.
+func (c *Controller) Run(stopCh <-chan struct{}) {
 	klog.Info("Starting controller")
 	defer klog.Info("Shutting down controller")
 
 	klog.Info("Waiting for caches to sync for controller")
 	if !cache.WaitForCacheSync(stopCh, c.podListerSynced, c.namespaceListerSynced, c.networkPolicyListerSynced) {
 		klog.Error("Unable to sync caches for controller")
+		c.shutDownQueues()
 		return
 	}
 	klog.Info("Caches are synced for controller")
 
 	// one worker is in charge of all the transactions since DDLog does not support concurrent
 	// transactions
-	go c.generateTransactions(stopCh)
-
-	for i := 0; i < defaultInputWorkers; i++ {
-		go wait.Until(c.podWorker, time.Second, stopCh)
-		go wait.Until(c.namespaceWorker, time.Second, stopCh)
-		go wait.Until(c.networkPolicyWorker, time.Second, stopCh)
+	transactionsDone := make(chan struct{})
+	go func() {
+		defer close(transactionsDone)
+		c.generateTransactions()
+	}()
+
+	var workers sync.WaitGroup
+	for i := 0; i < c.options.InputWorkers; i++ {
+		for _, worker := range []func(){c.podWorker, c.namespaceWorker, c.networkPolicyWorker} {
+			workers.Add(1)
+			go func(worker func()) {
+				defer workers.Done()
+				worker()
+			}(worker)
+		}
 	}
 
 	<-stopCh
+	c.drain(&workers, transactionsDone)
+}
+
+func (c *Controller) shutDownQueues() {
+	c.podQueue.ShutDown()
+	c.namespaceQueue.ShutDown()
+	c.networkPolicyQueue.ShutDown()
+}
+
+// drain processes the changes which are already queued and commits the pending updates, within the
+// shutdown timeout. If the timeout expires, the in-flight transaction is rolled back. Note that a
+// DDlog commit in progress cannot be interrupted.
+func (c *Controller) drain(workers *sync.WaitGroup, transactionsDone <-chan struct{}) {
+	klog.Infof("Draining pending updates, for at most %v", c.options.ShutdownTimeout)
+	// Items which are already in the queues are still returned by Get after ShutDown, new items
+	// are ignored. The workers exit once the queues are empty.
+	c.shutDownQueues()
+	go func() {
+		workers.Wait()
+		// The workers are the only senders: generateTransactions returns once it has
+		// received all the updates.
+		close(c.ddlogUpdatesCh)
+	}()
+	timer := time.NewTimer(c.options.ShutdownTimeout)
+	defer timer.Stop()
+	select {
+	case <-transactionsDone:
+		klog.Info("All pending updates have been committed")
+	case <-timer.C:
+		klog.Warning("Timeout when draining pending updates, aborting")
+		close(c.abortCh)
+		<-transactionsDone
+	}
+}
+
+// sendUpdate sends an update to generateTransactions. It fails if the shutdown timeout has expired.
+func (c *Controller) sendUpdate(u Update) error {
+	select {
+	case c.ddlogUpdatesCh <- u:
+		return nil
+	case <-c.abortCh:
+		return fmt.Errorf("controller is shutting down")
+	}
 }
 
 // We assume that there cannot be transient issues with DDLog transactions, and so there is no point
-// in retrying.
-func (c *Controller) generateTransactions(stopCh <-chan struct{}) {
+// in retrying. generateTransactions returns when ddlogUpdatesCh is closed, after committing the
+// in-flight transaction, or when abortCh is closed, after rolling it back.
+func (c *Controller) generateTransactions() {
 	transactionSize := 0
 	parentCxt, parentCancel := context.WithCancel(context.Background())
 	defer parentCancel()
 
 	ctx := parentCxt
 	var cancel context.CancelFunc
+	// The batching options are read when the transaction is started.
+	var maxUpdatesPerTransaction int
+	var maxTransactionDelay time.Duration
 
 	commitTransaction := func() {
 		transactionSize = 0
 		ctx = parentCxt
-		if err := c.ddlogProgram.CommitTransaction(); err != nil {
+		if err := c.sink.CommitTransaction(); err != nil {
 			klog.Errorf("Error when committing DDLog transaction: %v", err)
 		}
 	}
 
-	handleCommand := func(cmd ddlog.Command) {
+	handleUpdate := func(u Update) {
 		klog.V(2).Infof("Handling command")
 		if transactionSize == 0 {
 			// start transaction
-			if err := c.ddlogProgram.StartTransaction(); err != nil {
+			if err := c.sink.StartTransaction(); err != nil {
 				klog.Errorf("Error when starting DDLog transaction: %v", err)
 				return
 			}
+			maxUpdatesPerTransaction, maxTransactionDelay = c.getBatchingOptions()
 			ctx, cancel = context.WithTimeout(parentCxt, maxTransactionDelay)
 		}
 		// add to transaction
-		if err := c.ddlogProgram.ApplyUpdates(cmd); err != nil {
+		if err := c.sink.ApplyUpdates(u); err != nil {
 			klog.Errorf("Error when applying updates with DDLog: %v", err)
 			return
 		}
@@ -243,13 +434,39 @@ func (c *Controller) generateTransactions(stopCh <-chan struct{}) {
 		}
 	}
 
+	abort := func() {
+		if transactionSize == 0 {
+			return
+		}
+		cancel()
+		klog.Warningf("Rolling back DDLog transaction with %d update(s)", transactionSize)
+		if err := c.sink.RollbackTransaction(); err != nil {
+			klog.Errorf("Error when rolling back DDLog transaction: %v", err)
+		}
+	}
+
 	for {
+		// Check abortCh first, as select picks a random case when several are ready.
 		select {
-		case cmd := <-c.ddlogUpdatesCh:
-			handleCommand(cmd)
+		case <-c.abortCh:
+			abort()
+			return
+		default:
+		}
+		select {
+		case u, ok := <-c.ddlogUpdatesCh:
+			if !ok {
+				if transactionSize > 0 {
+					cancel()
+					commitTransaction()
+				}
+				return
+			}
+			handleUpdate(u)
 		case <-ctx.Done():
 			commitTransaction()
-		case <-stopCh:
+		case <-c.abortCh:
+			abort()
 			return
 		}
 	}
@@ -292,18 +509,22 @@ func (c *Controller) processPod(key string) error {
 		return fmt.Errorf("error when extracting Pod namespace and name: %v", err)
 	}
 	pod, err := c.podLister.Pods(namespace).Get(name)
-	var cmd ddlog.Command
-	if err != nil { // deletion
+	u := Update{Kind: "Pod", Key: key}
+	if errors.IsNotFound(err) { // deletion
 		r := ddlogk8s.NewRecordPodKey(namespace, name)
-		klog.Infof("DELETE POD: %s", r.Dump())
-		cmd = ddlog.NewDeleteKeyCommand(ddlogk8s.PodTableID, r)
+		u.Dump = r.Dump()
+		klog.Infof("DELETE POD: %s", u.Dump)
+		u.Deleted = true
+		u.Command = ddlog.NewDeleteKeyCommand(ddlogk8s.PodTableID, r)
+	} else if err != nil {
+		return fmt.Errorf("error when getting Pod: %v", err)
 	} else {
 		r := ddlogk8s.NewRecordPod(pod)
-		klog.Infof("UPDATE POD: %s", r.Dump())
-		cmd = ddlog.NewInsertOrUpdateCommand(ddlogk8s.PodTableID, r)
+		u.Dump = r.Dump()
+		klog.Infof("UPDATE POD: %s", u.Dump)
+		u.Command = ddlog.NewInsertOrUpdateCommand(ddlogk8s.PodTableID, r)
 	}
-	c.ddlogUpdatesCh <- cmd
-	return nil
+	return c.sendUpdate(u)
 }
 
 func (c *Controller) processNextNamespace() bool {
@@ -324,18 +545,22 @@ func (c *Controller) processNextNamespace() bool {
 
 func (c *Controller) processNamespace(key string) error {
 	namespace, err := c.namespaceLister.Get(key)
-	var cmd ddlog.Command
-	if err != nil { // deletion
+	u := Update{Kind: "Namespace", Key: key}
+	if errors.IsNotFound(err) { // deletion
 		r := ddlogk8s.NewRecordNamespaceKey(key)
-		klog.Infof("DELETE NAMESPACE: %s", r.Dump())
-		cmd = ddlog.NewDeleteKeyCommand(ddlogk8s.NamespaceTableID, r)
+		u.Dump = r.Dump()
+		klog.Infof("DELETE NAMESPACE: %s", u.Dump)
+		u.Deleted = true
+		u.Command = ddlog.NewDeleteKeyCommand(ddlogk8s.NamespaceTableID, r)
+	} else if err != nil {
+		return fmt.Errorf("error when getting Namespace: %v", err)
 	} else {
 		r := ddlogk8s.NewRecordNamespace(namespace)
-		klog.Infof("UPDATE NAMESPACE: %s", r.Dump())
-		cmd = ddlog.NewInsertOrUpdateCommand(ddlogk8s.NamespaceTableID, r)
+		u.Dump = r.Dump()
+		klog.Infof("UPDATE NAMESPACE: %s", u.Dump)
+		u.Command = ddlog.NewInsertOrUpdateCommand(ddlogk8s.NamespaceTableID, r)
 	}
-	c.ddlogUpdatesCh <- cmd
-	return nil
+	return c.sendUpdate(u)
 }
 
 func (c *Controller) processNextNetworkPolicy() bool {
@@ -360,16 +585,20 @@ func (c *Controller) processNetworkPolicy(key string) error {
 		return fmt.Errorf("error when extracting NetworkPolicy namespace and name: %v", err)
 	}
 	networkPolicy, err := c.networkPolicyLister.NetworkPolicies(namespace).Get(name)
-	var cmd ddlog.Command
-	if err != nil { // deletion
+	u := Update{Kind: "NetworkPolicy", Key: key}
+	if errors.IsNotFound(err) { // deletion
 		r := ddlogk8s.NewRecordNetworkPolicyKey(namespace, name)
-		klog.Infof("DELETE NETWORKPOLICY: %s", r.Dump())
-		cmd = ddlog.NewDeleteKeyCommand(ddlogk8s.NetworkPolicyTableID, r)
+		u.Dump = r.Dump()
+		klog.Infof("DELETE NETWORKPOLICY: %s", u.Dump)
+		u.Deleted = true
+		u.Command = ddlog.NewDeleteKeyCommand(ddlogk8s.NetworkPolicyTableID, r)
+	} else if err != nil {
+		return fmt.Errorf("error when getting NetworkPolicy: %v", err)
 	} else {
 		r := ddlogk8s.NewRecordNetworkPolicy(networkPolicy)
-		klog.Infof("UPDATE NETWORKPOLICY: %s", r.Dump())
-		cmd = ddlog.NewInsertOrUpdateCommand(ddlogk8s.NetworkPolicyTableID, r)
+		u.Dump = r.Dump()
+		klog.Infof("UPDATE NETWORKPOLICY: %s", u.Dump)
+		u.Command = ddlog.NewInsertOrUpdateCommand(ddlogk8s.NetworkPolicyTableID, r)
 	}
-	c.ddlogUpdatesCh <- cmd
-	return nil
+	return c.sendUpdate(u)
 }
@@ -0,0 +1,179 @@
+// Copyright 2020 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShutdownDrainsUpdates(t *testing.T) {
+	// The transaction is never committed because of its size or age, only on shutdown.
+	h := newTestHarness(t, Options{MaxTransactionDelay: time.Hour}, newTestNamespaces(5)...)
+	h.sink.gate = make(chan struct{})
+	h.start()
+
+	// The first update is blocked, the other ones are still queued when stopping.
+	h.waitFor("UPDATE", 1)
+	close(h.stopCh)
+	time.Sleep(50 * time.Millisecond)
+	close(h.sink.gate)
+	h.waitForStop()
+
+	transactions := h.sink.transactions()
+	require.Len(t, transactions, 1)
+	assert.ElementsMatch(t, []string{
+		"UPDATE Namespace ns0", "UPDATE Namespace ns1", "UPDATE Namespace ns2", "UPDATE Namespace ns3", "UPDATE Namespace ns4",
+	}, transactions[0])
+}
+
+func TestShutdownTimeout(t *testing.T) {
+	h := newTestHarness(t, Options{MaxTransactionDelay: time.Hour, ShutdownTimeout: 50 * time.Millisecond}, newTestNamespaces(5)...)
+	h.sink.gate = make(chan struct{})
+	h.start()
+
+	h.waitFor("UPDATE", 1)
+	close(h.stopCh)
+	// Unblock the update in progress once the timeout has expired.
+	time.AfterFunc(200*time.Millisecond, func() { close(h.sink.gate) })
+	h.waitForStop()
+
+	events := h.sink.getEvents()
+	require.Len(t, events, 3)
+	assert.Equal(t, eventStart, events[0])
+	assert.Contains(t, events[1], "UPDATE Namespace")
+	assert.Equal(t, eventRollback, events[2])
+}
+
+func TestSetBatchingOptions(t *testing.T) {
+	h := newTestHarness(t, Options{MaxTransactionDelay: time.Hour}, newTestNamespaces(5)...)
+	h.controller.SetBatchingOptions(2, time.Hour)
+	h.start()
+
+	h.waitFor("UPDATE", 5)
+	h.stop()
+
+	transactions := h.sink.transactions()
+	require.Len(t, transactions, 3)
+	assert.Len(t, transactions[0], 2)
+	assert.Len(t, transactions[1], 2)
+	assert.Len(t, transactions[2], 1)
+}
+
+func TestAddUpdateDelete(t *testing.T) {
+	h := newTestHarness(t, Options{MaxTransactionDelay: 10 * time.Millisecond})
+	h.start()
+	defer h.stop()
+
+	ns := newTestNamespace("ns1")
+	pod := newTestPod("ns1", "pod1", map[string]string{"app": "web"})
+	np := newTestNetworkPolicy("ns1", "np1")
+	// Each change is committed before the next one is made, so that the order of the
+	// transactions is deterministic.
+	steps := []func(){
+		func() { h.create(ns) },
+		func() { h.create(pod) },
+		func() {
+			pod = pod.DeepCopy()
+			pod.Labels["app"] = "db"
+			h.update(pod)
+		},
+		func() { h.create(np) },
+		func() { h.delete(np) },
+		func() { h.delete(pod) },
+		func() { h.delete(ns) },
+	}
+	for i, step := range steps {
+		step()
+		h.waitForTransactions(i + 1)
+	}
+	assert.Equal(t, [][]string{
+		{"UPDATE Namespace ns1"},
+		{"UPDATE Pod ns1/pod1"},
+		{"UPDATE Pod ns1/pod1"},
+		{"UPDATE NetworkPolicy ns1/np1"},
+		{"DELETE NetworkPolicy ns1/np1"},
+		{"DELETE Pod ns1/pod1"},
+		{"DELETE Namespace ns1"},
+	}, h.sink.transactions())
+	// The second Pod update must carry the new labels.
+	dumps := h.sink.getDumps("UPDATE Pod ns1/pod1")
+	require.Len(t, dumps, 2)
+	assert.Contains(t, dumps[0], `"web"`)
+	assert.NotContains(t, dumps[1], `"web"`)
+	assert.Contains(t, dumps[1], `"db"`)
+}
+
+func TestTransactionDelay(t *testing.T) {
+	h := newTestHarness(t, Options{MaxTransactionDelay: 200 * time.Millisecond}, newTestNamespaces(3)...)
+	h.start()
+	defer h.stop()
+
+	// The initial objects are all received before the transaction delay expires.
+	transactions := h.waitForTransactions(1)
+	assert.ElementsMatch(t, []string{"UPDATE Namespace ns0", "UPDATE Namespace ns1", "UPDATE Namespace ns2"}, transactions[0])
+}
+
+func TestMaxUpdatesPerTransaction(t *testing.T) {
+	h := newTestHarness(t, Options{MaxUpdatesPerTransaction: 2, MaxTransactionDelay: time.Hour}, newTestNamespaces(5)...)
+	h.start()
+
+	transactions := h.waitForTransactions(2)
+	assert.Len(t, transactions[0], 2)
+	assert.Len(t, transactions[1], 2)
+	// The last update is only committed on shutdown.
+	h.waitFor("UPDATE", 5)
+	h.stop()
+	transactions = h.sink.transactions()
+	assert.Len(t, transactions, 3)
+	assert.Len(t, transactions[2], 1)
+}
+
+func TestTombstone(t *testing.T) {
+	pod := newTestPod("ns1", "pod1", nil)
+	h := newTestHarness(t, Options{MaxTransactionDelay: 10 * time.Millisecond}, pod)
+	h.start()
+	defer h.stop()
+
+	h.waitForTransactions(1)
+	h.deletePodWithTombstone(pod)
+	transactions := h.waitForTransactions(2)
+	assert.Equal(t, []string{"DELETE Pod ns1/pod1"}, transactions[1])
+}
+
+func TestResync(t *testing.T) {
+	// Resync periods shorter than 1s are not supported by the informers.
+	h := newTestHarness(t, Options{ResyncPeriod: time.Second, MaxTransactionDelay: 10 * time.Millisecond}, newTestNamespace("ns1"))
+	h.start()
+	defer h.stop()
+
+	// The Namespace is processed again on resync, although it has not changed.
+	h.waitFor("UPDATE Namespace ns1", 2)
+}
+
+func TestRetry(t *testing.T) {
+	h := newTestHarness(t, Options{MaxTransactionDelay: 10 * time.Millisecond, MinRetryDelay: 10 * time.Millisecond}, newTestPod("ns1", "pod1", nil))
+	lister := h.failPodGets(2)
+	h.start()
+	defer h.stop()
+
+	// An error when getting the Pod must not be mistaken for a deletion.
+	transactions := h.waitForTransactions(1)
+	assert.Equal(t, [][]string{{"UPDATE Pod ns1/pod1"}}, transactions)
+	assert.Equal(t, 3, lister.getCalls("ns1/pod1"))
+}
@@ -0,0 +1,329 @@
+// Copyright 2020 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package controller
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes/fake"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+const (
+	eventStart    = "START"
+	eventCommit   = "COMMIT"
+	eventRollback = "ROLLBACK"
+)
+
+// recordingSink is a Sink which records the transaction boundaries (eventStart, eventCommit and
+// eventRollback) and the updates (see Update.String), in order. The record dumps of the updates are
+// recorded separately, by update. When gate is not nil, ApplyUpdates blocks until gate is closed.
+type recordingSink struct {
+	mutex  sync.Mutex
+	events []string
+	dumps  map[string][]string
+	gate   chan struct{}
+}
+
+func (s *recordingSink) record(event string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.events = append(s.events, event)
+}
+
+// getDumps returns the record dumps of all the updates recorded as event, in order.
+func (s *recordingSink) getDumps(event string) []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]string(nil), s.dumps[event]...)
+}
+
+func (s *recordingSink) getEvents() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]string(nil), s.events...)
+}
+
+// count returns the number of events which start with prefix.
+func (s *recordingSink) count(prefix string) int {
+	n := 0
+	for _, e := range s.getEvents() {
+		if strings.HasPrefix(e, prefix) {
+			n++
+		}
+	}
+	return n
+}
+
+// transactions returns the updates of each committed transaction.
+func (s *recordingSink) transactions() [][]string {
+	var transactions [][]string
+	var current []string
+	for _, e := range s.getEvents() {
+		switch e {
+		case eventStart:
+			current = []string{}
+		case eventCommit:
+			transactions = append(transactions, current)
+		case eventRollback:
+			current = nil
+		default:
+			current = append(current, e)
+		}
+	}
+	return transactions
+}
+
+func (s *recordingSink) StartTransaction() error {
+	s.record(eventStart)
+	return nil
+}
+
+func (s *recordingSink) ApplyUpdates(updates ...Update) error {
+	for i := range updates {
+		event := updates[i].String()
+		s.record(event)
+		s.mutex.Lock()
+		if s.dumps == nil {
+			s.dumps = make(map[string][]string)
+		}
+		s.dumps[event] = append(s.dumps[event], updates[i].Dump)
+		s.mutex.Unlock()
+	}
+	if s.gate != nil {
+		<-s.gate
+	}
+	return nil
+}
+
+func (s *recordingSink) CommitTransaction() error {
+	s.record(eventCommit)
+	return nil
+}
+
+func (s *recordingSink) RollbackTransaction() error {
+	s.record(eventRollback)
+	return nil
+}
+
+// failingPodLister is a PodLister which fails the first failures Get calls for each Pod.
+type failingPodLister struct {
+	corelisters.PodLister
+	mutex    sync.Mutex
+	failures int
+	gets     map[string]int
+}
+
+func (l *failingPodLister) getCalls(key string) int {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	return l.gets[key]
+}
+
+func (l *failingPodLister) Pods(namespace string) corelisters.PodNamespaceLister {
+	return &failingPodNamespaceLister{l.PodLister.Pods(namespace), l, namespace}
+}
+
+type failingPodNamespaceLister struct {
+	corelisters.PodNamespaceLister
+	lister    *failingPodLister
+	namespace string
+}
+
+func (l *failingPodNamespaceLister) Get(name string) (*corev1.Pod, error) {
+	key := l.namespace + "/" + name
+	l.lister.mutex.Lock()
+	l.lister.gets[key]++
+	fail := l.lister.gets[key] <= l.lister.failures
+	l.lister.mutex.Unlock()
+	if fail {
+		return nil, fmt.Errorf("injected failure")
+	}
+	return l.PodNamespaceLister.Get(name)
+}
+
+// testHarness runs a Controller with a fake clientset and a recordingSink.
+type testHarness struct {
+	t               *testing.T
+	clientset       *fake.Clientset
+	informerFactory informers.SharedInformerFactory
+	controller      *Controller
+	sink            *recordingSink
+	stopCh          chan struct{}
+	done            chan struct{}
+}
+
+// newTestHarness creates a testHarness for the provided initial objects. The informer factory uses
+// options.ResyncPeriod, as in antrea-convert. The Controller is only started by start, so that it
+// can be customized first.
+func newTestHarness(t *testing.T, options Options, objects ...runtime.Object) *testHarness {
+	clientset := fake.NewSimpleClientset(objects...)
+	informerFactory := informers.NewSharedInformerFactory(clientset, options.ResyncPeriod)
+	sink := &recordingSink{}
+	c := NewController(
+		clientset,
+		informerFactory.Core().V1().Pods(),
+		informerFactory.Core().V1().Namespaces(),
+		informerFactory.Networking().V1().NetworkPolicies(),
+		sink,
+		options,
+	)
+	return &testHarness{
+		t:               t,
+		clientset:       clientset,
+		informerFactory: informerFactory,
+		controller:      c,
+		sink:            sink,
+		stopCh:          make(chan struct{}),
+		done:            make(chan struct{}),
+	}
+}
+
+// failPodGets makes the first failures Get calls fail for each Pod. It must be called before start.
+func (h *testHarness) failPodGets(failures int) *failingPodLister {
+	lister := &failingPodLister{PodLister: h.controller.podLister, failures: failures, gets: make(map[string]int)}
+	h.controller.podLister = lister
+	return lister
+}
+
+func (h *testHarness) start() {
+	h.informerFactory.Start(h.stopCh)
+	go func() {
+		defer close(h.done)
+		h.controller.Run(h.stopCh)
+	}()
+}
+
+// stop stops the Controller and waits for Run to return.
+func (h *testHarness) stop() {
+	close(h.stopCh)
+	h.waitForStop()
+}
+
+// waitForStop waits for Run to return, once stopCh has been closed.
+func (h *testHarness) waitForStop() {
+	select {
+	case <-h.done:
+	case <-time.After(5 * time.Second):
+		h.t.Fatalf("Timeout when waiting for the controller to stop")
+	}
+}
+
+func (h *testHarness) create(obj runtime.Object) {
+	var err error
+	switch obj := obj.(type) {
+	case *corev1.Pod:
+		_, err = h.clientset.CoreV1().Pods(obj.Namespace).Create(obj)
+	case *corev1.Namespace:
+		_, err = h.clientset.CoreV1().Namespaces().Create(obj)
+	case *networkingv1.NetworkPolicy:
+		_, err = h.clientset.NetworkingV1().NetworkPolicies(obj.Namespace).Create(obj)
+	default:
+		h.t.Fatalf("Unsupported object type %T", obj)
+	}
+	require.NoError(h.t, err)
+}
+
+func (h *testHarness) update(obj runtime.Object) {
+	var err error
+	switch obj := obj.(type) {
+	case *corev1.Pod:
+		_, err = h.clientset.CoreV1().Pods(obj.Namespace).Update(obj)
+	case *corev1.Namespace:
+		_, err = h.clientset.CoreV1().Namespaces().Update(obj)
+	case *networkingv1.NetworkPolicy:
+		_, err = h.clientset.NetworkingV1().NetworkPolicies(obj.Namespace).Update(obj)
+	default:
+		h.t.Fatalf("Unsupported object type %T", obj)
+	}
+	require.NoError(h.t, err)
+}
+
+func (h *testHarness) delete(obj runtime.Object) {
+	var err error
+	switch obj := obj.(type) {
+	case *corev1.Pod:
+		err = h.clientset.CoreV1().Pods(obj.Namespace).Delete(obj.Name, &metav1.DeleteOptions{})
+	case *corev1.Namespace:
+		err = h.clientset.CoreV1().Namespaces().Delete(obj.Name, &metav1.DeleteOptions{})
+	case *networkingv1.NetworkPolicy:
+		err = h.clientset.NetworkingV1().NetworkPolicies(obj.Namespace).Delete(obj.Name, &metav1.DeleteOptions{})
+	default:
+		h.t.Fatalf("Unsupported object type %T", obj)
+	}
+	require.NoError(h.t, err)
+}
+
+// deletePodWithTombstone simulates a Pod deletion which was missed by the informer's watch: the Pod
+// is removed from the informer's store and the Controller receives a DeletedFinalStateUnknown
+// tombstone, as it would after a relist.
+func (h *testHarness) deletePodWithTombstone(pod *corev1.Pod) {
+	key, err := cache.MetaNamespaceKeyFunc(pod)
+	require.NoError(h.t, err)
+	require.NoError(h.t, h.informerFactory.Core().V1().Pods().Informer().GetIndexer().Delete(pod))
+	h.controller.enqueuePod(cache.DeletedFinalStateUnknown{Key: key, Obj: pod})
+}
+
+// waitFor waits until the number of recorded events which start with prefix is at least n.
+func (h *testHarness) waitFor(prefix string, n int) {
+	err := wait.PollImmediate(10*time.Millisecond, 5*time.Second, func() (bool, error) {
+		return h.sink.count(prefix) >= n, nil
+	})
+	require.NoError(h.t, err, "Timeout when waiting for %d '%s' event(s), got: %v", n, prefix, h.sink.getEvents())
+}
+
+// waitForTransactions waits until n transactions have been committed and returns them.
+func (h *testHarness) waitForTransactions(n int) [][]string {
+	h.waitFor(eventCommit, n)
+	return h.sink.transactions()
+}
+
+func newTestNamespace(name string) *corev1.Namespace {
+	return &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name}}
+}
+
+// newTestNamespaces returns n Namespaces named ns0 to ns<n-1>.
+func newTestNamespaces(n int) []runtime.Object {
+	var objects []runtime.Object
+	for i := 0; i < n; i++ {
+		objects = append(objects, newTestNamespace(fmt.Sprintf("ns%d", i)))
+	}
+	return objects
+}
+
+func newTestPod(namespace, name string, labels map[string]string) *corev1.Pod {
+	return &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name, Labels: labels}}
+}
+
+func newTestNetworkPolicy(namespace, name string) *networkingv1.NetworkPolicy {
+	return &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name},
+		Spec: networkingv1.NetworkPolicySpec{
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+		},
+	}
+}
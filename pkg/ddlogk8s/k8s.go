@@ -545,3 +545,19 @@ func NewRecordNetworkPolicyKey(namespace, name string) ddlog.Record {
 	rName := ddlog.NewRecordString(name)
 	return ddlog.NewRecordPair(rNamespace, rName)
 }
+
+// NewInsertOrUpdateCommands returns the commands which insert or update the provided Namespaces,
+// Pods and NetworkPolicies, in this order.
+func NewInsertOrUpdateCommands(namespaces []*v1.Namespace, pods []*v1.Pod, networkPolicies []*networkingv1.NetworkPolicy) []ddlog.Command {
+	var cmds []ddlog.Command
+	for _, ns := range namespaces {
+		cmds = append(cmds, ddlog.NewInsertOrUpdateCommand(NamespaceTableID, NewRecordNamespace(ns)))
+	}
+	for _, pod := range pods {
+		cmds = append(cmds, ddlog.NewInsertOrUpdateCommand(PodTableID, NewRecordPod(pod)))
+	}
+	for _, np := range networkPolicies {
+		cmds = append(cmds, ddlog.NewInsertOrUpdateCommand(NetworkPolicyTableID, NewRecordNetworkPolicy(np)))
+	}
+	return cmds
+}
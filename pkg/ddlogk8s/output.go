@@ -0,0 +1,394 @@
+package ddlogk8s
+
+import (
+	"fmt"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/vmware/differential-datalog/go/pkg/ddlog"
+)
+
+// The types below are the Go representations of the records in the DDlog output relations. Each
+// output relation R(f1, f2, ...) produces struct records with constructor R and one field per
+// relation column, in declaration order.
+
+// PodReference identifies a Pod by name and Namespace.
+type PodReference struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+}
+
+// AppliedToGroup is a record of relation AppliedToGroup(name: string).
+type AppliedToGroup struct {
+	Name string `json:"name"`
+}
+
+// AppliedToGroupPodsByNode is a record of relation
+// AppliedToGroupPodsByNode(appliedToGroup: string, nodeName: string, pods: Set<PodReference>).
+type AppliedToGroupPodsByNode struct {
+	AppliedToGroup string         `json:"appliedToGroup"`
+	NodeName       string         `json:"nodeName"`
+	Pods           []PodReference `json:"pods"`
+}
+
+// AppliedToGroupSpan is a record of relation AppliedToGroupSpan(appliedToGroup: string, nodeName: string).
+type AppliedToGroupSpan struct {
+	AppliedToGroup string `json:"appliedToGroup"`
+	NodeName       string `json:"nodeName"`
+}
+
+// AddressGroup is a record of relation AddressGroup(name: string).
+type AddressGroup struct {
+	Name string `json:"name"`
+}
+
+// AddressGroupAddress is a record of relation AddressGroupAddress(addressGroup: string, address: string).
+type AddressGroupAddress struct {
+	AddressGroup string `json:"addressGroup"`
+	Address      string `json:"address"`
+}
+
+// AddressGroupSpan is a record of relation AddressGroupSpan(addressGroup: string, nodeName: string).
+type AddressGroupSpan struct {
+	AddressGroup string `json:"addressGroup"`
+	NodeName     string `json:"nodeName"`
+}
+
+// Direction is the direction of an internal NetworkPolicy rule.
+type Direction string
+
+const (
+	DirectionIn  Direction = "In"
+	DirectionOut Direction = "Out"
+)
+
+// InternalNetworkPolicyPeer is a record of type
+// NetworkPolicyPeer{addressGroups: Vec<string>, ipBlocks: Vec<k8spolicy.IPBlock>}.
+type InternalNetworkPolicyPeer struct {
+	AddressGroups []string               `json:"addressGroups,omitempty"`
+	IPBlocks      []networkingv1.IPBlock `json:"ipBlocks,omitempty"`
+}
+
+// InternalNetworkPolicyRule is a record of type NetworkPolicyRule{direction: Direction, from:
+// NetworkPolicyPeer, to: NetworkPolicyPeer, services: Vec<k8spolicy.NetworkPolicyPort>}.
+type InternalNetworkPolicyRule struct {
+	Direction Direction                        `json:"direction"`
+	From      InternalNetworkPolicyPeer        `json:"from"`
+	To        InternalNetworkPolicyPeer        `json:"to"`
+	Services  []networkingv1.NetworkPolicyPort `json:"services,omitempty"`
+}
+
+// InternalNetworkPolicy is a record of relation NetworkPolicy(uid: k8spolicy.UID, name: string,
+// namespace: string, rules: Vec<NetworkPolicyRule>, appliedToGroups: Vec<string>, policyTypes:
+// Vec<k8spolicy.PolicyType>). The UID is the UID of the K8s NetworkPolicy it was computed from.
+type InternalNetworkPolicy struct {
+	UID             types.UID                   `json:"uid"`
+	Name            string                      `json:"name"`
+	Namespace       string                      `json:"namespace"`
+	Rules           []InternalNetworkPolicyRule `json:"rules"`
+	AppliedToGroups []string                    `json:"appliedToGroups"`
+	PolicyTypes     []networkingv1.PolicyType   `json:"policyTypes"`
+}
+
+// NetworkPolicySpan is a record of relation NetworkPolicySpan(networkPolicy: k8spolicy.UID, nodeName: string).
+type NetworkPolicySpan struct {
+	NetworkPolicy types.UID `json:"networkPolicy"`
+	NodeName      string    `json:"nodeName"`
+}
+
+// recordToElements returns the elements of a vector or set record.
+func recordToElements(record ddlog.Record) ([]ddlog.Record, error) {
+	if record.IsVector() {
+		rVector := record.AsVector()
+		elements := make([]ddlog.Record, rVector.Size())
+		for i := 0; i < rVector.Size(); i++ {
+			elements[i] = rVector.At(i)
+		}
+		return elements, nil
+	}
+	if record.IsSet() {
+		rSet := record.AsSet()
+		elements := make([]ddlog.Record, rSet.Size())
+		for i := 0; i < rSet.Size(); i++ {
+			elements[i] = rSet.At(i)
+		}
+		return elements, nil
+	}
+	return nil, fmt.Errorf("record is neither a vector nor a set")
+}
+
+func recordToStrings(record ddlog.Record) ([]string, error) {
+	elements, err := recordToElements(record)
+	if err != nil {
+		return nil, err
+	}
+	values := make([]string, len(elements))
+	for i, e := range elements {
+		if values[i], err = e.ToStringSafe(); err != nil {
+			return nil, err
+		}
+	}
+	return values, nil
+}
+
+// recordToStringPair decodes the first two fields of a struct record, which must be strings. Most
+// of the span and membership relations have this shape.
+func recordToStringPair(record ddlog.Record) (string, string, error) {
+	r, err := record.AsStructSafe()
+	if err != nil {
+		return "", "", err
+	}
+	first, err := r.At(0).ToStringSafe()
+	if err != nil {
+		return "", "", err
+	}
+	second, err := r.At(1).ToStringSafe()
+	if err != nil {
+		return "", "", err
+	}
+	return first, second, nil
+}
+
+func RecordToPodReference(record ddlog.Record) (*PodReference, error) {
+	name, namespace, err := recordToStringPair(record)
+	if err != nil {
+		return nil, err
+	}
+	return &PodReference{Name: name, Namespace: namespace}, nil
+}
+
+func RecordToAppliedToGroup(record ddlog.Record) (*AppliedToGroup, error) {
+	r, err := record.AsStructSafe()
+	if err != nil {
+		return nil, err
+	}
+	name, err := r.At(0).ToStringSafe()
+	if err != nil {
+		return nil, err
+	}
+	return &AppliedToGroup{Name: name}, nil
+}
+
+func RecordToAppliedToGroupPodsByNode(record ddlog.Record) (*AppliedToGroupPodsByNode, error) {
+	groupName, nodeName, err := recordToStringPair(record)
+	if err != nil {
+		return nil, err
+	}
+	r, err := record.AsStructSafe()
+	if err != nil {
+		return nil, err
+	}
+	rPods, err := recordToElements(r.At(2))
+	if err != nil {
+		return nil, err
+	}
+	pods := make([]PodReference, len(rPods))
+	for i, rPod := range rPods {
+		pod, err := RecordToPodReference(rPod)
+		if err != nil {
+			return nil, err
+		}
+		pods[i] = *pod
+	}
+	return &AppliedToGroupPodsByNode{
+		AppliedToGroup: groupName,
+		NodeName:       nodeName,
+		Pods:           pods,
+	}, nil
+}
+
+func RecordToAppliedToGroupSpan(record ddlog.Record) (*AppliedToGroupSpan, error) {
+	groupName, nodeName, err := recordToStringPair(record)
+	if err != nil {
+		return nil, err
+	}
+	return &AppliedToGroupSpan{AppliedToGroup: groupName, NodeName: nodeName}, nil
+}
+
+func RecordToAddressGroup(record ddlog.Record) (*AddressGroup, error) {
+	r, err := record.AsStructSafe()
+	if err != nil {
+		return nil, err
+	}
+	name, err := r.At(0).ToStringSafe()
+	if err != nil {
+		return nil, err
+	}
+	return &AddressGroup{Name: name}, nil
+}
+
+func RecordToAddressGroupAddress(record ddlog.Record) (*AddressGroupAddress, error) {
+	groupName, address, err := recordToStringPair(record)
+	if err != nil {
+		return nil, err
+	}
+	return &AddressGroupAddress{AddressGroup: groupName, Address: address}, nil
+}
+
+func RecordToAddressGroupSpan(record ddlog.Record) (*AddressGroupSpan, error) {
+	groupName, nodeName, err := recordToStringPair(record)
+	if err != nil {
+		return nil, err
+	}
+	return &AddressGroupSpan{AddressGroup: groupName, NodeName: nodeName}, nil
+}
+
+func RecordToInternalNetworkPolicyPeer(record ddlog.Record) (*InternalNetworkPolicyPeer, error) {
+	r, err := record.AsStructSafe()
+	if err != nil {
+		return nil, err
+	}
+	addressGroups, err := recordToStrings(r.At(0))
+	if err != nil {
+		return nil, err
+	}
+	rIPBlocks, err := recordToElements(r.At(1))
+	if err != nil {
+		return nil, err
+	}
+	var ipBlocks []networkingv1.IPBlock
+	for _, rIPBlock := range rIPBlocks {
+		ipBlocks = append(ipBlocks, *RecordToIPBlock(rIPBlock))
+	}
+	return &InternalNetworkPolicyPeer{
+		AddressGroups: addressGroups,
+		IPBlocks:      ipBlocks,
+	}, nil
+}
+
+func RecordToInternalNetworkPolicyRule(record ddlog.Record) (*InternalNetworkPolicyRule, error) {
+	r, err := record.AsStructSafe()
+	if err != nil {
+		return nil, err
+	}
+	rDirection, err := r.At(0).AsStructSafe()
+	if err != nil {
+		return nil, err
+	}
+	var direction Direction
+	switch rDirection.Name() {
+	case "DirectionIn":
+		direction = DirectionIn
+	case "DirectionOut":
+		direction = DirectionOut
+	default:
+		return nil, fmt.Errorf("unknown rule direction '%s'", rDirection.Name())
+	}
+	from, err := RecordToInternalNetworkPolicyPeer(r.At(1))
+	if err != nil {
+		return nil, err
+	}
+	to, err := RecordToInternalNetworkPolicyPeer(r.At(2))
+	if err != nil {
+		return nil, err
+	}
+	rServices, err := recordToElements(r.At(3))
+	if err != nil {
+		return nil, err
+	}
+	var services []networkingv1.NetworkPolicyPort
+	for _, rService := range rServices {
+		services = append(services, *RecordToNetworkPolicyPort(rService))
+	}
+	return &InternalNetworkPolicyRule{
+		Direction: direction,
+		From:      *from,
+		To:        *to,
+		Services:  services,
+	}, nil
+}
+
+func RecordToInternalNetworkPolicy(record ddlog.Record) (*InternalNetworkPolicy, error) {
+	r, err := record.AsStructSafe()
+	if err != nil {
+		return nil, err
+	}
+	name, err := r.At(1).ToStringSafe()
+	if err != nil {
+		return nil, err
+	}
+	namespace, err := r.At(2).ToStringSafe()
+	if err != nil {
+		return nil, err
+	}
+	rRules, err := recordToElements(r.At(3))
+	if err != nil {
+		return nil, err
+	}
+	rules := make([]InternalNetworkPolicyRule, len(rRules))
+	for i, rRule := range rRules {
+		rule, err := RecordToInternalNetworkPolicyRule(rRule)
+		if err != nil {
+			return nil, err
+		}
+		rules[i] = *rule
+	}
+	appliedToGroups, err := recordToStrings(r.At(4))
+	if err != nil {
+		return nil, err
+	}
+	rPolicyTypes, err := recordToElements(r.At(5))
+	if err != nil {
+		return nil, err
+	}
+	policyTypes := make([]networkingv1.PolicyType, len(rPolicyTypes))
+	for i, rPolicyType := range rPolicyTypes {
+		rPolicyTypeStruct, err := rPolicyType.AsStructSafe()
+		if err != nil {
+			return nil, err
+		}
+		switch rPolicyTypeStruct.Name() {
+		case "k8spolicy.PolicyTypeIngress":
+			policyTypes[i] = networkingv1.PolicyTypeIngress
+		case "k8spolicy.PolicyTypeEgress":
+			policyTypes[i] = networkingv1.PolicyTypeEgress
+		default:
+			return nil, fmt.Errorf("unknown policy type '%s'", rPolicyTypeStruct.Name())
+		}
+	}
+	return &InternalNetworkPolicy{
+		UID:             RecordToUID(r.At(0)),
+		Name:            name,
+		Namespace:       namespace,
+		Rules:           rules,
+		AppliedToGroups: appliedToGroups,
+		PolicyTypes:     policyTypes,
+	}, nil
+}
+
+func RecordToNetworkPolicySpan(record ddlog.Record) (*NetworkPolicySpan, error) {
+	r, err := record.AsStructSafe()
+	if err != nil {
+		return nil, err
+	}
+	nodeName, err := r.At(1).ToStringSafe()
+	if err != nil {
+		return nil, err
+	}
+	return &NetworkPolicySpan{NetworkPolicy: RecordToUID(r.At(0)), NodeName: nodeName}, nil
+}
+
+// RecordToOutput decodes a record received from DDlog for the provided output table into the
+// corresponding Go type (e.g. *AppliedToGroup for AppliedToGroupTableID).
+func RecordToOutput(tableID ddlog.TableID, record ddlog.Record) (interface{}, error) {
+	switch tableID {
+	case AppliedToGroupTableID:
+		return RecordToAppliedToGroup(record)
+	case AppliedToGroupPodsByNodeTableID:
+		return RecordToAppliedToGroupPodsByNode(record)
+	case AppliedToGroupSpanTableID:
+		return RecordToAppliedToGroupSpan(record)
+	case AddressGroupTableID:
+		return RecordToAddressGroup(record)
+	case AddressGroupAddressTableID:
+		return RecordToAddressGroupAddress(record)
+	case AddressGroupSpanTableID:
+		return RecordToAddressGroupSpan(record)
+	case NetworkPolicyOutTableID:
+		return RecordToInternalNetworkPolicy(record)
+	case NetworkPolicyOutSpanTableID:
+		return RecordToNetworkPolicySpan(record)
+	}
+	return nil, fmt.Errorf("unknown output table %d", tableID)
+}
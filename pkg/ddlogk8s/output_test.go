@@ -0,0 +1,98 @@
+package ddlogk8s
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/types"
+
+	"github.com/vmware/differential-datalog/go/pkg/ddlog"
+)
+
+func TestAppliedToGroupPodsByNode(t *testing.T) {
+	rPods := ddlog.NewRecordSet(
+		ddlog.NewRecordStruct("PodReference", ddlog.NewRecordString("pod1"), ddlog.NewRecordString("ns1")),
+		ddlog.NewRecordStruct("PodReference", ddlog.NewRecordString("pod2"), ddlog.NewRecordString("ns1")),
+	)
+	r := ddlog.NewRecordStruct(
+		"AppliedToGroupPodsByNode",
+		ddlog.NewRecordString("group1"),
+		ddlog.NewRecordString("node-1"),
+		rPods,
+	)
+	defer r.Free()
+
+	obj, err := RecordToOutput(AppliedToGroupPodsByNodeTableID, r)
+	require.Nil(t, err)
+	assert.Equal(t, &AppliedToGroupPodsByNode{
+		AppliedToGroup: "group1",
+		NodeName:       "node-1",
+		Pods: []PodReference{
+			{Name: "pod1", Namespace: "ns1"},
+			{Name: "pod2", Namespace: "ns1"},
+		},
+	}, obj)
+}
+
+func TestInternalNetworkPolicy(t *testing.T) {
+	ipBlock := &networkingv1.IPBlock{CIDR: "10.0.0.0/8", Except: []string{"10.1.0.0/16"}}
+	rRule := ddlog.NewRecordStruct(
+		"NetworkPolicyRule",
+		ddlog.NewRecordStruct("DirectionIn"),
+		ddlog.NewRecordStruct("NetworkPolicyPeer",
+			ddlog.NewRecordVector(ddlog.NewRecordString("addressGroup1")),
+			ddlog.NewRecordVector(NewRecordIPBlock(ipBlock)),
+		),
+		ddlog.NewRecordStruct("NetworkPolicyPeer", ddlog.NewRecordVector(), ddlog.NewRecordVector()),
+		ddlog.NewRecordVector(),
+	)
+	r := ddlog.NewRecordStruct(
+		"NetworkPolicy",
+		NewRecordUID("uid1"),
+		ddlog.NewRecordString("np1"),
+		ddlog.NewRecordString("ns1"),
+		ddlog.NewRecordVector(rRule),
+		ddlog.NewRecordVector(ddlog.NewRecordString("appliedToGroup1")),
+		ddlog.NewRecordVector(ddlog.NewRecordStructStatic(PolicyTypeIngressConstructor)),
+	)
+	defer r.Free()
+
+	np, err := RecordToInternalNetworkPolicy(r)
+	require.Nil(t, err)
+	assert.Equal(t, &InternalNetworkPolicy{
+		UID:       types.UID("uid1"),
+		Name:      "np1",
+		Namespace: "ns1",
+		Rules: []InternalNetworkPolicyRule{
+			{
+				Direction: DirectionIn,
+				From: InternalNetworkPolicyPeer{
+					AddressGroups: []string{"addressGroup1"},
+					IPBlocks:      []networkingv1.IPBlock{*ipBlock},
+				},
+				To: InternalNetworkPolicyPeer{AddressGroups: []string{}},
+			},
+		},
+		AppliedToGroups: []string{"appliedToGroup1"},
+		PolicyTypes:     []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+	}, np)
+}
+
+func TestInternalNetworkPolicyUnknownPolicyType(t *testing.T) {
+	r := ddlog.NewRecordStruct(
+		"NetworkPolicy",
+		NewRecordUID("uid1"),
+		ddlog.NewRecordString("np1"),
+		ddlog.NewRecordString("ns1"),
+		ddlog.NewRecordVector(),
+		ddlog.NewRecordVector(ddlog.NewRecordString("appliedToGroup1")),
+		ddlog.NewRecordVector(ddlog.NewRecordStruct("k8spolicy.PolicyTypeUnknown")),
+	)
+	defer r.Free()
+
+	_, err := RecordToInternalNetworkPolicy(r)
+	assert.Error(t, err)
+}
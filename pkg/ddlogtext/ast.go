@@ -0,0 +1,138 @@
+// Copyright 2020 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ddlogtext parses the text format used by DDlog for records and commands, without the
+// native DDlog library. This is the format produced by ddlog.Record.Dump and by
+// ddlog.Program.StartRecordingCommands, which is also the input format of the DDlog CLI. A
+// recorded command file looks like this:
+//
+//	start;
+//	insert k8spolicy.Namespace[k8spolicy.Namespace{"default", ...}],
+//	delete_key k8spolicy.Pod ("default", "pod1");
+//	commit;
+//
+// Values are written as Rust-style literals: booleans, integers, strings, tuples "(a, b)",
+// vectors, sets and maps "[a, b]" (map entries are written as tuples), and structs, either
+// positional "Name{a, b}" or named "Name{.f1 = a, .f2 = b}". The std.Option and std.Either
+// constructors are recognized and parsed as Option and Either values.
+//
+// The conversion of the parsed values and commands to DDlog records and commands, which requires
+// the native library, is provided by package ddlogconv.
+package ddlogtext
+
+import "math/big"
+
+// Value is a record literal. It is one of Bool, *Int, String, Tuple, Array, *Struct, *Option and
+// *Either.
+type Value interface {
+	isValue()
+}
+
+// Bool is a boolean literal.
+type Bool bool
+
+// Int is an integer literal. DDlog integers can be arbitrarily large.
+type Int struct {
+	*big.Int
+}
+
+// String is a string literal, unescaped.
+type String string
+
+// Tuple is a tuple literal "(a, b, ...)".
+type Tuple []Value
+
+// Array is a vector, set or map literal "[a, b, ...]". The text format does not distinguish
+// between them; map entries are 2-tuples.
+type Array []Value
+
+// Struct is a struct literal, with positional or named fields.
+type Struct struct {
+	Constructor string
+	// FieldNames is nil for positional structs. Otherwise it has the same length as Fields.
+	FieldNames []string
+	Fields     []Value
+}
+
+// Option is a std.Some or std.None literal.
+type Option struct {
+	// Value is nil for std.None.
+	Value Value
+}
+
+// Either is a std.Left or std.Right literal.
+type Either struct {
+	Left  bool
+	Value Value
+}
+
+func (Bool) isValue()    {}
+func (*Int) isValue()    {}
+func (String) isValue()  {}
+func (Tuple) isValue()   {}
+func (Array) isValue()   {}
+func (*Struct) isValue() {}
+func (*Option) isValue() {}
+func (*Either) isValue() {}
+
+// MapEntries returns the entries of the array as (key, value) pairs. It returns false if the array
+// is not a valid map, i.e. if one of the elements is not a 2-tuple.
+func (a Array) MapEntries() ([][2]Value, bool) {
+	entries := make([][2]Value, len(a))
+	for i, e := range a {
+		t, ok := e.(Tuple)
+		if !ok || len(t) != 2 {
+			return nil, false
+		}
+		entries[i] = [2]Value{t[0], t[1]}
+	}
+	return entries, true
+}
+
+// CommandKind is the kind of a DDlog command.
+type CommandKind string
+
+const (
+	CommandStart          CommandKind = "start"
+	CommandCommit         CommandKind = "commit"
+	CommandRollback       CommandKind = "rollback"
+	CommandInsert         CommandKind = "insert"
+	CommandInsertOrUpdate CommandKind = "insert_or_update"
+	CommandDelete         CommandKind = "delete"
+	CommandDeleteKey      CommandKind = "delete_key"
+	CommandClear          CommandKind = "clear"
+)
+
+// IsUpdate returns true for the commands which update a relation.
+func (k CommandKind) IsUpdate() bool {
+	switch k {
+	case CommandInsert, CommandInsertOrUpdate, CommandDelete, CommandDeleteKey, CommandClear:
+		return true
+	}
+	return false
+}
+
+// Command is a parsed DDlog command.
+type Command struct {
+	// Line is the line at which the command starts in the input.
+	Line int
+	Kind CommandKind
+	// Relation is only set for updates.
+	Relation string
+	// Value is the record for insert, insert_or_update and delete, the key for delete_key, and
+	// nil otherwise.
+	Value Value
+	// DumpChanges is true for "commit dump_changes;".
+	DumpChanges bool
+}
@@ -0,0 +1,120 @@
+// Copyright 2020 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ddlogconv converts the values and commands parsed by package ddlogtext to DDlog records
+// and commands. It is kept separate from ddlogtext, which is pure Go, since it requires the native
+// DDlog library.
+package ddlogconv
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/antoninbas/antrea-k8s-to-ddlog/pkg/ddlogtext"
+	"github.com/vmware/differential-datalog/go/pkg/ddlog"
+)
+
+var maxU64 = new(big.Int).SetUint64(^uint64(0))
+
+// ToRecord converts a value to a DDlog record. Arrays are converted to vectors, which DDlog
+// accepts for sets and maps as well. Named struct fields must appear in declaration order, which
+// is always the case for records printed by DDlog.
+func ToRecord(v ddlogtext.Value) (ddlog.Record, error) {
+	switch v := v.(type) {
+	case ddlogtext.Bool:
+		return ddlog.NewRecordBool(bool(v)), nil
+	case *ddlogtext.Int:
+		if v.Sign() >= 0 && v.Cmp(maxU64) <= 0 {
+			return ddlog.NewRecordU64(v.Uint64()), nil
+		}
+		if v.IsInt64() {
+			return ddlog.NewRecordI64(v.Int64()), nil
+		}
+		return nil, fmt.Errorf("integer '%s' does not fit in 64 bits", v)
+	case ddlogtext.String:
+		return ddlog.NewRecordString(string(v)), nil
+	case ddlogtext.Tuple:
+		records, err := toRecords(v)
+		if err != nil {
+			return nil, err
+		}
+		return ddlog.NewRecordTuple(records...), nil
+	case ddlogtext.Array:
+		records, err := toRecords(v)
+		if err != nil {
+			return nil, err
+		}
+		return ddlog.NewRecordVector(records...), nil
+	case *ddlogtext.Struct:
+		records, err := toRecords(v.Fields)
+		if err != nil {
+			return nil, err
+		}
+		return ddlog.NewRecordStruct(v.Constructor, records...), nil
+	case *ddlogtext.Option:
+		if v.Value == nil {
+			return ddlog.NewRecordNone(), nil
+		}
+		r, err := ToRecord(v.Value)
+		if err != nil {
+			return nil, err
+		}
+		return ddlog.NewRecordSome(r), nil
+	case *ddlogtext.Either:
+		r, err := ToRecord(v.Value)
+		if err != nil {
+			return nil, err
+		}
+		if v.Left {
+			return ddlog.NewRecordLeft(r), nil
+		}
+		return ddlog.NewRecordRight(r), nil
+	}
+	return nil, fmt.Errorf("unsupported value type %T", v)
+}
+
+func toRecords(values []ddlogtext.Value) ([]ddlog.Record, error) {
+	records := make([]ddlog.Record, len(values))
+	for i, v := range values {
+		r, err := ToRecord(v)
+		if err != nil {
+			return nil, err
+		}
+		records[i] = r
+	}
+	return records, nil
+}
+
+// ToCommand converts an update command to a DDlog command. Only insert, insert_or_update, delete
+// and delete_key commands can be converted.
+func ToCommand(c *ddlogtext.Command) (ddlog.Command, error) {
+	var newCommand func(ddlog.TableID, ddlog.Record) ddlog.Command
+	switch c.Kind {
+	case ddlogtext.CommandInsert:
+		newCommand = ddlog.NewInsertCommand
+	case ddlogtext.CommandInsertOrUpdate:
+		newCommand = ddlog.NewInsertOrUpdateCommand
+	case ddlogtext.CommandDelete:
+		newCommand = ddlog.NewDeleteValCommand
+	case ddlogtext.CommandDeleteKey:
+		newCommand = ddlog.NewDeleteKeyCommand
+	default:
+		return ddlog.Command{}, fmt.Errorf("line %d: cannot convert '%s' to a DDlog command", c.Line, c.Kind)
+	}
+	r, err := ToRecord(c.Value)
+	if err != nil {
+		return ddlog.Command{}, fmt.Errorf("line %d: %v", c.Line, err)
+	}
+	return newCommand(ddlog.GetTableID(c.Relation), r), nil
+}
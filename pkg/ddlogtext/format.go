@@ -0,0 +1,127 @@
+// Copyright 2020 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ddlogtext
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+)
+
+// Format returns the text representation of a value, which can be parsed back with ParseValue.
+func Format(v Value) string {
+	var b strings.Builder
+	format(&b, v)
+	return b.String()
+}
+
+func formatString(b *strings.Builder, s string) {
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\r':
+			b.WriteString(`\r`)
+		case 0:
+			b.WriteString(`\0`)
+		default:
+			if unicode.IsPrint(r) {
+				b.WriteRune(r)
+			} else {
+				fmt.Fprintf(b, `\u{%x}`, r)
+			}
+		}
+	}
+	b.WriteByte('"')
+}
+
+func formatList(b *strings.Builder, open, close string, values []Value) {
+	b.WriteString(open)
+	for i, v := range values {
+		if i > 0 {
+			b.WriteString(", ")
+		}
+		format(b, v)
+	}
+	b.WriteString(close)
+}
+
+func format(b *strings.Builder, v Value) {
+	switch v := v.(type) {
+	case Bool:
+		fmt.Fprintf(b, "%t", bool(v))
+	case *Int:
+		b.WriteString(v.String())
+	case String:
+		formatString(b, string(v))
+	case Tuple:
+		formatList(b, "(", ")", v)
+	case Array:
+		formatList(b, "[", "]", v)
+	case *Struct:
+		b.WriteString(v.Constructor)
+		if v.FieldNames == nil {
+			formatList(b, "{", "}", v.Fields)
+			return
+		}
+		b.WriteByte('{')
+		for i, f := range v.Fields {
+			if i > 0 {
+				b.WriteString(", ")
+			}
+			fmt.Fprintf(b, ".%s = ", v.FieldNames[i])
+			format(b, f)
+		}
+		b.WriteByte('}')
+	case *Option:
+		if v.Value == nil {
+			b.WriteString("std.None{}")
+			return
+		}
+		formatList(b, "std.Some{", "}", []Value{v.Value})
+	case *Either:
+		constructor := "std.Right{"
+		if v.Left {
+			constructor = "std.Left{"
+		}
+		formatList(b, constructor, "}", []Value{v.Value})
+	}
+}
+
+// String returns the text representation of the command, which can be parsed back with
+// ParseCommands.
+func (c *Command) String() string {
+	switch c.Kind {
+	case CommandCommit:
+		if c.DumpChanges {
+			return "commit dump_changes;"
+		}
+		return "commit;"
+	case CommandInsert, CommandInsertOrUpdate, CommandDelete:
+		return fmt.Sprintf("%s %s[%s];", c.Kind, c.Relation, Format(c.Value))
+	case CommandDeleteKey:
+		return fmt.Sprintf("%s %s %s;", c.Kind, c.Relation, Format(c.Value))
+	case CommandClear:
+		return fmt.Sprintf("%s %s;", c.Kind, c.Relation)
+	}
+	return fmt.Sprintf("%s;", c.Kind)
+}
@@ -0,0 +1,75 @@
+// Copyright 2020 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build gofuzz
+// +build gofuzz
+
+package ddlogtext
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Fuzz is the entry point for go-fuzz (https://github.com/dvyukov/go-fuzz):
+//
+//	go-fuzz-build github.com/antoninbas/antrea-k8s-to-ddlog/pkg/ddlogtext
+//	go-fuzz -bin ddlogtext-fuzz.zip -workdir fuzz
+//
+// See checkRoundTrip for the properties being checked.
+func Fuzz(data []byte) int {
+	if err := checkRoundTrip(string(data)); err != nil {
+		panic(err)
+	}
+	if _, err := ParseCommands(string(data)); err != nil {
+		return 0
+	}
+	return 1
+}
+
+// checkRoundTrip checks the properties verified by fuzzing: if the input can be parsed (as a
+// value or as a list of commands), then its formatted representation can be parsed as well, and
+// formatting is stable, i.e. formatting the result of parsing the formatted representation yields
+// the same text.
+func checkRoundTrip(input string) error {
+	if v, err := ParseValue(input); err == nil {
+		formatted := Format(v)
+		v2, err := ParseValue(formatted)
+		if err != nil {
+			return fmt.Errorf("cannot parse formatted value %q (from %q): %v", formatted, input, err)
+		}
+		if formatted2 := Format(v2); formatted2 != formatted {
+			return fmt.Errorf("formatting is not stable for %q: %q != %q", input, formatted, formatted2)
+		}
+	}
+	if cmds, err := ParseCommands(input); err == nil {
+		formatted := formatCommands(cmds)
+		cmds2, err := ParseCommands(formatted)
+		if err != nil {
+			return fmt.Errorf("cannot parse formatted commands %q (from %q): %v", formatted, input, err)
+		}
+		if formatted2 := formatCommands(cmds2); formatted2 != formatted {
+			return fmt.Errorf("formatting is not stable for %q: %q != %q", input, formatted, formatted2)
+		}
+	}
+	return nil
+}
+
+func formatCommands(cmds []*Command) string {
+	lines := make([]string, len(cmds))
+	for i, cmd := range cmds {
+		lines[i] = cmd.String()
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,79 @@
+// Copyright 2020 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build gofuzz
+// +build gofuzz
+
+package ddlogtext
+
+import (
+	"math/rand"
+	"testing"
+)
+
+var fuzzCorpus = []string{
+	testCommands,
+	`k8spolicy.Pod{.name = "a\tb", .uid = k8spolicy.UID{"\u{1f600}"}, .labels = [("k", "v")], .ip = std.Some{-1}}`,
+	`(true, false, [], (), std.Left{std.Right{std.None{}}})`,
+	`[1, 2, 18446744073709551616, "\\\"", S{}, E]`,
+}
+
+// fuzzBytes are the bytes inserted by mutate, chosen to exercise the lexer and the parser.
+var fuzzBytes = []byte(`[]{}(),;=.-"\ #0123456789abcxnu_` + "\n\x00\xff")
+
+// mutate applies a few random byte-level mutations to the input.
+func mutate(r *rand.Rand, input []byte) []byte {
+	out := append([]byte(nil), input...)
+	for n := r.Intn(4) + 1; n > 0; n-- {
+		pos := 0
+		if len(out) > 0 {
+			pos = r.Intn(len(out))
+		}
+		switch r.Intn(3) {
+		case 0: // insert
+			b := fuzzBytes[r.Intn(len(fuzzBytes))]
+			out = append(out[:pos], append([]byte{b}, out[pos:]...)...)
+		case 1: // delete
+			if len(out) > 0 {
+				out = append(out[:pos], out[pos+1:]...)
+			}
+		case 2: // truncate
+			out = out[:pos]
+		}
+	}
+	return out
+}
+
+// TestFuzz runs a simple mutation-based fuzzer for a fixed number of iterations, checking that the
+// parser never panics and that formatting round-trips. The Fuzz function can be used with go-fuzz
+// for longer runs. Like checkRoundTrip, it requires the gofuzz build tag, which "make check-unit"
+// sets.
+func TestFuzz(t *testing.T) {
+	iterations := 20000
+	if testing.Short() {
+		iterations = 2000
+	}
+	r := rand.New(rand.NewSource(1))
+	for _, seed := range fuzzCorpus {
+		if err := checkRoundTrip(seed); err != nil {
+			t.Fatalf("Seed failed: %v", err)
+		}
+	}
+	for i := 0; i < iterations; i++ {
+		input := mutate(r, []byte(fuzzCorpus[r.Intn(len(fuzzCorpus))]))
+		if err := checkRoundTrip(string(input)); err != nil {
+			t.Fatalf("Round trip failed: %v", err)
+		}
+	}
+}
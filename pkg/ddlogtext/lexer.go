@@ -0,0 +1,201 @@
+// Copyright 2020 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ddlogtext
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenIdent
+	tokenString
+	tokenNumber
+	tokenPunct
+)
+
+type token struct {
+	kind tokenKind
+	text string
+	line int
+}
+
+func (t token) String() string {
+	switch t.kind {
+	case tokenEOF:
+		return "end of input"
+	case tokenString:
+		return strconv.Quote(t.text)
+	default:
+		return fmt.Sprintf("'%s'", t.text)
+	}
+}
+
+type lexer struct {
+	input string
+	pos   int
+	line  int
+}
+
+func isIdentStart(r rune) bool {
+	return r == '_' || unicode.IsLetter(r)
+}
+
+func isIdentChar(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func (l *lexer) peekRune(offset int) rune {
+	if l.pos+offset >= len(l.input) {
+		return utf8.RuneError
+	}
+	r, _ := utf8.DecodeRuneInString(l.input[l.pos+offset:])
+	return r
+}
+
+func (l *lexer) skipSpaceAndComments() {
+	for l.pos < len(l.input) {
+		c := l.input[l.pos]
+		switch {
+		case c == '\n':
+			l.line++
+			l.pos++
+		case c == ' ' || c == '\t' || c == '\r':
+			l.pos++
+		case c == '#':
+			for l.pos < len(l.input) && l.input[l.pos] != '\n' {
+				l.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+func (l *lexer) next() (token, error) {
+	l.skipSpaceAndComments()
+	if l.pos >= len(l.input) {
+		return token{kind: tokenEOF, line: l.line}, nil
+	}
+	start := l.pos
+	r, size := utf8.DecodeRuneInString(l.input[l.pos:])
+	switch {
+	case isIdentStart(r):
+		// Identifiers may be qualified with a module name, e.g. "k8spolicy.Pod".
+		l.pos += size
+		for l.pos < len(l.input) {
+			r, size := utf8.DecodeRuneInString(l.input[l.pos:])
+			if isIdentChar(r) {
+				l.pos += size
+			} else if r == '.' && isIdentStart(l.peekRune(1)) {
+				l.pos += size
+			} else {
+				break
+			}
+		}
+		return token{kind: tokenIdent, text: l.input[start:l.pos], line: l.line}, nil
+	case r == '-' || (r >= '0' && r <= '9'):
+		l.pos++
+		for l.pos < len(l.input) && l.input[l.pos] >= '0' && l.input[l.pos] <= '9' {
+			l.pos++
+		}
+		if l.pos == start+1 && r == '-' {
+			return token{}, fmt.Errorf("line %d: invalid number", l.line)
+		}
+		return token{kind: tokenNumber, text: l.input[start:l.pos], line: l.line}, nil
+	case r == '"':
+		s, err := l.lexString()
+		if err != nil {
+			return token{}, err
+		}
+		return token{kind: tokenString, text: s, line: l.line}, nil
+	case strings.ContainsRune("[]{}(),;=.", r):
+		l.pos++
+		return token{kind: tokenPunct, text: string(r), line: l.line}, nil
+	}
+	return token{}, fmt.Errorf("line %d: unexpected character %q", l.line, r)
+}
+
+// lexString lexes a string literal with Rust escape sequences, which is how DDlog prints strings.
+func (l *lexer) lexString() (string, error) {
+	startLine := l.line
+	l.pos++ // opening quote
+	var b strings.Builder
+	for {
+		if l.pos >= len(l.input) {
+			return "", fmt.Errorf("line %d: unterminated string", startLine)
+		}
+		c := l.input[l.pos]
+		switch c {
+		case '"':
+			l.pos++
+			return b.String(), nil
+		case '\n':
+			l.line++
+			b.WriteByte(c)
+			l.pos++
+		case '\\':
+			if l.pos+1 >= len(l.input) {
+				return "", fmt.Errorf("line %d: unterminated string", startLine)
+			}
+			esc := l.input[l.pos+1]
+			l.pos += 2
+			switch esc {
+			case 'n':
+				b.WriteByte('\n')
+			case 't':
+				b.WriteByte('\t')
+			case 'r':
+				b.WriteByte('\r')
+			case '0':
+				b.WriteByte(0)
+			case '\\', '"', '\'':
+				b.WriteByte(esc)
+			case 'x':
+				if l.pos+2 > len(l.input) {
+					return "", fmt.Errorf("line %d: invalid escape sequence", l.line)
+				}
+				v, err := strconv.ParseUint(l.input[l.pos:l.pos+2], 16, 8)
+				if err != nil {
+					return "", fmt.Errorf("line %d: invalid escape sequence", l.line)
+				}
+				b.WriteByte(byte(v))
+				l.pos += 2
+			case 'u':
+				end := strings.IndexByte(l.input[l.pos:], '}')
+				if l.pos >= len(l.input) || l.input[l.pos] != '{' || end < 0 {
+					return "", fmt.Errorf("line %d: invalid unicode escape sequence", l.line)
+				}
+				v, err := strconv.ParseUint(l.input[l.pos+1:l.pos+end], 16, 32)
+				if err != nil || !utf8.ValidRune(rune(v)) {
+					return "", fmt.Errorf("line %d: invalid unicode escape sequence", l.line)
+				}
+				b.WriteRune(rune(v))
+				l.pos += end + 1
+			default:
+				return "", fmt.Errorf("line %d: invalid escape sequence '\\%c'", l.line, esc)
+			}
+		default:
+			b.WriteByte(c)
+			l.pos++
+		}
+	}
+}
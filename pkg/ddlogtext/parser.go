@@ -0,0 +1,344 @@
+// Copyright 2020 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ddlogtext
+
+import (
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// stdModules are the names under which the DDlog standard library module can appear in
+// constructor names, depending on the DDlog version.
+var stdModules = []string{"std.", "ddlog_std."}
+
+type parser struct {
+	lexer *lexer
+	tok   token
+}
+
+func newParser(input string) (*parser, error) {
+	p := &parser{lexer: &lexer{input: input, line: 1}}
+	if err := p.advance(); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (p *parser) advance() error {
+	tok, err := p.lexer.next()
+	if err != nil {
+		return err
+	}
+	p.tok = tok
+	return nil
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("line %d: %s", p.tok.line, fmt.Sprintf(format, args...))
+}
+
+func (p *parser) isPunct(s string) bool {
+	return p.tok.kind == tokenPunct && p.tok.text == s
+}
+
+func (p *parser) expectPunct(s string) error {
+	if !p.isPunct(s) {
+		return p.errorf("expected '%s', got %s", s, p.tok)
+	}
+	return p.advance()
+}
+
+func (p *parser) expectIdent() (string, error) {
+	if p.tok.kind != tokenIdent {
+		return "", p.errorf("expected identifier, got %s", p.tok)
+	}
+	name := p.tok.text
+	return name, p.advance()
+}
+
+func (p *parser) expectEOF() error {
+	if p.tok.kind != tokenEOF {
+		return p.errorf("unexpected %s", p.tok)
+	}
+	return nil
+}
+
+// ParseValue parses a single record literal, e.g. the output of ddlog.Record.Dump.
+func ParseValue(input string) (Value, error) {
+	p, err := newParser(input)
+	if err != nil {
+		return nil, err
+	}
+	v, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+	return v, p.expectEOF()
+}
+
+// parseList parses a comma-separated list of elements terminated by the closing punctuation. The
+// opening punctuation must already have been consumed. A trailing comma is accepted.
+func (p *parser) parseList(closing string, parseElement func() error) error {
+	for !p.isPunct(closing) {
+		if err := parseElement(); err != nil {
+			return err
+		}
+		if p.isPunct(",") {
+			if err := p.advance(); err != nil {
+				return err
+			}
+		} else if !p.isPunct(closing) {
+			return p.errorf("expected ',' or '%s', got %s", closing, p.tok)
+		}
+	}
+	return p.advance()
+}
+
+func (p *parser) parseValues(closing string) ([]Value, error) {
+	var values []Value
+	err := p.parseList(closing, func() error {
+		v, err := p.parseValue()
+		values = append(values, v)
+		return err
+	})
+	return values, err
+}
+
+func (p *parser) parseValue() (Value, error) {
+	tok := p.tok
+	switch tok.kind {
+	case tokenString:
+		return String(tok.text), p.advance()
+	case tokenNumber:
+		i, ok := new(big.Int).SetString(tok.text, 10)
+		if !ok {
+			return nil, p.errorf("invalid integer '%s'", tok.text)
+		}
+		return &Int{i}, p.advance()
+	case tokenIdent:
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		if !p.isPunct("{") {
+			switch tok.text {
+			case "true":
+				return Bool(true), nil
+			case "false":
+				return Bool(false), nil
+			}
+			// Struct with no fields, e.g. an enum constructor.
+			return toStdValue(&Struct{Constructor: tok.text}), nil
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		s, err := p.parseStructFields(tok.text)
+		if err != nil {
+			return nil, err
+		}
+		return toStdValue(s), nil
+	case tokenPunct:
+		switch tok.text {
+		case "(":
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			values, err := p.parseValues(")")
+			return Tuple(values), err
+		case "[":
+			if err := p.advance(); err != nil {
+				return nil, err
+			}
+			values, err := p.parseValues("]")
+			if values == nil {
+				values = []Value{}
+			}
+			return Array(values), err
+		}
+	}
+	return nil, p.errorf("expected value, got %s", tok)
+}
+
+func (p *parser) parseStructFields(constructor string) (*Struct, error) {
+	s := &Struct{Constructor: constructor}
+	named := p.isPunct(".")
+	err := p.parseList("}", func() error {
+		if named {
+			if err := p.expectPunct("."); err != nil {
+				return err
+			}
+			name, err := p.expectIdent()
+			if err != nil {
+				return err
+			}
+			if err := p.expectPunct("="); err != nil {
+				return err
+			}
+			s.FieldNames = append(s.FieldNames, name)
+		}
+		v, err := p.parseValue()
+		s.Fields = append(s.Fields, v)
+		return err
+	})
+	return s, err
+}
+
+// stdConstructor returns the name of the constructor without the standard library module prefix,
+// or an empty string if the constructor is not from the standard library.
+func stdConstructor(constructor string) string {
+	for _, module := range stdModules {
+		if strings.HasPrefix(constructor, module) {
+			return strings.TrimPrefix(constructor, module)
+		}
+	}
+	return ""
+}
+
+// toStdValue converts the std.Option and std.Either constructors to Option and Either values.
+// Other structs are returned unchanged.
+func toStdValue(s *Struct) Value {
+	switch stdConstructor(s.Constructor) {
+	case "None":
+		if len(s.Fields) == 0 {
+			return &Option{}
+		}
+	case "Some":
+		if len(s.Fields) == 1 {
+			return &Option{Value: s.Fields[0]}
+		}
+	case "Left":
+		if len(s.Fields) == 1 {
+			return &Either{Left: true, Value: s.Fields[0]}
+		}
+	case "Right":
+		if len(s.Fields) == 1 {
+			return &Either{Left: false, Value: s.Fields[0]}
+		}
+	}
+	return s
+}
+
+// ParseCommands parses a sequence of commands, e.g. the contents of a file written by
+// ddlog.Program.StartRecordingCommands. Updates can be separated by ',' or by ';'. Commands which
+// do not modify the state of the program, such as "echo", "dump", "timestamp" or "profile", are
+// skipped.
+func ParseCommands(input string) ([]*Command, error) {
+	p, err := newParser(input)
+	if err != nil {
+		return nil, err
+	}
+	var commands []*Command
+	// inUpdates is true if the last update was terminated by ',', in which case it must be
+	// followed by another update.
+	inUpdates := false
+	for p.tok.kind != tokenEOF {
+		line := p.tok.line
+		if p.tok.kind != tokenIdent {
+			return nil, p.errorf("expected command, got %s", p.tok)
+		}
+		kind := CommandKind(p.tok.text)
+		switch kind {
+		case "echo", "dump", "timestamp", "profile", "exit":
+			if inUpdates {
+				return nil, fmt.Errorf("line %d: expected update after ',', got '%s'", line, kind)
+			}
+			// Skip until the end of the command. echo takes arbitrary text, which may not
+			// be valid tokens, so we skip at the character level.
+			if err := p.skipCommand(); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if err := p.advance(); err != nil {
+			return nil, err
+		}
+		if inUpdates && !kind.IsUpdate() {
+			return nil, fmt.Errorf("line %d: expected update after ',', got '%s'", line, kind)
+		}
+		cmd := &Command{Line: line, Kind: kind}
+		commands = append(commands, cmd)
+		switch kind {
+		case CommandStart, CommandRollback:
+		case CommandCommit:
+			if p.tok.kind == tokenIdent && p.tok.text == "dump_changes" {
+				cmd.DumpChanges = true
+				if err := p.advance(); err != nil {
+					return nil, err
+				}
+			}
+		default:
+			if !kind.IsUpdate() {
+				return nil, fmt.Errorf("line %d: unknown command '%s'", line, kind)
+			}
+			if err := p.parseUpdate(cmd); err != nil {
+				return nil, err
+			}
+			if p.isPunct(",") {
+				inUpdates = true
+				if err := p.advance(); err != nil {
+					return nil, err
+				}
+				continue
+			}
+			inUpdates = false
+		}
+		if err := p.expectPunct(";"); err != nil {
+			return nil, err
+		}
+	}
+	if inUpdates {
+		return nil, p.errorf("expected update after ','")
+	}
+	return commands, nil
+}
+
+// skipCommand skips the current token and all the input until the next ';', which is consumed.
+func (p *parser) skipCommand() error {
+	l := p.lexer
+	for l.pos < len(l.input) && l.input[l.pos] != ';' {
+		if l.input[l.pos] == '\n' {
+			l.line++
+		}
+		l.pos++
+	}
+	if l.pos >= len(l.input) {
+		return p.errorf("expected ';'")
+	}
+	l.pos++
+	return p.advance()
+}
+
+func (p *parser) parseUpdate(cmd *Command) error {
+	relation, err := p.expectIdent()
+	if err != nil {
+		return err
+	}
+	cmd.Relation = relation
+	switch cmd.Kind {
+	case CommandClear:
+		return nil
+	case CommandDeleteKey:
+		cmd.Value, err = p.parseValue()
+		return err
+	}
+	if err := p.expectPunct("["); err != nil {
+		return err
+	}
+	if cmd.Value, err = p.parseValue(); err != nil {
+		return err
+	}
+	return p.expectPunct("]")
+}
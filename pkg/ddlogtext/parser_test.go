@@ -0,0 +1,135 @@
+// Copyright 2020 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ddlogtext
+
+import (
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newInt(v int64) *Int {
+	return &Int{big.NewInt(v)}
+}
+
+func TestParseValue(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		input    string
+		expected Value
+	}{
+		{"bool", "true", Bool(true)},
+		{"negative int", "-42", newInt(-42)},
+		{"string with escapes", `"a\"b\\c\n\u{e9}"`, String("a\"b\\c\né")},
+		{"tuple", `(1, "a")`, Tuple{newInt(1), String("a")}},
+		{"empty vector", "[]", Array{}},
+		{"map", `[("k1", "v1"), ("k2", "v2")]`, Array{Tuple{String("k1"), String("v1")}, Tuple{String("k2"), String("v2")}}},
+		{"positional struct", `k8spolicy.PodSpec{"node-1"}`, &Struct{Constructor: "k8spolicy.PodSpec", Fields: []Value{String("node-1")}}},
+		{"named struct", `k8spolicy.PodSpec{.nodeName = "node-1", .x = 1,}`, &Struct{
+			Constructor: "k8spolicy.PodSpec",
+			FieldNames:  []string{"nodeName", "x"},
+			Fields:      []Value{String("node-1"), newInt(1)},
+		}},
+		{"enum constructor", "k8spolicy.DirectionIn", &Struct{Constructor: "k8spolicy.DirectionIn"}},
+		{"none", "std.None{}", &Option{}},
+		{"some", `ddlog_std.Some{"a"}`, &Option{Value: String("a")}},
+		{"left", "std.Left{1}", &Either{Left: true, Value: newInt(1)}},
+		{"right", "std.Right{[]}", &Either{Value: Array{}}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			v, err := ParseValue(tc.input)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, v)
+			// The formatted value must be parsed back to the same value.
+			v2, err := ParseValue(Format(v))
+			require.NoError(t, err)
+			assert.Equal(t, v, v2)
+		})
+	}
+}
+
+func TestMapEntries(t *testing.T) {
+	v, err := ParseValue(`[("k1", 1)]`)
+	require.NoError(t, err)
+	entries, ok := v.(Array).MapEntries()
+	require.True(t, ok)
+	assert.Equal(t, [][2]Value{{String("k1"), newInt(1)}}, entries)
+
+	_, ok = Array{newInt(1)}.MapEntries()
+	assert.False(t, ok)
+}
+
+const testCommands = `# recorded commands
+start;
+insert k8spolicy.Namespace[k8spolicy.Namespace{.name = "ns1", .labels = [("env", "prod")]}],
+insert_or_update k8spolicy.Pod[k8spolicy.Pod{"pod1", std.None{}}];
+commit dump_changes;
+echo this is ignored, even with weird characters: !@;
+start;
+delete_key k8spolicy.Pod ("ns1", "pod1"),
+delete R[1],
+clear k8spolicy.NetworkPolicy;
+rollback;
+`
+
+func TestParseCommands(t *testing.T) {
+	cmds, err := ParseCommands(testCommands)
+	require.NoError(t, err)
+	assert.Equal(t, []*Command{
+		{Line: 2, Kind: CommandStart},
+		{Line: 3, Kind: CommandInsert, Relation: "k8spolicy.Namespace", Value: &Struct{
+			Constructor: "k8spolicy.Namespace",
+			FieldNames:  []string{"name", "labels"},
+			Fields:      []Value{String("ns1"), Array{Tuple{String("env"), String("prod")}}},
+		}},
+		{Line: 4, Kind: CommandInsertOrUpdate, Relation: "k8spolicy.Pod", Value: &Struct{
+			Constructor: "k8spolicy.Pod",
+			Fields:      []Value{String("pod1"), &Option{}},
+		}},
+		{Line: 5, Kind: CommandCommit, DumpChanges: true},
+		{Line: 7, Kind: CommandStart},
+		{Line: 8, Kind: CommandDeleteKey, Relation: "k8spolicy.Pod", Value: Tuple{String("ns1"), String("pod1")}},
+		{Line: 9, Kind: CommandDelete, Relation: "R", Value: newInt(1)},
+		{Line: 10, Kind: CommandClear, Relation: "k8spolicy.NetworkPolicy"},
+		{Line: 11, Kind: CommandRollback},
+	}, cmds)
+
+	assert.Equal(t, `insert k8spolicy.Namespace[k8spolicy.Namespace{.name = "ns1", .labels = [("env", "prod")]}];`, cmds[1].String())
+	assert.Equal(t, `delete_key k8spolicy.Pod ("ns1", "pod1");`, cmds[5].String())
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		input string
+	}{
+		{"dangling comma", "start;\ninsert R[1],\ncommit;"},
+		{"missing semicolon", "start"},
+		{"unterminated string", `insert R["abc];`},
+		{"invalid escape", `insert R["\q"];`},
+		{"invalid unicode escape", `insert R["\u{110000}"];`},
+		{"unknown command", `foo;`},
+		{"unbalanced brackets", `insert R[S{1, 2];`},
+		{"lone minus", `insert R[-];`},
+		{"mixed named and positional fields", `insert R[S{.a = 1, 2}];`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := ParseCommands(tc.input)
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,269 @@
+// Copyright 2020 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package explain answers questions about the effect of NetworkPolicies on Pods, such as "which
+// policies apply to this Pod". Answers are derived from the DDlog output relations, as maintained by
+// a view.View, so that they reflect what Antrea agents would enforce. The K8s Pods are only used to
+// resolve a Pod name to its IP address and Node.
+package explain
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"github.com/antoninbas/antrea-k8s-to-ddlog/pkg/ddlogk8s"
+	"github.com/antoninbas/antrea-k8s-to-ddlog/pkg/view"
+)
+
+// PolicyReference identifies an internal NetworkPolicy.
+type PolicyReference struct {
+	UID       types.UID `json:"uid"`
+	Name      string    `json:"name"`
+	Namespace string    `json:"namespace"`
+}
+
+func (r PolicyReference) String() string {
+	return r.Namespace + "/" + r.Name
+}
+
+func policyReference(policy *view.NetworkPolicy) PolicyReference {
+	return PolicyReference{UID: policy.UID, Name: policy.Name, Namespace: policy.Namespace}
+}
+
+// RuleReference identifies a rule of an internal NetworkPolicy.
+type RuleReference struct {
+	Policy PolicyReference `json:"policy"`
+	// Rule is the index of the rule in the policy.
+	Rule      int                `json:"rule"`
+	Direction ddlogk8s.Direction `json:"direction"`
+}
+
+func (r RuleReference) String() string {
+	return fmt.Sprintf("%s rule %d (%s)", r.Policy, r.Rule, r.Direction)
+}
+
+// AddressGroupMembership is an AddressGroup which contains the IP of a Pod, with the rules which
+// use it as a peer.
+type AddressGroupMembership struct {
+	Name  string          `json:"name"`
+	Rules []RuleReference `json:"rules"`
+}
+
+// PodExplanation lists the output objects which involve a Pod.
+type PodExplanation struct {
+	Pod      ddlogk8s.PodReference `json:"pod"`
+	IP       string                `json:"ip,omitempty"`
+	NodeName string                `json:"nodeName,omitempty"`
+	// IsolatedForIngress is true if at least one of the applied policies has the Ingress policy
+	// type, in which case only the ingress traffic allowed by one of these policies is accepted.
+	IsolatedForIngress bool `json:"isolatedForIngress"`
+	// IsolatedForEgress is the same as IsolatedForIngress, for egress traffic.
+	IsolatedForEgress bool `json:"isolatedForEgress"`
+	// AppliedToGroups are the AppliedToGroups which include the Pod.
+	AppliedToGroups []string `json:"appliedToGroups"`
+	// AppliedPolicies are the internal NetworkPolicies applied to one of AppliedToGroups, with
+	// their rules.
+	AppliedPolicies []*view.NetworkPolicy `json:"appliedPolicies"`
+	// AddressGroups are the AddressGroups which contain the Pod IP, i.e. through which the Pod is
+	// a peer of other policies.
+	AddressGroups []*AddressGroupMembership `json:"addressGroups"`
+}
+
+// hasPolicyType returns true if the policy types of the provided policy include policyType.
+func hasPolicyType(policy *view.NetworkPolicy, policyType networkingv1.PolicyType) bool {
+	for _, t := range policy.PolicyTypes {
+		if t == policyType {
+			return true
+		}
+	}
+	return false
+}
+
+// appliedToGroups returns the names of the AppliedToGroups which include the Pod.
+func appliedToGroups(v *view.View, pod ddlogk8s.PodReference) sets.String {
+	names := sets.NewString()
+	for _, group := range v.ListAppliedToGroups() {
+		for _, pods := range group.PodsByNode {
+			for _, p := range pods {
+				if p == pod {
+					names.Insert(group.Name)
+				}
+			}
+		}
+	}
+	return names
+}
+
+// appliedPolicies returns the policies applied to at least one of the provided groups, sorted by
+// Namespace and name.
+func appliedPolicies(policies []*view.NetworkPolicy, groups sets.String) []*view.NetworkPolicy {
+	result := make([]*view.NetworkPolicy, 0)
+	for _, policy := range policies {
+		if groups.HasAny(policy.AppliedToGroups...) {
+			result = append(result, policy)
+		}
+	}
+	return result
+}
+
+// addressGroupsWithIP returns the names of the AddressGroups which contain the IP.
+func addressGroupsWithIP(v *view.View, ip string) sets.String {
+	names := sets.NewString()
+	if ip == "" {
+		return names
+	}
+	for _, group := range v.ListAddressGroups() {
+		for _, address := range group.Addresses {
+			if address == ip {
+				names.Insert(group.Name)
+			}
+		}
+	}
+	return names
+}
+
+// ExplainPod returns the AppliedToGroups, policies and AddressGroups which involve the Pod.
+func ExplainPod(v *view.View, pod *v1.Pod) *PodExplanation {
+	ref := ddlogk8s.PodReference{Name: pod.Name, Namespace: pod.Namespace}
+	groups := appliedToGroups(v, ref)
+	policies := v.ListNetworkPolicies()
+	e := &PodExplanation{
+		Pod:             ref,
+		IP:              pod.Status.PodIP,
+		NodeName:        pod.Spec.NodeName,
+		AppliedToGroups: groups.List(),
+		AppliedPolicies: appliedPolicies(policies, groups),
+		AddressGroups:   make([]*AddressGroupMembership, 0),
+	}
+	for _, policy := range e.AppliedPolicies {
+		e.IsolatedForIngress = e.IsolatedForIngress || hasPolicyType(policy, networkingv1.PolicyTypeIngress)
+		e.IsolatedForEgress = e.IsolatedForEgress || hasPolicyType(policy, networkingv1.PolicyTypeEgress)
+	}
+	for _, name := range addressGroupsWithIP(v, e.IP).List() {
+		membership := &AddressGroupMembership{Name: name, Rules: make([]RuleReference, 0)}
+		for _, policy := range policies {
+			for i := range policy.Rules {
+				rule := &policy.Rules[i]
+				peer := &rule.From
+				if rule.Direction == ddlogk8s.DirectionOut {
+					peer = &rule.To
+				}
+				if sets.NewString(peer.AddressGroups...).Has(name) {
+					membership.Rules = append(membership.Rules, RuleReference{
+						Policy:    policyReference(policy),
+						Rule:      i,
+						Direction: rule.Direction,
+					})
+				}
+			}
+		}
+		e.AddressGroups = append(e.AddressGroups, membership)
+	}
+	return e
+}
+
+func formatPeer(peer *ddlogk8s.InternalNetworkPolicyPeer) string {
+	var parts []string
+	if len(peer.AddressGroups) > 0 {
+		parts = append(parts, fmt.Sprintf("addressGroups=[%s]", strings.Join(peer.AddressGroups, ", ")))
+	}
+	for _, ipBlock := range peer.IPBlocks {
+		block := "ipBlock=" + ipBlock.CIDR
+		if len(ipBlock.Except) > 0 {
+			block += fmt.Sprintf(" except [%s]", strings.Join(ipBlock.Except, ", "))
+		}
+		parts = append(parts, block)
+	}
+	if len(parts) == 0 {
+		return "nothing"
+	}
+	return strings.Join(parts, ", ")
+}
+
+func formatPort(port *networkingv1.NetworkPolicyPort) string {
+	protocol := "TCP"
+	if port.Protocol != nil {
+		protocol = string(*port.Protocol)
+	}
+	if port.Port == nil {
+		return protocol
+	}
+	return protocol + "/" + port.Port.String()
+}
+
+// formatRule returns a one-line description of a rule, e.g. "In from addressGroups=[...] on
+// TCP/80".
+func formatRule(rule *ddlogk8s.InternalNetworkPolicyRule) string {
+	var s string
+	if rule.Direction == ddlogk8s.DirectionOut {
+		s = "Out to " + formatPeer(&rule.To)
+	} else {
+		s = "In from " + formatPeer(&rule.From)
+	}
+	if len(rule.Services) == 0 {
+		return s + " on all ports"
+	}
+	ports := make([]string, len(rule.Services))
+	for i := range rule.Services {
+		ports[i] = formatPort(&rule.Services[i])
+	}
+	return s + " on " + strings.Join(ports, ", ")
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
+
+// Print writes a human-readable version of the explanation.
+func (e *PodExplanation) Print(w io.Writer) {
+	fmt.Fprintf(w, "Pod %s/%s", e.Pod.Namespace, e.Pod.Name)
+	if e.IP != "" {
+		fmt.Fprintf(w, ", IP %s", e.IP)
+	}
+	if e.NodeName != "" {
+		fmt.Fprintf(w, ", Node %s", e.NodeName)
+	}
+	fmt.Fprintf(w, "\nIsolated for ingress: %s\nIsolated for egress: %s\n", yesNo(e.IsolatedForIngress), yesNo(e.IsolatedForEgress))
+	fmt.Fprintf(w, "AppliedToGroups:\n")
+	for _, name := range e.AppliedToGroups {
+		fmt.Fprintf(w, "  %s\n", name)
+	}
+	fmt.Fprintf(w, "NetworkPolicies applied to the Pod:\n")
+	for _, policy := range e.AppliedPolicies {
+		policyTypes := make([]string, len(policy.PolicyTypes))
+		for i, t := range policy.PolicyTypes {
+			policyTypes[i] = string(t)
+		}
+		fmt.Fprintf(w, "  %s (uid %s, policy types: %s)\n", policyReference(policy), policy.UID, strings.Join(policyTypes, ", "))
+		for i := range policy.Rules {
+			fmt.Fprintf(w, "    rule %d: %s\n", i, formatRule(&policy.Rules[i]))
+		}
+	}
+	fmt.Fprintf(w, "AddressGroups containing the Pod IP:\n")
+	for _, group := range e.AddressGroups {
+		fmt.Fprintf(w, "  %s\n", group.Name)
+		for _, rule := range group.Rules {
+			fmt.Fprintf(w, "    peer of %s\n", rule)
+		}
+	}
+}
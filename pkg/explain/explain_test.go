@@ -0,0 +1,167 @@
+// Copyright 2020 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package explain
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/antoninbas/antrea-k8s-to-ddlog/pkg/ddlogk8s"
+	"github.com/antoninbas/antrea-k8s-to-ddlog/pkg/view"
+)
+
+var (
+	pod1 = &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "pod1", Namespace: "ns1"},
+		Spec: v1.PodSpec{
+			NodeName: "node-1",
+			Containers: []v1.Container{{
+				Name:  "web",
+				Ports: []v1.ContainerPort{{Name: "http", ContainerPort: 8080}},
+			}},
+		},
+		Status: v1.PodStatus{PodIP: "10.0.0.1"},
+	}
+	pod2 = &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "pod2", Namespace: "ns1"},
+		Spec:       v1.PodSpec{NodeName: "node-2"},
+		Status:     v1.PodStatus{PodIP: "10.0.0.2"},
+	}
+)
+
+// newTestView returns a view in which policy np1 applies to pod1 and allows ingress traffic from
+// pod2 on TCP port 80, and policy np2 applies to pod2 with no rule, which isolates it for egress.
+func newTestView() *view.View {
+	v := view.NewView()
+	tcp := v1.ProtocolTCP
+	port80 := intstr.FromInt(80)
+	outputs := []interface{}{
+		&ddlogk8s.AppliedToGroup{Name: "atg1"},
+		&ddlogk8s.AppliedToGroupPodsByNode{
+			AppliedToGroup: "atg1",
+			NodeName:       "node-1",
+			Pods:           []ddlogk8s.PodReference{{Name: "pod1", Namespace: "ns1"}},
+		},
+		&ddlogk8s.AppliedToGroup{Name: "atg2"},
+		&ddlogk8s.AppliedToGroupPodsByNode{
+			AppliedToGroup: "atg2",
+			NodeName:       "node-2",
+			Pods:           []ddlogk8s.PodReference{{Name: "pod2", Namespace: "ns1"}},
+		},
+		&ddlogk8s.AddressGroup{Name: "ag1"},
+		&ddlogk8s.AddressGroupAddress{AddressGroup: "ag1", Address: "10.0.0.2"},
+		&ddlogk8s.InternalNetworkPolicy{
+			UID:       "uid1",
+			Name:      "np1",
+			Namespace: "ns1",
+			Rules: []ddlogk8s.InternalNetworkPolicyRule{{
+				Direction: ddlogk8s.DirectionIn,
+				From:      ddlogk8s.InternalNetworkPolicyPeer{AddressGroups: []string{"ag1"}},
+				Services:  []networkingv1.NetworkPolicyPort{{Protocol: &tcp, Port: &port80}},
+			}},
+			AppliedToGroups: []string{"atg1"},
+			PolicyTypes:     []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+		},
+		&ddlogk8s.InternalNetworkPolicy{
+			UID:             "uid2",
+			Name:            "np2",
+			Namespace:       "ns1",
+			AppliedToGroups: []string{"atg2"},
+			PolicyTypes:     []networkingv1.PolicyType{networkingv1.PolicyTypeEgress},
+		},
+	}
+	for _, obj := range outputs {
+		v.HandleOutput(obj, true)
+	}
+	return v
+}
+
+func newTestPodLister(pods ...*v1.Pod) corelisters.PodLister {
+	indexer := cache.NewIndexer(cache.MetaNamespaceKeyFunc, cache.Indexers{cache.NamespaceIndex: cache.MetaNamespaceIndexFunc})
+	for _, pod := range pods {
+		indexer.Add(pod)
+	}
+	return corelisters.NewPodLister(indexer)
+}
+
+func TestExplainPod(t *testing.T) {
+	v := newTestView()
+
+	e := ExplainPod(v, pod1)
+	assert.Equal(t, "10.0.0.1", e.IP)
+	assert.True(t, e.IsolatedForIngress)
+	assert.False(t, e.IsolatedForEgress)
+	assert.Equal(t, []string{"atg1"}, e.AppliedToGroups)
+	require.Len(t, e.AppliedPolicies, 1)
+	assert.Equal(t, "np1", e.AppliedPolicies[0].Name)
+	assert.Empty(t, e.AddressGroups)
+
+	e = ExplainPod(v, pod2)
+	assert.False(t, e.IsolatedForIngress)
+	assert.True(t, e.IsolatedForEgress)
+	assert.Equal(t, []string{"atg2"}, e.AppliedToGroups)
+	require.Len(t, e.AppliedPolicies, 1)
+	assert.Equal(t, "np2", e.AppliedPolicies[0].Name)
+	assert.Equal(t, []*AddressGroupMembership{{
+		Name: "ag1",
+		Rules: []RuleReference{{
+			Policy:    PolicyReference{UID: "uid1", Name: "np1", Namespace: "ns1"},
+			Rule:      0,
+			Direction: ddlogk8s.DirectionIn,
+		}},
+	}}, e.AddressGroups)
+
+	var b bytes.Buffer
+	ExplainPod(v, pod1).Print(&b)
+	assert.Contains(t, b.String(), "    rule 0: In from addressGroups=[ag1] on TCP/80\n")
+}
+
+func TestHandler(t *testing.T) {
+	handler := NewHandler(newTestView(), newTestPodLister(pod1, pod2))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/explain/pods/ns1/pod2", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+	var e PodExplanation
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &e))
+	assert.Equal(t, ddlogk8s.PodReference{Name: "pod2", Namespace: "ns1"}, e.Pod)
+	assert.Equal(t, []string{"atg2"}, e.AppliedToGroups)
+
+	for _, tc := range []struct {
+		method string
+		path   string
+		code   int
+	}{
+		{http.MethodGet, "/explain/pods/ns1/pod3", http.StatusNotFound},
+		{http.MethodGet, "/explain/pods/ns1", http.StatusNotFound},
+		{http.MethodPost, "/explain/pods/ns1/pod1", http.StatusMethodNotAllowed},
+	} {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(tc.method, tc.path, nil))
+		assert.Equal(t, tc.code, rr.Code, "%s %s", tc.method, tc.path)
+	}
+}
@@ -0,0 +1,164 @@
+// Copyright 2020 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package explain
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	"k8s.io/klog"
+
+	"github.com/antoninbas/antrea-k8s-to-ddlog/pkg/view"
+)
+
+const (
+	// PathPrefix is the path prefix under which all the explain endpoints are served.
+	PathPrefix = "/explain/"
+
+	podsPath         = "/explain/pods/"
+	reachabilityPath = "/explain/reachability"
+
+	// The query parameters of the reachability endpoint.
+	FromParam     = "from"
+	ToParam       = "to"
+	ProtocolParam = "protocol"
+	PortParam     = "port"
+)
+
+// NewHandler returns a handler serving, as JSON:
+//   - GET /explain/pods/<namespace>/<name>, which returns the PodExplanation for a Pod;
+//   - GET /explain/reachability?from=<namespace>/<name>&to=<namespace>/<name>&protocol=<protocol>&port=<port>,
+//     which returns the Reachability between two Pods. The protocol defaults to TCP, the port can
+//     be a number or the name of a container port of the destination Pod.
+//
+// Pods are looked up with podLister.
+func NewHandler(v *view.View, podLister corelisters.PodLister) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(podsPath, func(w http.ResponseWriter, r *http.Request) {
+		handlePod(v, podLister, w, r)
+	})
+	mux.HandleFunc(reachabilityPath, func(w http.ResponseWriter, r *http.Request) {
+		handleReachability(v, podLister, w, r)
+	})
+	return mux
+}
+
+// ReachabilityPath returns the path and query of the reachability endpoint for the provided
+// parameters.
+func ReachabilityPath(from, to string, protocol v1.Protocol, port string) string {
+	query := url.Values{
+		FromParam:     []string{from},
+		ToParam:       []string{to},
+		ProtocolParam: []string{string(protocol)},
+		PortParam:     []string{port},
+	}
+	return reachabilityPath + "?" + query.Encode()
+}
+
+func writeJSON(w http.ResponseWriter, obj interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(obj); err != nil {
+		klog.Errorf("Error when writing response: %v", err)
+	}
+}
+
+func handlePod(v *view.View, podLister corelisters.PodLister, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "only GET is supported", http.StatusMethodNotAllowed)
+		return
+	}
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, podsPath), "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		http.Error(w, fmt.Sprintf("path must be %s<namespace>/<name>", podsPath), http.StatusNotFound)
+		return
+	}
+	pod, ok := getPod(podLister, w, parts[0], parts[1])
+	if !ok {
+		return
+	}
+	writeJSON(w, ExplainPod(v, pod))
+}
+
+// getPod looks up a Pod and writes an error response if it cannot be found.
+func getPod(podLister corelisters.PodLister, w http.ResponseWriter, namespace, name string) (*v1.Pod, bool) {
+	pod, err := podLister.Pods(namespace).Get(name)
+	if errors.IsNotFound(err) {
+		http.Error(w, fmt.Sprintf("Pod %s/%s not found", namespace, name), http.StatusNotFound)
+		return nil, false
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return nil, false
+	}
+	return pod, true
+}
+
+// ParsePodKey splits a "<namespace>/<name>" Pod key.
+func ParsePodKey(key string) (string, string, error) {
+	parts := strings.Split(key, "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", fmt.Errorf("invalid Pod '%s', must be <namespace>/<name>", key)
+	}
+	return parts[0], parts[1], nil
+}
+
+// ParsePort parses a port number or name.
+func ParsePort(port string) intstr.IntOrString {
+	if n, err := strconv.Atoi(port); err == nil {
+		return intstr.FromInt(n)
+	}
+	return intstr.FromString(port)
+}
+
+func handleReachability(v *view.View, podLister corelisters.PodLister, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "only GET is supported", http.StatusMethodNotAllowed)
+		return
+	}
+	query := r.URL.Query()
+	var pods [2]*v1.Pod
+	for i, param := range []string{FromParam, ToParam} {
+		namespace, name, err := ParsePodKey(query.Get(param))
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid '%s' parameter: %v", param, err), http.StatusBadRequest)
+			return
+		}
+		var ok bool
+		if pods[i], ok = getPod(podLister, w, namespace, name); !ok {
+			return
+		}
+	}
+	protocol := v1.Protocol(strings.ToUpper(query.Get(ProtocolParam)))
+	if protocol == "" {
+		protocol = v1.ProtocolTCP
+	}
+	if query.Get(PortParam) == "" {
+		http.Error(w, fmt.Sprintf("missing '%s' parameter", PortParam), http.StatusBadRequest)
+		return
+	}
+	result, err := CheckReachability(v, pods[0], pods[1], protocol, ParsePort(query.Get(PortParam)))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, result)
+}
@@ -0,0 +1,249 @@
+// Copyright 2020 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package explain
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"strings"
+
+	"k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	"github.com/antoninbas/antrea-k8s-to-ddlog/pkg/ddlogk8s"
+	"github.com/antoninbas/antrea-k8s-to-ddlog/pkg/view"
+)
+
+// DirectionReachability is the result of the evaluation of the policies applied to one end of the
+// traffic: egress policies for the source Pod, ingress policies for the destination Pod.
+type DirectionReachability struct {
+	// Isolated is true if at least one policy applied to the Pod has the policy type for this
+	// direction. Traffic is always allowed if the Pod is not isolated.
+	Isolated bool `json:"isolated"`
+	// IsolatedBy are the policies which isolate the Pod.
+	IsolatedBy []PolicyReference `json:"isolatedBy"`
+	Allowed    bool              `json:"allowed"`
+	// AllowedBy are the rules of the isolating policies which match the traffic.
+	AllowedBy []RuleReference `json:"allowedBy"`
+}
+
+// Reachability is the result of the evaluation of the NetworkPolicies for traffic between two Pods.
+type Reachability struct {
+	Source        ddlogk8s.PodReference `json:"source"`
+	SourceIP      string                `json:"sourceIP"`
+	Destination   ddlogk8s.PodReference `json:"destination"`
+	DestinationIP string                `json:"destinationIP"`
+	Protocol      v1.Protocol           `json:"protocol"`
+	Port          int32                 `json:"port"`
+	Allowed       bool                  `json:"allowed"`
+	Egress        DirectionReachability `json:"egress"`
+	Ingress       DirectionReachability `json:"ingress"`
+}
+
+// ResolvePort returns the port number for a port which can be a number or the name of a container
+// port of the Pod with the provided protocol.
+func ResolvePort(pod *v1.Pod, port intstr.IntOrString, protocol v1.Protocol) (int32, error) {
+	if port.Type == intstr.Int {
+		if port.IntVal <= 0 || port.IntVal > 65535 {
+			return 0, fmt.Errorf("invalid port %d", port.IntVal)
+		}
+		return port.IntVal, nil
+	}
+	for _, container := range pod.Spec.Containers {
+		for _, p := range container.Ports {
+			if p.Name == port.StrVal && containerPortProtocol(&p) == protocol {
+				return p.ContainerPort, nil
+			}
+		}
+	}
+	return 0, fmt.Errorf("Pod %s/%s has no %s port named '%s'", pod.Namespace, pod.Name, protocol, port.StrVal)
+}
+
+func containerPortProtocol(p *v1.ContainerPort) v1.Protocol {
+	if p.Protocol == "" {
+		return v1.ProtocolTCP
+	}
+	return p.Protocol
+}
+
+// serviceMatches returns true if the traffic to the destination Pod on the provided protocol and
+// port number matches the NetworkPolicyPort. Named ports are resolved against the container ports
+// of the destination Pod, like the Antrea agent does.
+func serviceMatches(service *networkingv1.NetworkPolicyPort, dst *v1.Pod, protocol v1.Protocol, port int32) bool {
+	serviceProtocol := v1.ProtocolTCP
+	if service.Protocol != nil {
+		serviceProtocol = *service.Protocol
+	}
+	if serviceProtocol != protocol {
+		return false
+	}
+	if service.Port == nil {
+		return true
+	}
+	resolved, err := ResolvePort(dst, *service.Port, protocol)
+	return err == nil && resolved == port
+}
+
+func servicesMatch(services []networkingv1.NetworkPolicyPort, dst *v1.Pod, protocol v1.Protocol, port int32) bool {
+	if len(services) == 0 {
+		return true
+	}
+	for i := range services {
+		if serviceMatches(&services[i], dst, protocol, port) {
+			return true
+		}
+	}
+	return false
+}
+
+// ipBlockContains returns true if the IP is in the CIDR of the IPBlock and in none of the except
+// ranges. Invalid CIDRs never match.
+func ipBlockContains(ipBlock *networkingv1.IPBlock, ip net.IP) bool {
+	_, cidr, err := net.ParseCIDR(ipBlock.CIDR)
+	if err != nil || !cidr.Contains(ip) {
+		return false
+	}
+	for _, except := range ipBlock.Except {
+		_, exceptNet, err := net.ParseCIDR(except)
+		if err == nil && exceptNet.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// peerContains returns true if the IP is in one of the AddressGroups or IPBlocks of the peer.
+func peerContains(v *view.View, peer *ddlogk8s.InternalNetworkPolicyPeer, ip string) bool {
+	for _, name := range peer.AddressGroups {
+		addresses, _ := v.ListAddressGroupAddresses(name)
+		for _, address := range addresses {
+			if address == ip {
+				return true
+			}
+		}
+	}
+	parsedIP := net.ParseIP(ip)
+	if parsedIP == nil {
+		return false
+	}
+	for i := range peer.IPBlocks {
+		if ipBlockContains(&peer.IPBlocks[i], parsedIP) {
+			return true
+		}
+	}
+	return false
+}
+
+// evaluateDirection evaluates the policies applied to pod for the provided direction. peerIP is the
+// IP of the other end of the traffic, dst is always the destination Pod, against which named ports
+// are resolved.
+func evaluateDirection(v *view.View, policies []*view.NetworkPolicy, pod *v1.Pod, direction ddlogk8s.Direction, peerIP string, dst *v1.Pod, protocol v1.Protocol, port int32) DirectionReachability {
+	policyType := networkingv1.PolicyTypeIngress
+	if direction == ddlogk8s.DirectionOut {
+		policyType = networkingv1.PolicyTypeEgress
+	}
+	groups := appliedToGroups(v, ddlogk8s.PodReference{Name: pod.Name, Namespace: pod.Namespace})
+	r := DirectionReachability{IsolatedBy: make([]PolicyReference, 0), AllowedBy: make([]RuleReference, 0)}
+	for _, policy := range appliedPolicies(policies, groups) {
+		if !hasPolicyType(policy, policyType) {
+			continue
+		}
+		r.IsolatedBy = append(r.IsolatedBy, policyReference(policy))
+		for i := range policy.Rules {
+			rule := &policy.Rules[i]
+			if rule.Direction != direction {
+				continue
+			}
+			peer := &rule.From
+			if direction == ddlogk8s.DirectionOut {
+				peer = &rule.To
+			}
+			if peerContains(v, peer, peerIP) && servicesMatch(rule.Services, dst, protocol, port) {
+				r.AllowedBy = append(r.AllowedBy, RuleReference{Policy: policyReference(policy), Rule: i, Direction: direction})
+			}
+		}
+	}
+	r.Isolated = len(r.IsolatedBy) > 0
+	r.Allowed = !r.Isolated || len(r.AllowedBy) > 0
+	return r
+}
+
+// CheckReachability evaluates whether traffic from src to dst, with the provided protocol and
+// destination port, is allowed by the NetworkPolicies. port can be a number or the name of a
+// container port of dst. Traffic must be allowed both by the egress policies applied to src and by
+// the ingress policies applied to dst.
+func CheckReachability(v *view.View, src, dst *v1.Pod, protocol v1.Protocol, port intstr.IntOrString) (*Reachability, error) {
+	switch protocol {
+	case v1.ProtocolTCP, v1.ProtocolUDP, v1.ProtocolSCTP:
+	default:
+		return nil, fmt.Errorf("invalid protocol '%s', must be one of TCP, UDP or SCTP", protocol)
+	}
+	for _, pod := range []*v1.Pod{src, dst} {
+		if pod.Status.PodIP == "" {
+			return nil, fmt.Errorf("Pod %s/%s has no IP", pod.Namespace, pod.Name)
+		}
+	}
+	portNumber, err := ResolvePort(dst, port, protocol)
+	if err != nil {
+		return nil, err
+	}
+	policies := v.ListNetworkPolicies()
+	r := &Reachability{
+		Source:        ddlogk8s.PodReference{Name: src.Name, Namespace: src.Namespace},
+		SourceIP:      src.Status.PodIP,
+		Destination:   ddlogk8s.PodReference{Name: dst.Name, Namespace: dst.Namespace},
+		DestinationIP: dst.Status.PodIP,
+		Protocol:      protocol,
+		Port:          portNumber,
+		Egress:        evaluateDirection(v, policies, src, ddlogk8s.DirectionOut, dst.Status.PodIP, dst, protocol, portNumber),
+		Ingress:       evaluateDirection(v, policies, dst, ddlogk8s.DirectionIn, src.Status.PodIP, dst, protocol, portNumber),
+	}
+	r.Allowed = r.Egress.Allowed && r.Ingress.Allowed
+	return r, nil
+}
+
+func allowedOrDenied(allowed bool) string {
+	if allowed {
+		return "allowed"
+	}
+	return "denied"
+}
+
+func printDirection(w io.Writer, title string, r *DirectionReachability) {
+	if !r.Isolated {
+		fmt.Fprintf(w, "%s: allowed, no policy isolates the Pod\n", title)
+		return
+	}
+	policies := make([]string, len(r.IsolatedBy))
+	for i, p := range r.IsolatedBy {
+		policies[i] = p.String()
+	}
+	fmt.Fprintf(w, "%s: %s, Pod isolated by %s\n", title, allowedOrDenied(r.Allowed), strings.Join(policies, ", "))
+	for _, rule := range r.AllowedBy {
+		fmt.Fprintf(w, "  allowed by %s\n", rule)
+	}
+}
+
+// Print writes a human-readable version of the result.
+func (r *Reachability) Print(w io.Writer) {
+	fmt.Fprintf(w, "Traffic from %s/%s (%s) to %s/%s (%s) on %s/%d: %s\n",
+		r.Source.Namespace, r.Source.Name, r.SourceIP,
+		r.Destination.Namespace, r.Destination.Name, r.DestinationIP,
+		r.Protocol, r.Port, allowedOrDenied(r.Allowed))
+	printDirection(w, fmt.Sprintf("Egress from %s/%s", r.Source.Namespace, r.Source.Name), &r.Egress)
+	printDirection(w, fmt.Sprintf("Ingress to %s/%s", r.Destination.Namespace, r.Destination.Name), &r.Ingress)
+}
@@ -0,0 +1,176 @@
+// Copyright 2020 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package explain
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	"github.com/antoninbas/antrea-k8s-to-ddlog/pkg/ddlogk8s"
+)
+
+var pod3 = &v1.Pod{
+	ObjectMeta: metav1.ObjectMeta{Name: "pod3", Namespace: "ns2"},
+	Spec:       v1.PodSpec{NodeName: "node-1"},
+	Status:     v1.PodStatus{PodIP: "10.0.0.3"},
+}
+
+func TestCheckReachability(t *testing.T) {
+	v := newTestView()
+	// np3 allows ingress traffic to pod1 from 10.0.0.0/24, except from pod2, on the "http"
+	// named port.
+	httpPort := intstr.FromString("http")
+	v.HandleOutput(&ddlogk8s.InternalNetworkPolicy{
+		UID:       "uid3",
+		Name:      "np3",
+		Namespace: "ns1",
+		Rules: []ddlogk8s.InternalNetworkPolicyRule{{
+			Direction: ddlogk8s.DirectionIn,
+			From: ddlogk8s.InternalNetworkPolicyPeer{
+				IPBlocks: []networkingv1.IPBlock{{CIDR: "10.0.0.0/24", Except: []string{"10.0.0.2/32"}}},
+			},
+			Services: []networkingv1.NetworkPolicyPort{{Port: &httpPort}},
+		}},
+		AppliedToGroups: []string{"atg1"},
+		PolicyTypes:     []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+	}, true)
+	np1 := PolicyReference{UID: "uid1", Name: "np1", Namespace: "ns1"}
+	np2 := PolicyReference{UID: "uid2", Name: "np2", Namespace: "ns1"}
+	np3 := PolicyReference{UID: "uid3", Name: "np3", Namespace: "ns1"}
+
+	for _, tc := range []struct {
+		name     string
+		src, dst *v1.Pod
+		protocol v1.Protocol
+		port     intstr.IntOrString
+		egress   DirectionReachability
+		ingress  DirectionReachability
+	}{
+		{
+			name:     "no isolation",
+			src:      pod1,
+			dst:      pod2,
+			protocol: v1.ProtocolTCP,
+			port:     intstr.FromInt(80),
+			egress:   DirectionReachability{Allowed: true, IsolatedBy: []PolicyReference{}, AllowedBy: []RuleReference{}},
+			ingress:  DirectionReachability{Allowed: true, IsolatedBy: []PolicyReference{}, AllowedBy: []RuleReference{}},
+		},
+		{
+			name:     "egress isolation",
+			src:      pod2,
+			dst:      pod1,
+			protocol: v1.ProtocolTCP,
+			port:     intstr.FromInt(80),
+			egress:   DirectionReachability{Isolated: true, IsolatedBy: []PolicyReference{np2}, AllowedBy: []RuleReference{}},
+			ingress: DirectionReachability{
+				Isolated:   true,
+				IsolatedBy: []PolicyReference{np1, np3},
+				Allowed:    true,
+				AllowedBy:  []RuleReference{{Policy: np1, Rule: 0, Direction: ddlogk8s.DirectionIn}},
+			},
+		},
+		{
+			name:     "wrong protocol",
+			src:      pod3,
+			dst:      pod1,
+			protocol: v1.ProtocolUDP,
+			port:     intstr.FromInt(80),
+			egress:   DirectionReachability{Allowed: true, IsolatedBy: []PolicyReference{}, AllowedBy: []RuleReference{}},
+			ingress:  DirectionReachability{Isolated: true, IsolatedBy: []PolicyReference{np1, np3}, AllowedBy: []RuleReference{}},
+		},
+		{
+			name:     "named port",
+			src:      pod3,
+			dst:      pod1,
+			protocol: v1.ProtocolTCP,
+			port:     intstr.FromInt(8080),
+			egress:   DirectionReachability{Allowed: true, IsolatedBy: []PolicyReference{}, AllowedBy: []RuleReference{}},
+			ingress: DirectionReachability{
+				Isolated:   true,
+				IsolatedBy: []PolicyReference{np1, np3},
+				Allowed:    true,
+				AllowedBy:  []RuleReference{{Policy: np3, Rule: 0, Direction: ddlogk8s.DirectionIn}},
+			},
+		},
+		{
+			name:     "named port in query",
+			src:      pod3,
+			dst:      pod1,
+			protocol: v1.ProtocolTCP,
+			port:     intstr.FromString("http"),
+			egress:   DirectionReachability{Allowed: true, IsolatedBy: []PolicyReference{}, AllowedBy: []RuleReference{}},
+			ingress: DirectionReachability{
+				Isolated:   true,
+				IsolatedBy: []PolicyReference{np1, np3},
+				Allowed:    true,
+				AllowedBy:  []RuleReference{{Policy: np3, Rule: 0, Direction: ddlogk8s.DirectionIn}},
+			},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r, err := CheckReachability(v, tc.src, tc.dst, tc.protocol, tc.port)
+			require.NoError(t, err)
+			assert.Equal(t, tc.egress, r.Egress)
+			assert.Equal(t, tc.ingress, r.Ingress)
+			assert.Equal(t, tc.egress.Allowed && tc.ingress.Allowed, r.Allowed)
+		})
+	}
+
+	// pod2 is in the except range of np3.
+	r, err := CheckReachability(v, pod2, pod1, v1.ProtocolTCP, intstr.FromInt(8080))
+	require.NoError(t, err)
+	assert.False(t, r.Ingress.Allowed)
+
+	_, err = CheckReachability(v, pod1, pod2, v1.ProtocolTCP, intstr.FromString("http"))
+	assert.Error(t, err, "pod2 has no named port")
+	_, err = CheckReachability(v, pod1, pod2, "ICMP", intstr.FromInt(80))
+	assert.Error(t, err)
+}
+
+func TestReachabilityHandler(t *testing.T) {
+	handler := NewHandler(newTestView(), newTestPodLister(pod1, pod2))
+
+	rr := httptest.NewRecorder()
+	handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, ReachabilityPath("ns1/pod2", "ns1/pod1", v1.ProtocolTCP, "80"), nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+	var r Reachability
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &r))
+	assert.False(t, r.Allowed)
+	assert.True(t, r.Ingress.Allowed)
+	assert.Equal(t, int32(80), r.Port)
+
+	for _, tc := range []struct {
+		path string
+		code int
+	}{
+		{ReachabilityPath("ns1/pod2", "ns1/pod3", v1.ProtocolTCP, "80"), http.StatusNotFound},
+		{ReachabilityPath("pod2", "ns1/pod1", v1.ProtocolTCP, "80"), http.StatusBadRequest},
+		{ReachabilityPath("ns1/pod2", "ns1/pod1", v1.ProtocolTCP, ""), http.StatusBadRequest},
+		{ReachabilityPath("ns1/pod2", "ns1/pod1", v1.ProtocolTCP, "dns"), http.StatusBadRequest},
+	} {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, tc.path, nil))
+		assert.Equal(t, tc.code, rr.Code, tc.path)
+	}
+}
@@ -0,0 +1,355 @@
+// Copyright 2020 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package lint analyzes NetworkPolicies to find likely mistakes, such as selectors which match
+// nothing or rules which have no effect. The analysis uses both the K8s objects and the DDlog
+// output relations: group membership is taken from the outputs, so that it reflects what Antrea
+// agents would enforce.
+package lint
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"github.com/antoninbas/antrea-k8s-to-ddlog/pkg/ddlogk8s"
+	"github.com/antoninbas/antrea-k8s-to-ddlog/pkg/reference"
+	"github.com/antoninbas/antrea-k8s-to-ddlog/pkg/view"
+)
+
+// Check identifies the kind of problem reported by a Finding.
+type Check string
+
+const (
+	// CheckPolicySelectsNoPods is reported for NetworkPolicies whose podSelector matches no Pod.
+	CheckPolicySelectsNoPods Check = "PolicySelectsNoPods"
+	// CheckPeerMatchesNothing is reported for rule peers which select no Pod, or IPBlocks which
+	// are entirely excluded by one of their except ranges.
+	CheckPeerMatchesNothing Check = "PeerMatchesNothing"
+	// CheckShadowedRule is reported for rules which allow a subset of the traffic allowed by
+	// another rule in the same Namespace, for the same Pods (or a superset).
+	CheckShadowedRule Check = "ShadowedRule"
+	// CheckOverlappingIPBlocks is reported for IPBlocks which overlap with another IPBlock of the
+	// same rule. IPBlocks of different rules are not compared: the rules may allow different
+	// ports, in which case overlapping CIDRs are expected.
+	CheckOverlappingIPBlocks Check = "OverlappingIPBlocks"
+	// CheckExceptOutsideCIDR is reported for except ranges which are not included in the CIDR of
+	// their IPBlock, and therefore have no effect.
+	CheckExceptOutsideCIDR Check = "ExceptOutsideCIDR"
+	// CheckNamespaceNotIsolated is reported for Namespaces in which no Pod is selected by a
+	// NetworkPolicy.
+	CheckNamespaceNotIsolated Check = "NamespaceNotIsolated"
+)
+
+// Severity is the severity of a Finding.
+type Severity string
+
+const (
+	SeverityWarning Severity = "warning"
+	SeverityInfo    Severity = "info"
+)
+
+var severities = map[Check]Severity{
+	CheckPolicySelectsNoPods:  SeverityWarning,
+	CheckPeerMatchesNothing:   SeverityWarning,
+	CheckShadowedRule:         SeverityWarning,
+	CheckOverlappingIPBlocks:  SeverityWarning,
+	CheckExceptOutsideCIDR:    SeverityWarning,
+	CheckNamespaceNotIsolated: SeverityInfo,
+}
+
+// Finding is a problem found by the analysis.
+type Finding struct {
+	Check     Check    `json:"check"`
+	Severity  Severity `json:"severity"`
+	Namespace string   `json:"namespace"`
+	// NetworkPolicy is empty for findings about a Namespace.
+	NetworkPolicy string `json:"networkPolicy,omitempty"`
+	// Rule identifies a rule of the NetworkPolicy, e.g. "ingress[0]" or "egress[1]".
+	Rule string `json:"rule,omitempty"`
+	// Peer is the index of the peer in the rule, for findings about a peer.
+	Peer    *int   `json:"peer,omitempty"`
+	Message string `json:"message"`
+}
+
+func (f *Finding) location() string {
+	location := f.Namespace
+	if f.NetworkPolicy != "" {
+		location += "/" + f.NetworkPolicy
+	}
+	if f.Rule != "" {
+		location += " " + f.Rule
+	}
+	if f.Peer != nil {
+		location += fmt.Sprintf(" peer %d", *f.Peer)
+	}
+	return location
+}
+
+// Report is the result of the analysis.
+type Report struct {
+	// Findings are sorted by Namespace, NetworkPolicy, rule and peer.
+	Findings []Finding `json:"findings"`
+	// Counts is the number of findings for each check.
+	Counts map[Check]int `json:"counts"`
+}
+
+// WriteJSON writes the report as indented JSON.
+func (r *Report) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
+
+// Print writes a human-readable version of the report, one line per finding.
+func (r *Report) Print(w io.Writer) {
+	for i := range r.Findings {
+		f := &r.Findings[i]
+		fmt.Fprintf(w, "%-7s %s: %s: %s\n", f.Severity, f.location(), f.Check, f.Message)
+	}
+	fmt.Fprintf(w, "%d finding(s)\n", len(r.Findings))
+}
+
+type analyzer struct {
+	inputs   *reference.Inputs
+	view     *view.View
+	selector *reference.Selector
+	// policies are the K8s NetworkPolicies, indexed by UID.
+	policies map[types.UID]*networkingv1.NetworkPolicy
+	// groupPods are the Pods in each AppliedToGroup, as "<namespace>/<name>" keys.
+	groupPods map[string]sets.String
+	findings  []Finding
+}
+
+// Analyze runs all the checks. inputs are the K8s objects from which the outputs in v were
+// computed.
+func Analyze(inputs *reference.Inputs, v *view.View) *Report {
+	a := &analyzer{
+		inputs:    inputs,
+		view:      v,
+		selector:  reference.NewSelector(inputs),
+		policies:  make(map[types.UID]*networkingv1.NetworkPolicy, len(inputs.NetworkPolicies)),
+		groupPods: make(map[string]sets.String),
+	}
+	for _, np := range inputs.NetworkPolicies {
+		a.policies[np.UID] = np
+	}
+	for _, group := range v.ListAppliedToGroups() {
+		pods := sets.NewString()
+		for _, podsByNode := range group.PodsByNode {
+			for _, pod := range podsByNode {
+				pods.Insert(pod.Namespace + "/" + pod.Name)
+			}
+		}
+		a.groupPods[group.Name] = pods
+	}
+
+	for _, np := range inputs.NetworkPolicies {
+		a.checkPolicySelectsNoPods(np)
+		a.checkIngressRules(np)
+		a.checkEgressRules(np)
+	}
+	a.checkShadowedRules()
+	a.checkNamespacesNotIsolated()
+
+	sortFindings(a.findings)
+	report := &Report{Findings: a.findings, Counts: make(map[Check]int)}
+	if report.Findings == nil {
+		report.Findings = []Finding{}
+	}
+	for _, f := range report.Findings {
+		report.Counts[f.Check]++
+	}
+	return report
+}
+
+// sortFindings sorts findings by Namespace, NetworkPolicy, rule and peer. Rules are sorted by kind,
+// then by index, so that e.g. "ingress[2]" comes before "ingress[10]".
+func sortFindings(findings []Finding) {
+	sort.SliceStable(findings, func(i, j int) bool {
+		fi, fj := &findings[i], &findings[j]
+		if fi.Namespace != fj.Namespace {
+			return fi.Namespace < fj.Namespace
+		}
+		if fi.NetworkPolicy != fj.NetworkPolicy {
+			return fi.NetworkPolicy < fj.NetworkPolicy
+		}
+		kindI, idxI := parseRule(fi.Rule)
+		kindJ, idxJ := parseRule(fj.Rule)
+		if kindI != kindJ {
+			return kindI < kindJ
+		}
+		if idxI != idxJ {
+			return idxI < idxJ
+		}
+		return peerIndex(fi) < peerIndex(fj)
+	})
+}
+
+// parseRule splits a rule name, e.g. "ingress[0]", into its kind and index. The index is -1 if the
+// rule name is empty or has no valid index.
+func parseRule(rule string) (string, int) {
+	open := strings.Index(rule, "[")
+	if open < 0 || !strings.HasSuffix(rule, "]") {
+		return rule, -1
+	}
+	idx, err := strconv.Atoi(rule[open+1 : len(rule)-1])
+	if err != nil {
+		return rule, -1
+	}
+	return rule[:open], idx
+}
+
+func peerIndex(f *Finding) int {
+	if f.Peer == nil {
+		return -1
+	}
+	return *f.Peer
+}
+
+func (a *analyzer) report(check Check, namespace, policy, rule string, peer *int, format string, args ...interface{}) {
+	a.findings = append(a.findings, Finding{
+		Check:         check,
+		Severity:      severities[check],
+		Namespace:     namespace,
+		NetworkPolicy: policy,
+		Rule:          rule,
+		Peer:          peer,
+		Message:       fmt.Sprintf(format, args...),
+	})
+}
+
+// policyPods returns the Pods the internal NetworkPolicy applies to, according to the outputs.
+func (a *analyzer) policyPods(policy *view.NetworkPolicy) sets.String {
+	pods := sets.NewString()
+	for _, name := range policy.AppliedToGroups {
+		pods = pods.Union(a.groupPods[name])
+	}
+	return pods
+}
+
+func (a *analyzer) checkPolicySelectsNoPods(np *networkingv1.NetworkPolicy) {
+	policy, ok := a.view.GetNetworkPolicy(np.UID)
+	if !ok {
+		// The outputs are not available (yet) for this policy.
+		return
+	}
+	if a.policyPods(policy).Len() == 0 {
+		a.report(CheckPolicySelectsNoPods, np.Namespace, np.Name, "", nil, "podSelector does not match any Pod")
+	}
+}
+
+func (a *analyzer) checkIngressRules(np *networkingv1.NetworkPolicy) {
+	for i := range np.Spec.Ingress {
+		a.checkPeers(np, fmt.Sprintf("ingress[%d]", i), np.Spec.Ingress[i].From)
+	}
+}
+
+func (a *analyzer) checkEgressRules(np *networkingv1.NetworkPolicy) {
+	for i := range np.Spec.Egress {
+		a.checkPeers(np, fmt.Sprintf("egress[%d]", i), np.Spec.Egress[i].To)
+	}
+}
+
+// checkPeers runs the checks on the peers of an input rule.
+func (a *analyzer) checkPeers(np *networkingv1.NetworkPolicy, rule string, peers []networkingv1.NetworkPolicyPeer) {
+	type ipBlock struct {
+		peer int
+		cidr *net.IPNet
+	}
+	var ipBlocks []ipBlock
+	for i := range peers {
+		peer := &peers[i]
+		peerIdx := i
+		if peer.IPBlock == nil {
+			if len(a.selector.SelectPeerPods(np.Namespace, peer)) == 0 {
+				a.report(CheckPeerMatchesNothing, np.Namespace, np.Name, rule, &peerIdx, "peer does not select any Pod")
+			}
+			continue
+		}
+		_, cidr, err := net.ParseCIDR(peer.IPBlock.CIDR)
+		if err != nil {
+			// Rejected by the K8s API validation.
+			continue
+		}
+		for _, except := range peer.IPBlock.Except {
+			_, exceptNet, err := net.ParseCIDR(except)
+			if err != nil {
+				continue
+			}
+			if netContains(exceptNet, cidr) {
+				a.report(CheckPeerMatchesNothing, np.Namespace, np.Name, rule, &peerIdx, "except range %s excludes the whole CIDR %s", except, peer.IPBlock.CIDR)
+			} else if !netContains(cidr, exceptNet) {
+				a.report(CheckExceptOutsideCIDR, np.Namespace, np.Name, rule, &peerIdx, "except range %s is not included in CIDR %s", except, peer.IPBlock.CIDR)
+			}
+		}
+		for _, other := range ipBlocks {
+			if netsOverlap(other.cidr, cidr) {
+				a.report(CheckOverlappingIPBlocks, np.Namespace, np.Name, rule, &peerIdx, "CIDR %s overlaps with CIDR %s of peer %d", cidr, other.cidr, other.peer)
+			}
+		}
+		ipBlocks = append(ipBlocks, ipBlock{peer: i, cidr: cidr})
+	}
+}
+
+func (a *analyzer) checkNamespacesNotIsolated() {
+	selected := sets.NewString()
+	for _, policy := range a.view.ListNetworkPolicies() {
+		selected = selected.Union(a.policyPods(policy))
+	}
+	numPods := make(map[string]int)
+	isolated := sets.NewString()
+	for _, pod := range a.inputs.Pods {
+		numPods[pod.Namespace]++
+		if selected.Has(pod.Namespace + "/" + pod.Name) {
+			isolated.Insert(pod.Namespace)
+		}
+	}
+	for _, ns := range a.inputs.Namespaces {
+		if numPods[ns.Name] > 0 && !isolated.Has(ns.Name) {
+			a.report(CheckNamespaceNotIsolated, ns.Name, "", "", nil, "none of the %d Pod(s) in the Namespace is selected by a NetworkPolicy", numPods[ns.Name])
+		}
+	}
+}
+
+// netContains returns true if n1 includes all the addresses of n2.
+func netContains(n1, n2 *net.IPNet) bool {
+	ones1, bits1 := n1.Mask.Size()
+	ones2, bits2 := n2.Mask.Size()
+	return bits1 == bits2 && ones1 <= ones2 && n1.Contains(n2.IP)
+}
+
+func netsOverlap(n1, n2 *net.IPNet) bool {
+	return n1.Contains(n2.IP) || n2.Contains(n1.IP)
+}
+
+// ruleName returns the name of an internal NetworkPolicy rule in the K8s NetworkPolicy np, which
+// may be nil if it is unknown. Internal rules are the ingress rules followed by the egress rules.
+func ruleName(np *networkingv1.NetworkPolicy, idx int, direction ddlogk8s.Direction) string {
+	if direction == ddlogk8s.DirectionIn {
+		return fmt.Sprintf("ingress[%d]", idx)
+	}
+	if np != nil && idx >= len(np.Spec.Ingress) {
+		return fmt.Sprintf("egress[%d]", idx-len(np.Spec.Ingress))
+	}
+	return fmt.Sprintf("rules[%d]", idx)
+}
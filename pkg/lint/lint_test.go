@@ -0,0 +1,210 @@
+// Copyright 2020 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lint
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+
+	"github.com/antoninbas/antrea-k8s-to-ddlog/pkg/ddlogk8s"
+	"github.com/antoninbas/antrea-k8s-to-ddlog/pkg/reference"
+	"github.com/antoninbas/antrea-k8s-to-ddlog/pkg/view"
+)
+
+func newPod(namespace, name, ip string, labels map[string]string) *v1.Pod {
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name, Labels: labels},
+		Spec:       v1.PodSpec{NodeName: "node-1"},
+		Status:     v1.PodStatus{PodIP: ip},
+	}
+}
+
+func newPolicy(namespace, name string, podSelector map[string]string, ingress ...networkingv1.NetworkPolicyIngressRule) *networkingv1.NetworkPolicy {
+	return &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name, UID: types.UID(namespace + "-" + name)},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{MatchLabels: podSelector},
+			Ingress:     ingress,
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress},
+		},
+	}
+}
+
+func podPeer(labels map[string]string) networkingv1.NetworkPolicyPeer {
+	return networkingv1.NetworkPolicyPeer{PodSelector: &metav1.LabelSelector{MatchLabels: labels}}
+}
+
+func ipBlockPeer(cidr string, except ...string) networkingv1.NetworkPolicyPeer {
+	return networkingv1.NetworkPolicyPeer{IPBlock: &networkingv1.IPBlock{CIDR: cidr, Except: except}}
+}
+
+func tcpPort(port int) networkingv1.NetworkPolicyPort {
+	tcp := v1.ProtocolTCP
+	p := intstr.FromInt(port)
+	return networkingv1.NetworkPolicyPort{Protocol: &tcp, Port: &p}
+}
+
+// testView builds the outputs for the inputs with the reference computation, with one
+// AppliedToGroup and one AddressGroup per rule, like DDlog would.
+func testView(inputs *reference.Inputs) *view.View {
+	v := view.NewView()
+	for uid, np := range reference.Compute(inputs) {
+		group := "atg-" + string(uid)
+		v.HandleOutput(&ddlogk8s.AppliedToGroup{Name: group}, true)
+		for nodeName, pods := range np.PodsByNode {
+			refs := make([]ddlogk8s.PodReference, len(pods))
+			for i, pod := range pods {
+				refs[i] = ddlogk8s.PodReference{Name: pod.Name, Namespace: pod.Namespace}
+			}
+			v.HandleOutput(&ddlogk8s.AppliedToGroupPodsByNode{AppliedToGroup: group, NodeName: nodeName, Pods: refs}, true)
+		}
+		policy := &ddlogk8s.InternalNetworkPolicy{
+			UID:             uid,
+			Name:            np.Name,
+			Namespace:       np.Namespace,
+			AppliedToGroups: []string{group},
+			PolicyTypes:     np.PolicyTypes,
+		}
+		for i, rule := range np.Rules {
+			peer := ddlogk8s.InternalNetworkPolicyPeer{IPBlocks: rule.IPBlocks}
+			if len(rule.Addresses) > 0 {
+				addressGroup := fmt.Sprintf("ag-%s-%d", uid, i)
+				v.HandleOutput(&ddlogk8s.AddressGroup{Name: addressGroup}, true)
+				for _, address := range rule.Addresses {
+					v.HandleOutput(&ddlogk8s.AddressGroupAddress{AddressGroup: addressGroup, Address: address}, true)
+				}
+				peer.AddressGroups = []string{addressGroup}
+			}
+			internalRule := ddlogk8s.InternalNetworkPolicyRule{Direction: ddlogk8s.Direction(rule.Direction), Services: rule.Services}
+			if rule.Direction == reference.DirectionIn {
+				internalRule.From = peer
+			} else {
+				internalRule.To = peer
+			}
+			policy.Rules = append(policy.Rules, internalRule)
+		}
+		v.HandleOutput(policy, true)
+	}
+	return v
+}
+
+func TestAnalyze(t *testing.T) {
+	web := map[string]string{"app": "web"}
+	db := map[string]string{"app": "db"}
+	inputs := &reference.Inputs{
+		Namespaces: []*v1.Namespace{
+			{ObjectMeta: metav1.ObjectMeta{Name: "ns1"}},
+			{ObjectMeta: metav1.ObjectMeta{Name: "ns2"}},
+			{ObjectMeta: metav1.ObjectMeta{Name: "ns3"}},
+		},
+		Pods: []*v1.Pod{
+			newPod("ns1", "web", "10.0.0.1", web),
+			newPod("ns1", "db", "10.0.0.2", db),
+			newPod("ns2", "web", "10.0.1.1", web),
+		},
+		NetworkPolicies: []*networkingv1.NetworkPolicy{
+			// Selects no Pod.
+			newPolicy("ns1", "cache", map[string]string{"app": "cache"}),
+			newPolicy("ns1", "db", db,
+				// Peer 1 selects no Pod.
+				networkingv1.NetworkPolicyIngressRule{
+					From:  []networkingv1.NetworkPolicyPeer{podPeer(web), podPeer(map[string]string{"app": "api"})},
+					Ports: []networkingv1.NetworkPolicyPort{tcpPort(5432)},
+				},
+				// Overlapping IPBlocks, except outside the CIDR and except excluding
+				// the whole CIDR.
+				networkingv1.NetworkPolicyIngressRule{
+					From: []networkingv1.NetworkPolicyPeer{
+						ipBlockPeer("192.168.0.0/16", "172.16.0.0/24"),
+						ipBlockPeer("192.168.1.0/24"),
+						ipBlockPeer("172.17.0.0/24", "172.17.0.0/16"),
+					},
+				},
+			),
+			// Allows a subset of the traffic allowed by the first rule of "db".
+			newPolicy("ns1", "db-web", db, networkingv1.NetworkPolicyIngressRule{
+				From:  []networkingv1.NetworkPolicyPeer{podPeer(web)},
+				Ports: []networkingv1.NetworkPolicyPort{tcpPort(5432)},
+			}),
+			// Does not shadow the rule of "db-web": the ports are different.
+			newPolicy("ns1", "db-other", db, networkingv1.NetworkPolicyIngressRule{
+				From:  []networkingv1.NetworkPolicyPeer{podPeer(web)},
+				Ports: []networkingv1.NetworkPolicyPort{tcpPort(3306)},
+			}),
+		},
+	}
+	report := Analyze(inputs, testView(inputs))
+
+	peer1, peer2 := 1, 2
+	assert.Equal(t, []Finding{
+		{Check: CheckPolicySelectsNoPods, Severity: SeverityWarning, Namespace: "ns1", NetworkPolicy: "cache", Message: "podSelector does not match any Pod"},
+		{Check: CheckPeerMatchesNothing, Severity: SeverityWarning, Namespace: "ns1", NetworkPolicy: "db", Rule: "ingress[0]", Peer: &peer1, Message: "peer does not select any Pod"},
+		{Check: CheckExceptOutsideCIDR, Severity: SeverityWarning, Namespace: "ns1", NetworkPolicy: "db", Rule: "ingress[1]", Peer: new(int), Message: "except range 172.16.0.0/24 is not included in CIDR 192.168.0.0/16"},
+		{Check: CheckOverlappingIPBlocks, Severity: SeverityWarning, Namespace: "ns1", NetworkPolicy: "db", Rule: "ingress[1]", Peer: &peer1, Message: "CIDR 192.168.1.0/24 overlaps with CIDR 192.168.0.0/16 of peer 0"},
+		{Check: CheckPeerMatchesNothing, Severity: SeverityWarning, Namespace: "ns1", NetworkPolicy: "db", Rule: "ingress[1]", Peer: &peer2, Message: "except range 172.17.0.0/16 excludes the whole CIDR 172.17.0.0/24"},
+		{Check: CheckShadowedRule, Severity: SeverityWarning, Namespace: "ns1", NetworkPolicy: "db-web", Rule: "ingress[0]", Message: "rule is shadowed by rule ingress[0] of NetworkPolicy db"},
+		{Check: CheckNamespaceNotIsolated, Severity: SeverityInfo, Namespace: "ns2", Message: "none of the 1 Pod(s) in the Namespace is selected by a NetworkPolicy"},
+	}, report.Findings)
+	assert.Equal(t, 2, report.Counts[CheckPeerMatchesNothing])
+
+	var b bytes.Buffer
+	require.NoError(t, report.WriteJSON(&b))
+	var decoded Report
+	require.NoError(t, json.Unmarshal(b.Bytes(), &decoded))
+	assert.Equal(t, report, &decoded)
+}
+
+func TestShadowedDuplicateRules(t *testing.T) {
+	web := map[string]string{"app": "web"}
+	rule := networkingv1.NetworkPolicyIngressRule{From: []networkingv1.NetworkPolicyPeer{ipBlockPeer("10.0.0.0/8")}}
+	inputs := &reference.Inputs{
+		Namespaces: []*v1.Namespace{{ObjectMeta: metav1.ObjectMeta{Name: "ns1"}}},
+		Pods:       []*v1.Pod{newPod("ns1", "web", "10.0.0.1", web)},
+		NetworkPolicies: []*networkingv1.NetworkPolicy{
+			newPolicy("ns1", "np1", web, rule),
+			newPolicy("ns1", "np2", web, rule),
+		},
+	}
+	report := Analyze(inputs, testView(inputs))
+	// Only one of two identical rules is reported.
+	require.Len(t, report.Findings, 1)
+	assert.Equal(t, "np2", report.Findings[0].NetworkPolicy)
+	assert.Equal(t, "rule is shadowed by rule ingress[0] of NetworkPolicy np1", report.Findings[0].Message)
+}
+
+func TestSortFindings(t *testing.T) {
+	findings := []Finding{
+		{Namespace: "ns1", NetworkPolicy: "np1", Rule: "ingress[10]"},
+		{Namespace: "ns1", NetworkPolicy: "np1", Rule: "ingress[2]"},
+		{Namespace: "ns1", NetworkPolicy: "np1", Rule: "egress[1]"},
+		{Namespace: "ns1", NetworkPolicy: "np1"},
+	}
+	sortFindings(findings)
+	var rules []string
+	for _, f := range findings {
+		rules = append(rules, f.Rule)
+	}
+	assert.Equal(t, []string{"", "egress[1]", "ingress[2]", "ingress[10]"}, rules)
+}
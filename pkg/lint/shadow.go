@@ -0,0 +1,237 @@
+// Copyright 2020 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lint
+
+import (
+	"net"
+
+	"k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/util/sets"
+
+	"github.com/antoninbas/antrea-k8s-to-ddlog/pkg/ddlogk8s"
+	"github.com/antoninbas/antrea-k8s-to-ddlog/pkg/view"
+)
+
+// resolvedRule is an internal NetworkPolicy rule, with its AddressGroups resolved.
+type resolvedRule struct {
+	policy *view.NetworkPolicy
+	index  int
+	rule   *ddlogk8s.InternalNetworkPolicyRule
+	// pods are the Pods the policy applies to.
+	pods      sets.String
+	addresses sets.String
+	ipBlocks  []networkingv1.IPBlock
+}
+
+func (r *resolvedRule) empty() bool {
+	return r.addresses.Len() == 0 && len(r.ipBlocks) == 0
+}
+
+// less orders rules by policy name and rule index. When two rules shadow each other, only the
+// greater one is reported.
+func (r *resolvedRule) less(other *resolvedRule) bool {
+	if r.policy.Name != other.policy.Name {
+		return r.policy.Name < other.policy.Name
+	}
+	return r.index < other.index
+}
+
+func (a *analyzer) resolveRules(policy *view.NetworkPolicy) []*resolvedRule {
+	pods := a.policyPods(policy)
+	rules := make([]*resolvedRule, len(policy.Rules))
+	for i := range policy.Rules {
+		rule := &policy.Rules[i]
+		peer := &rule.From
+		if rule.Direction == ddlogk8s.DirectionOut {
+			peer = &rule.To
+		}
+		addresses := sets.NewString()
+		for _, name := range peer.AddressGroups {
+			groupAddresses, _ := a.view.ListAddressGroupAddresses(name)
+			addresses.Insert(groupAddresses...)
+		}
+		rules[i] = &resolvedRule{
+			policy:    policy,
+			index:     i,
+			rule:      rule,
+			pods:      pods,
+			addresses: addresses,
+			ipBlocks:  peer.IPBlocks,
+		}
+	}
+	return rules
+}
+
+func isEnforced(policy *view.NetworkPolicy, direction ddlogk8s.Direction) bool {
+	policyType := networkingv1.PolicyTypeIngress
+	if direction == ddlogk8s.DirectionOut {
+		policyType = networkingv1.PolicyTypeEgress
+	}
+	for _, t := range policy.PolicyTypes {
+		if t == policyType {
+			return true
+		}
+	}
+	return false
+}
+
+// checkShadowedRules reports the rules which allow a subset of the traffic allowed by another rule
+// of a policy of the same Namespace, which applies to at least the same Pods. Such rules can be
+// removed without changing the set of allowed connections.
+func (a *analyzer) checkShadowedRules() {
+	rulesByNamespace := make(map[string][]*resolvedRule)
+	for _, policy := range a.view.ListNetworkPolicies() {
+		for _, rule := range a.resolveRules(policy) {
+			if isEnforced(policy, rule.rule.Direction) {
+				rulesByNamespace[policy.Namespace] = append(rulesByNamespace[policy.Namespace], rule)
+			}
+		}
+	}
+	for namespace, rules := range rulesByNamespace {
+		for _, r := range rules {
+			// Rules with an empty peer or for no Pods are reported by other checks.
+			if r.empty() || r.pods.Len() == 0 {
+				continue
+			}
+			for _, other := range rules {
+				if other == r || !shadows(other, r) || (shadows(r, other) && r.less(other)) {
+					continue
+				}
+				a.report(CheckShadowedRule, namespace, r.policy.Name, ruleName(a.policies[r.policy.UID], r.index, r.rule.Direction), nil,
+					"rule is shadowed by rule %s of NetworkPolicy %s", ruleName(a.policies[other.policy.UID], other.index, other.rule.Direction), other.policy.Name)
+				break
+			}
+		}
+	}
+}
+
+// shadows returns true if rule r1 allows all the traffic allowed by rule r2.
+func shadows(r1, r2 *resolvedRule) bool {
+	if r1.rule.Direction != r2.rule.Direction || !r1.pods.IsSuperset(r2.pods) {
+		return false
+	}
+	for address := range r2.addresses {
+		if !r1.addresses.Has(address) && !ipBlocksContain(r1.ipBlocks, address) {
+			return false
+		}
+	}
+	for i := range r2.ipBlocks {
+		if !ipBlocksCover(r1.ipBlocks, &r2.ipBlocks[i]) {
+			return false
+		}
+	}
+	return servicesCover(r1.rule.Services, r2.rule.Services)
+}
+
+func ipBlocksContain(ipBlocks []networkingv1.IPBlock, address string) bool {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return false
+	}
+	for _, ipBlock := range ipBlocks {
+		_, cidr, err := net.ParseCIDR(ipBlock.CIDR)
+		if err != nil || !cidr.Contains(ip) {
+			continue
+		}
+		excluded := false
+		for _, except := range ipBlock.Except {
+			if _, exceptNet, err := net.ParseCIDR(except); err == nil && exceptNet.Contains(ip) {
+				excluded = true
+				break
+			}
+		}
+		if !excluded {
+			return true
+		}
+	}
+	return false
+}
+
+// ipBlocksCover returns true if one of ipBlocks includes all the addresses of ipBlock: its CIDR
+// must include the CIDR of ipBlock, and its except ranges must be excluded from ipBlock as well.
+func ipBlocksCover(ipBlocks []networkingv1.IPBlock, ipBlock *networkingv1.IPBlock) bool {
+	_, cidr, err := net.ParseCIDR(ipBlock.CIDR)
+	if err != nil {
+		return false
+	}
+	var excepts []*net.IPNet
+	for _, except := range ipBlock.Except {
+		if _, exceptNet, err := net.ParseCIDR(except); err == nil {
+			excepts = append(excepts, exceptNet)
+		}
+	}
+	for _, other := range ipBlocks {
+		_, otherCIDR, err := net.ParseCIDR(other.CIDR)
+		if err != nil || !netContains(otherCIDR, cidr) {
+			continue
+		}
+		covered := true
+		for _, except := range other.Except {
+			_, otherExcept, err := net.ParseCIDR(except)
+			if err != nil || !netsOverlap(otherExcept, cidr) {
+				continue
+			}
+			excluded := false
+			for _, e := range excepts {
+				excluded = excluded || netContains(e, otherExcept)
+			}
+			if !excluded {
+				covered = false
+				break
+			}
+		}
+		if covered {
+			return true
+		}
+	}
+	return false
+}
+
+func serviceProtocol(service *networkingv1.NetworkPolicyPort) v1.Protocol {
+	if service.Protocol == nil {
+		return v1.ProtocolTCP
+	}
+	return *service.Protocol
+}
+
+// servicesCover returns true if services1 includes all the ports of services2. An empty list
+// matches all ports.
+func servicesCover(services1, services2 []networkingv1.NetworkPolicyPort) bool {
+	if len(services1) == 0 {
+		return true
+	}
+	if len(services2) == 0 {
+		return false
+	}
+	for i := range services2 {
+		s2 := &services2[i]
+		covered := false
+		for j := range services1 {
+			s1 := &services1[j]
+			if serviceProtocol(s1) != serviceProtocol(s2) {
+				continue
+			}
+			if s1.Port == nil || (s2.Port != nil && *s1.Port == *s2.Port) {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,135 @@
+// Copyright 2020 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package loadgen
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+)
+
+// ChurnPattern is a kind of change applied to the cluster after it has been populated.
+type ChurnPattern string
+
+const (
+	// ChurnRollingUpdate replaces all the Pods of an app in a Namespace, one by one, creating the
+	// new Pod before deleting the old one.
+	ChurnRollingUpdate ChurnPattern = "rolling-update"
+	// ChurnLabelFlip changes the value of the "tier" or "version" label of a Pod.
+	ChurnLabelFlip ChurnPattern = "label-flip"
+	// ChurnNamespaceDeletion deletes a Namespace and all its Pods, then re-creates the Namespace
+	// with new labels and as many new Pods.
+	ChurnNamespaceDeletion ChurnPattern = "namespace-deletion"
+)
+
+var churnPatterns = []ChurnPattern{ChurnRollingUpdate, ChurnLabelFlip, ChurnNamespaceDeletion}
+
+// ParseChurnPatterns parses a comma-separated list of churn patterns. An empty string means no
+// churn.
+func ParseChurnPatterns(s string) ([]ChurnPattern, error) {
+	var patterns []ChurnPattern
+	if s == "" {
+		return patterns, nil
+	}
+	for _, name := range strings.Split(s, ",") {
+		valid := false
+		for _, pattern := range churnPatterns {
+			valid = valid || ChurnPattern(name) == pattern
+		}
+		if !valid {
+			return nil, fmt.Errorf("invalid churn pattern '%s', must be one of %v", name, churnPatterns)
+		}
+		patterns = append(patterns, ChurnPattern(name))
+	}
+	return patterns, nil
+}
+
+// randomNamespace returns a random Namespace which has at least one Pod, or an empty string if there
+// is none.
+func (c *cluster) randomNamespace() string {
+	var names []string
+	for name, pods := range c.pods {
+		if len(pods) > 0 {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return ""
+	}
+	// Map iteration order is random, sort to keep the generation deterministic.
+	sort.Strings(names)
+	return names[c.gen.Intn(len(names))]
+}
+
+// randomPod returns a random Pod from the Namespace.
+func (c *cluster) randomPod(namespace string) *corev1.Pod {
+	var names []string
+	for name := range c.pods[namespace] {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return c.pods[namespace][names[c.gen.Intn(len(names))]]
+}
+
+func (c *cluster) churn(pattern ChurnPattern) error {
+	namespace := c.randomNamespace()
+	if namespace == "" {
+		return nil
+	}
+	switch pattern {
+	case ChurnRollingUpdate:
+		app := c.randomPod(namespace).Labels["app"]
+		var oldPods []*corev1.Pod
+		for _, pod := range c.pods[namespace] {
+			if pod.Labels["app"] == app {
+				oldPods = append(oldPods, pod)
+			}
+		}
+		sort.Slice(oldPods, func(i, j int) bool { return oldPods[i].Name < oldPods[j].Name })
+		for _, pod := range oldPods {
+			if err := c.createPod(c.gen.ReplacePod(pod)); err != nil {
+				return err
+			}
+			if err := c.deletePod(pod); err != nil {
+				return err
+			}
+		}
+	case ChurnLabelFlip:
+		return c.updatePod(c.gen.FlipLabels(c.randomPod(namespace)))
+	case ChurnNamespaceDeletion:
+		var apps []string
+		for _, pod := range c.pods[namespace] {
+			apps = append(apps, pod.Labels["app"])
+			if err := c.deletePod(pod); err != nil {
+				return err
+			}
+		}
+		sort.Strings(apps)
+		if err := c.deleteNamespace(namespace); err != nil {
+			return err
+		}
+		if err := c.createNamespace(c.gen.Namespace(namespace)); err != nil {
+			return err
+		}
+		for _, app := range apps {
+			if err := c.createPod(c.gen.Pod(namespace, app)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,248 @@
+// Copyright 2020 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package loadgen generates a synthetic cluster, with realistic label distributions, and drives it
+// through the controller using a fake clientset, to measure the throughput and the commit latency
+// of the DDlog program.
+package loadgen
+
+import (
+	"fmt"
+	"math/rand"
+	"net"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+)
+
+const (
+	// appsPerNamespace is the number of distinct "app" label values in each Namespace. Pods are
+	// distributed among apps with a Zipf distribution, so that a few apps have most Pods.
+	appsPerNamespace = 10
+	teams            = 10
+	// podCIDR is the CIDR from which Pod IPs are allocated.
+	podCIDR = "10.0.0.0/8"
+)
+
+// weighted is a label value and its relative weight.
+type weighted struct {
+	value  string
+	weight int
+}
+
+var (
+	envValues     = []weighted{{"prod", 5}, {"staging", 3}, {"dev", 2}}
+	tierValues    = []weighted{{"frontend", 4}, {"backend", 4}, {"db", 2}}
+	versionValues = []weighted{{"v1", 7}, {"v2", 3}}
+)
+
+func pickWeighted(r *rand.Rand, values []weighted) string {
+	total := 0
+	for _, v := range values {
+		total += v.weight
+	}
+	n := r.Intn(total)
+	for _, v := range values {
+		if n < v.weight {
+			return v.value
+		}
+		n -= v.weight
+	}
+	return values[len(values)-1].value
+}
+
+// Generator creates synthetic Kubernetes objects. It is deterministic for a given seed.
+type Generator struct {
+	rand  *rand.Rand
+	zipf  *rand.Zipf
+	nodes int
+	// nextIP is the next IP allocated to a Pod, as an offset in podCIDR.
+	nextIP  uint32
+	baseIP  uint32
+	nextUID int
+	nextPod int
+}
+
+// NewGenerator creates a new Generator. Pods are scheduled on the provided number of Nodes.
+func NewGenerator(seed int64, nodes int) *Generator {
+	r := rand.New(rand.NewSource(seed))
+	_, podNet, _ := net.ParseCIDR(podCIDR)
+	ip := podNet.IP.To4()
+	return &Generator{
+		rand:   r,
+		zipf:   rand.NewZipf(r, 1.2, 1, appsPerNamespace-1),
+		nodes:  nodes,
+		nextIP: 1,
+		baseIP: uint32(ip[0])<<24 | uint32(ip[1])<<16 | uint32(ip[2])<<8 | uint32(ip[3]),
+	}
+}
+
+func (g *Generator) uid(kind string) types.UID {
+	g.nextUID++
+	return types.UID(fmt.Sprintf("%s-%d", kind, g.nextUID))
+}
+
+func (g *Generator) ip() string {
+	ip := g.baseIP + g.nextIP
+	g.nextIP++
+	return net.IPv4(byte(ip>>24), byte(ip>>16), byte(ip>>8), byte(ip)).String()
+}
+
+// NamespaceName returns the name of the i-th Namespace.
+func NamespaceName(i int) string {
+	return fmt.Sprintf("ns-%d", i)
+}
+
+// Namespace creates a Namespace with "env" and "team" labels.
+func (g *Generator) Namespace(name string) *corev1.Namespace {
+	return &corev1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{
+			Name: name,
+			UID:  g.uid("ns"),
+			Labels: map[string]string{
+				"env":  pickWeighted(g.rand, envValues),
+				"team": fmt.Sprintf("team-%d", g.rand.Intn(teams)),
+			},
+		},
+	}
+}
+
+// App returns a random "app" label value, following a Zipf distribution.
+func (g *Generator) App() string {
+	return fmt.Sprintf("app-%d", g.zipf.Uint64())
+}
+
+// Pod creates a running Pod for the provided app, with "app", "tier" and "version" labels, a Node
+// and an IP.
+func (g *Generator) Pod(namespace, app string) *corev1.Pod {
+	g.nextPod++
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("%s-%d", app, g.nextPod),
+			Namespace: namespace,
+			UID:       g.uid("pod"),
+			Labels: map[string]string{
+				"app":     app,
+				"tier":    pickWeighted(g.rand, tierValues),
+				"version": pickWeighted(g.rand, versionValues),
+			},
+		},
+		Spec: corev1.PodSpec{
+			NodeName: fmt.Sprintf("node-%d", g.rand.Intn(g.nodes)),
+		},
+		Status: corev1.PodStatus{
+			Phase: corev1.PodRunning,
+			PodIP: g.ip(),
+		},
+	}
+}
+
+// ReplacePod creates a new Pod which replaces the provided one, as done by a rolling update: it
+// has a new name, UID, Node and IP, and the same labels except for "version".
+func (g *Generator) ReplacePod(pod *corev1.Pod) *corev1.Pod {
+	newPod := g.Pod(pod.Namespace, pod.Labels["app"])
+	newPod.Labels["tier"] = pod.Labels["tier"]
+	return newPod
+}
+
+// FlipLabels returns a copy of the Pod with a different value for the "tier" or "version" label.
+func (g *Generator) FlipLabels(pod *corev1.Pod) *corev1.Pod {
+	newPod := pod.DeepCopy()
+	key, values := "tier", tierValues
+	if g.rand.Intn(2) == 0 {
+		key, values = "version", versionValues
+	}
+	for {
+		if value := pickWeighted(g.rand, values); value != pod.Labels[key] {
+			newPod.Labels[key] = value
+			return newPod
+		}
+	}
+}
+
+// policyKinds is the number of different policy shapes generated by NetworkPolicy.
+const policyKinds = 5
+
+// NetworkPolicy creates a NetworkPolicy in the provided Namespace. The i-th policy uses one of
+// several shapes, which together cover the different kinds of selectors and peers:
+//   - podSelector with matchLabels, ingress from Pods of a tier;
+//   - podSelector with matchExpressions, ingress from prod Namespaces on a port;
+//   - empty podSelector and no rule, i.e. default deny ingress;
+//   - egress to an ipBlock with an exception, on a UDP port;
+//   - ingress from Pods selected by both a namespaceSelector and a podSelector.
+func (g *Generator) NetworkPolicy(namespace string, i int) *networkingv1.NetworkPolicy {
+	tcp, udp := corev1.ProtocolTCP, corev1.ProtocolUDP
+	np := &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      fmt.Sprintf("np-%d", i),
+			Namespace: namespace,
+			UID:       g.uid("np"),
+		},
+	}
+	spec := &np.Spec
+	switch i % policyKinds {
+	case 0:
+		spec.PodSelector = metav1.LabelSelector{MatchLabels: map[string]string{"app": g.App()}}
+		spec.Ingress = []networkingv1.NetworkPolicyIngressRule{{
+			From: []networkingv1.NetworkPolicyPeer{{
+				PodSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"tier": "frontend"}},
+			}},
+		}}
+		spec.PolicyTypes = []networkingv1.PolicyType{networkingv1.PolicyTypeIngress}
+	case 1:
+		spec.PodSelector = metav1.LabelSelector{MatchExpressions: []metav1.LabelSelectorRequirement{{
+			Key:      "tier",
+			Operator: metav1.LabelSelectorOpIn,
+			Values:   []string{"backend", "db"},
+		}}}
+		port := intstr.FromInt(8080)
+		spec.Ingress = []networkingv1.NetworkPolicyIngressRule{{
+			Ports: []networkingv1.NetworkPolicyPort{{Protocol: &tcp, Port: &port}},
+			From: []networkingv1.NetworkPolicyPeer{{
+				NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"env": "prod"}},
+			}},
+		}}
+		spec.PolicyTypes = []networkingv1.PolicyType{networkingv1.PolicyTypeIngress}
+	case 2:
+		spec.PolicyTypes = []networkingv1.PolicyType{networkingv1.PolicyTypeIngress}
+	case 3:
+		spec.PodSelector = metav1.LabelSelector{MatchLabels: map[string]string{"app": g.App()}}
+		port := intstr.FromInt(53)
+		spec.Egress = []networkingv1.NetworkPolicyEgressRule{{
+			Ports: []networkingv1.NetworkPolicyPort{{Protocol: &udp, Port: &port}},
+			To: []networkingv1.NetworkPolicyPeer{{
+				IPBlock: &networkingv1.IPBlock{CIDR: "10.0.0.0/8", Except: []string{"10.1.0.0/16"}},
+			}},
+		}}
+		spec.PolicyTypes = []networkingv1.PolicyType{networkingv1.PolicyTypeEgress}
+	case 4:
+		spec.PodSelector = metav1.LabelSelector{MatchLabels: map[string]string{"tier": "db"}}
+		spec.Ingress = []networkingv1.NetworkPolicyIngressRule{{
+			From: []networkingv1.NetworkPolicyPeer{{
+				NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"env": "staging"}},
+				PodSelector:       &metav1.LabelSelector{MatchLabels: map[string]string{"app": g.App()}},
+			}},
+		}}
+		spec.PolicyTypes = []networkingv1.PolicyType{networkingv1.PolicyTypeIngress}
+	}
+	return np
+}
+
+// Intn returns a random integer in [0, n).
+func (g *Generator) Intn(n int) int {
+	return g.rand.Intn(n)
+}
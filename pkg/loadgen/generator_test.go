@@ -0,0 +1,95 @@
+// Copyright 2020 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package loadgen
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestGeneratorDeterministic(t *testing.T) {
+	g1, g2 := NewGenerator(42, 3), NewGenerator(42, 3)
+	for i := 0; i < 10; i++ {
+		assert.Equal(t, g1.Namespace("ns"), g2.Namespace("ns"))
+		assert.Equal(t, g1.Pod("ns", g1.App()), g2.Pod("ns", g2.App()))
+		assert.Equal(t, g1.NetworkPolicy("ns", i), g2.NetworkPolicy("ns", i))
+	}
+}
+
+func TestGeneratorPods(t *testing.T) {
+	g := NewGenerator(1, 3)
+	ips := make(map[string]bool)
+	apps := make(map[string]int)
+	for i := 0; i < 1000; i++ {
+		pod := g.Pod("ns", g.App())
+		assert.NotEmpty(t, pod.Spec.NodeName)
+		assert.False(t, ips[pod.Status.PodIP], "duplicate IP %s", pod.Status.PodIP)
+		ips[pod.Status.PodIP] = true
+		apps[pod.Labels["app"]]++
+	}
+	assert.Equal(t, "10.0.0.1", NewGenerator(1, 1).Pod("ns", "app").Status.PodIP)
+	// With a Zipf distribution, the most frequent app has many more Pods than the average.
+	assert.Greater(t, apps["app-0"], 1000/appsPerNamespace*2)
+
+	pod := g.Pod("ns", "app-1")
+	newPod := g.ReplacePod(pod)
+	assert.NotEqual(t, pod.Name, newPod.Name)
+	assert.NotEqual(t, pod.Status.PodIP, newPod.Status.PodIP)
+	assert.Equal(t, pod.Labels["app"], newPod.Labels["app"])
+	assert.Equal(t, pod.Labels["tier"], newPod.Labels["tier"])
+
+	flipped := g.FlipLabels(pod)
+	assert.Equal(t, pod.Name, flipped.Name)
+	assert.NotEqual(t, pod.Labels, flipped.Labels)
+}
+
+func TestGeneratorNetworkPolicies(t *testing.T) {
+	g := NewGenerator(1, 1)
+	for i := 0; i < policyKinds; i++ {
+		np := g.NetworkPolicy("ns", i)
+		_, err := metav1.LabelSelectorAsSelector(&np.Spec.PodSelector)
+		require.NoError(t, err)
+		assert.NotEmpty(t, np.Spec.PolicyTypes)
+	}
+}
+
+func TestParseChurnPatterns(t *testing.T) {
+	patterns, err := ParseChurnPatterns("rolling-update,label-flip")
+	require.NoError(t, err)
+	assert.Equal(t, []ChurnPattern{ChurnRollingUpdate, ChurnLabelFlip}, patterns)
+	patterns, err = ParseChurnPatterns("")
+	require.NoError(t, err)
+	assert.Empty(t, patterns)
+	_, err = ParseChurnPatterns("rolling-update,foo")
+	assert.Error(t, err)
+}
+
+func TestComputeLatencies(t *testing.T) {
+	var latencies []time.Duration
+	for i := 100; i > 0; i-- {
+		latencies = append(latencies, time.Duration(i)*time.Millisecond)
+	}
+	assert.Equal(t, Latencies{
+		P50: 50 * time.Millisecond,
+		P90: 90 * time.Millisecond,
+		P99: 99 * time.Millisecond,
+		Max: 100 * time.Millisecond,
+	}, computeLatencies(latencies))
+	assert.Equal(t, Latencies{}, computeLatencies(nil))
+}
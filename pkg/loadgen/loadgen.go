@@ -0,0 +1,294 @@
+// Copyright 2020 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package loadgen
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/informers"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/cache"
+
+	"github.com/antoninbas/antrea-k8s-to-ddlog/pkg/controller"
+	"github.com/antoninbas/antrea-k8s-to-ddlog/pkg/program"
+)
+
+const (
+	defaultNamespaces       = 10
+	defaultPodsPerNamespace = 10
+	defaultPolicies         = 10
+	defaultNodes            = 10
+	defaultSettleTime       = 1 * time.Second
+	defaultTimeout          = 5 * time.Minute
+
+	// maxInFlightEvents is the maximum number of events which have been sent to the fake
+	// clientset but not received by the informers yet. The fake clientset panics if too many
+	// watch events are pending.
+	maxInFlightEvents = 50
+)
+
+// Config describes the synthetic cluster and the churn applied to it. The zero value of each field
+// selects the default value.
+type Config struct {
+	Namespaces       int
+	PodsPerNamespace int
+	Policies         int
+	Nodes            int
+	// Churn is the list of churn patterns, applied in a round-robin fashion once the cluster
+	// has been populated.
+	Churn []ChurnPattern
+	// ChurnOperations is the number of churn patterns applied. Each one generates one or more
+	// events.
+	ChurnOperations int
+	Seed            int64
+	// Workers is the number of DDlog worker threads.
+	Workers uint
+	// SettleTime is how long to wait with no DDlog commit before considering that all the
+	// events have been processed.
+	SettleTime time.Duration
+	// Timeout is how long to wait for all the events to be processed once the churn has been
+	// applied, before giving up.
+	Timeout    time.Duration
+	Controller controller.Options
+}
+
+func (c *Config) setDefaults() {
+	if c.Namespaces == 0 {
+		c.Namespaces = defaultNamespaces
+	}
+	if c.PodsPerNamespace == 0 {
+		c.PodsPerNamespace = defaultPodsPerNamespace
+	}
+	if c.Policies == 0 {
+		c.Policies = defaultPolicies
+	}
+	if c.Nodes == 0 {
+		c.Nodes = defaultNodes
+	}
+	if c.Workers == 0 {
+		c.Workers = 1
+	}
+	if c.SettleTime == 0 {
+		c.SettleTime = defaultSettleTime
+	}
+	if c.Timeout == 0 {
+		c.Timeout = defaultTimeout
+	}
+}
+
+// cluster applies changes to the fake clientset and keeps track of the current objects.
+type cluster struct {
+	client kubernetes.Interface
+	gen    *Generator
+	pods   map[string]map[string]*corev1.Pod
+	// sentEvents is the number of changes applied to the clientset, receivedEvents the number of
+	// events received by the informers. receivedEvents is updated by the informer goroutines.
+	sentEvents     int64
+	receivedEvents int64
+}
+
+func newCluster(client kubernetes.Interface, gen *Generator) *cluster {
+	return &cluster{
+		client: client,
+		gen:    gen,
+		pods:   make(map[string]map[string]*corev1.Pod),
+	}
+}
+
+func (c *cluster) eventHandler() cache.ResourceEventHandler {
+	received := func() { atomic.AddInt64(&c.receivedEvents, 1) }
+	return cache.ResourceEventHandlerFuncs{
+		AddFunc:    func(obj interface{}) { received() },
+		UpdateFunc: func(oldObj, newObj interface{}) { received() },
+		DeleteFunc: func(obj interface{}) { received() },
+	}
+}
+
+func (c *cluster) allEventsReceived() bool {
+	return atomic.LoadInt64(&c.receivedEvents) >= c.sentEvents
+}
+
+// send applies a change to the clientset, after waiting for the informers to catch up if needed.
+func (c *cluster) send(change func() error) error {
+	for c.sentEvents-atomic.LoadInt64(&c.receivedEvents) >= maxInFlightEvents {
+		time.Sleep(100 * time.Microsecond)
+	}
+	if err := change(); err != nil {
+		return err
+	}
+	c.sentEvents++
+	return nil
+}
+
+func (c *cluster) createNamespace(ns *corev1.Namespace) error {
+	c.pods[ns.Name] = make(map[string]*corev1.Pod)
+	return c.send(func() error {
+		_, err := c.client.CoreV1().Namespaces().Create(ns)
+		return err
+	})
+}
+
+func (c *cluster) deleteNamespace(name string) error {
+	delete(c.pods, name)
+	return c.send(func() error {
+		return c.client.CoreV1().Namespaces().Delete(name, &metav1.DeleteOptions{})
+	})
+}
+
+func (c *cluster) createPod(pod *corev1.Pod) error {
+	c.pods[pod.Namespace][pod.Name] = pod
+	return c.send(func() error {
+		_, err := c.client.CoreV1().Pods(pod.Namespace).Create(pod)
+		return err
+	})
+}
+
+func (c *cluster) updatePod(pod *corev1.Pod) error {
+	c.pods[pod.Namespace][pod.Name] = pod
+	return c.send(func() error {
+		_, err := c.client.CoreV1().Pods(pod.Namespace).Update(pod)
+		return err
+	})
+}
+
+func (c *cluster) deletePod(pod *corev1.Pod) error {
+	delete(c.pods[pod.Namespace], pod.Name)
+	return c.send(func() error {
+		return c.client.CoreV1().Pods(pod.Namespace).Delete(pod.Name, &metav1.DeleteOptions{})
+	})
+}
+
+func (c *cluster) createNetworkPolicy(np *networkingv1.NetworkPolicy) error {
+	return c.send(func() error {
+		_, err := c.client.NetworkingV1().NetworkPolicies(np.Namespace).Create(np)
+		return err
+	})
+}
+
+// populate creates the Namespaces, then the Pods, then the NetworkPolicies.
+func (c *cluster) populate(cfg *Config) error {
+	for i := 0; i < cfg.Namespaces; i++ {
+		if err := c.createNamespace(c.gen.Namespace(NamespaceName(i))); err != nil {
+			return err
+		}
+	}
+	for i := 0; i < cfg.Namespaces; i++ {
+		for j := 0; j < cfg.PodsPerNamespace; j++ {
+			if err := c.createPod(c.gen.Pod(NamespaceName(i), c.gen.App())); err != nil {
+				return err
+			}
+		}
+	}
+	for i := 0; i < cfg.Policies; i++ {
+		if err := c.createNetworkPolicy(c.gen.NetworkPolicy(NamespaceName(i%cfg.Namespaces), i)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Run creates a DDlog program and a controller watching a fake clientset, populates the clientset
+// with a synthetic cluster, applies the churn and waits for all the changes to be committed to
+// DDlog.
+func Run(cfg Config) (*Result, error) {
+	cfg.setDefaults()
+
+	recorder := newCommitRecorder()
+	ddlogProgram, err := program.NewProgram(cfg.Workers, recorder)
+	if err != nil {
+		return nil, fmt.Errorf("error when creating DDLog program: %v", err)
+	}
+	defer ddlogProgram.Stop()
+
+	client := fake.NewSimpleClientset()
+	informerFactory := informers.NewSharedInformerFactory(client, 0)
+	podInformer := informerFactory.Core().V1().Pods()
+	namespaceInformer := informerFactory.Core().V1().Namespaces()
+	networkPolicyInformer := informerFactory.Networking().V1().NetworkPolicies()
+	c := controller.NewController(client, podInformer, namespaceInformer, networkPolicyInformer, controller.NewProgramSink(ddlogProgram), cfg.Controller)
+
+	cl := newCluster(client, NewGenerator(cfg.Seed, cfg.Nodes))
+	podInformer.Informer().AddEventHandler(cl.eventHandler())
+	namespaceInformer.Informer().AddEventHandler(cl.eventHandler())
+	networkPolicyInformer.Informer().AddEventHandler(cl.eventHandler())
+
+	stopCh := make(chan struct{})
+	controllerDone := make(chan struct{})
+	// The controller drains the pending updates when stopped, so the DDlog program must only be
+	// stopped once Run has returned.
+	defer func() {
+		close(stopCh)
+		<-controllerDone
+	}()
+	informerFactory.Start(stopCh)
+	informerFactory.WaitForCacheSync(stopCh)
+	go func() {
+		defer close(controllerDone)
+		c.Run(stopCh)
+	}()
+
+	sampler := newMemorySampler()
+	go sampler.run(stopCh)
+
+	start := time.Now()
+	if err := cl.populate(&cfg); err != nil {
+		return nil, fmt.Errorf("error when populating cluster: %v", err)
+	}
+	for i := 0; i < cfg.ChurnOperations && len(cfg.Churn) > 0; i++ {
+		if err := cl.churn(cfg.Churn[i%len(cfg.Churn)]); err != nil {
+			return nil, fmt.Errorf("error when applying churn: %v", err)
+		}
+	}
+	// The last commit is only known once no commit happened for SettleTime after all the
+	// events were received.
+	deadline := time.Now().Add(cfg.Timeout)
+	for !cl.allEventsReceived() || !recorder.settled(cfg.SettleTime) {
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("timeout after %v when waiting for all the events to be processed", cfg.Timeout)
+		}
+		time.Sleep(cfg.SettleTime / 10)
+	}
+	sampler.sample()
+
+	pods := 0
+	for _, podsInNamespace := range cl.pods {
+		pods += len(podsInNamespace)
+	}
+	latencies, lastCommit := recorder.latencies()
+	duration := lastCommit.Sub(start)
+	result := &Result{
+		Namespaces:    len(cl.pods),
+		Pods:          pods,
+		Policies:      cfg.Policies,
+		Workers:       cfg.Workers,
+		Events:        int(cl.sentEvents),
+		Duration:      duration,
+		Commits:       len(latencies),
+		OutputChanges: recorder.outputChanges(),
+		CommitLatency: computeLatencies(latencies),
+		PeakHeapBytes: sampler.peakHeap(),
+		PeakRSSBytes:  peakRSS(),
+	}
+	if duration > 0 {
+		result.EventsPerSecond = float64(result.Events) / duration.Seconds()
+	}
+	return result, nil
+}
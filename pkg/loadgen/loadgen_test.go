@@ -0,0 +1,92 @@
+// Copyright 2020 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package loadgen
+
+import (
+	"fmt"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRun(t *testing.T) {
+	cfg := Config{
+		Namespaces:       3,
+		PodsPerNamespace: 5,
+		Policies:         5,
+		Churn:            []ChurnPattern{ChurnRollingUpdate, ChurnLabelFlip, ChurnNamespaceDeletion},
+		ChurnOperations:  6,
+		SettleTime:       300 * time.Millisecond,
+	}
+	result, err := Run(cfg)
+	require.NoError(t, err)
+	assert.Equal(t, 3, result.Namespaces)
+	// Churn does not change the number of Pods.
+	assert.Equal(t, 15, result.Pods)
+	assert.Greater(t, result.Events, 3+15+5)
+	assert.Greater(t, result.Commits, 0)
+	assert.Greater(t, result.EventsPerSecond, 0.0)
+	assert.Greater(t, result.PeakHeapBytes, uint64(0))
+}
+
+func TestRunTimeout(t *testing.T) {
+	cfg := Config{
+		Namespaces:       1,
+		PodsPerNamespace: 1,
+		Policies:         1,
+		// The changes cannot settle before the timeout expires.
+		SettleTime: time.Second,
+		Timeout:    10 * time.Millisecond,
+	}
+	_, err := Run(cfg)
+	assert.Error(t, err)
+}
+
+// BenchmarkRun measures how the throughput and the commit latency scale with the number of DDlog
+// workers, e.g. with "go test -bench=Run -benchtime=1x ./pkg/loadgen".
+func BenchmarkRun(b *testing.B) {
+	workerCounts := []uint{1, 2, 4}
+	if numCPU := uint(runtime.NumCPU()); numCPU > 4 {
+		workerCounts = append(workerCounts, numCPU)
+	}
+	for _, workers := range workerCounts {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			benchmarkRun(b, workers)
+		})
+	}
+}
+
+func benchmarkRun(b *testing.B, workers uint) {
+	cfg := Config{
+		Namespaces:       20,
+		PodsPerNamespace: 50,
+		Policies:         100,
+		Churn:            []ChurnPattern{ChurnRollingUpdate, ChurnLabelFlip, ChurnNamespaceDeletion},
+		ChurnOperations:  30,
+		SettleTime:       500 * time.Millisecond,
+		Workers:          workers,
+	}
+	for i := 0; i < b.N; i++ {
+		result, err := Run(cfg)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.ReportMetric(result.EventsPerSecond, "events/s")
+		b.ReportMetric(float64(result.CommitLatency.P99)/float64(time.Microsecond), "p99-commit-us")
+	}
+}
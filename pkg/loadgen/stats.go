@@ -0,0 +1,219 @@
+// Copyright 2020 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package loadgen
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"text/tabwriter"
+	"time"
+
+	"github.com/vmware/differential-datalog/go/pkg/ddlog"
+)
+
+// Latencies summarizes a distribution of latencies.
+type Latencies struct {
+	P50 time.Duration `json:"p50"`
+	P90 time.Duration `json:"p90"`
+	P99 time.Duration `json:"p99"`
+	Max time.Duration `json:"max"`
+}
+
+// computeLatencies returns the percentiles of the latencies, using the nearest-rank method.
+func computeLatencies(latencies []time.Duration) Latencies {
+	if len(latencies) == 0 {
+		return Latencies{}
+	}
+	sorted := make([]time.Duration, len(latencies))
+	copy(sorted, latencies)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	percentile := func(p float64) time.Duration {
+		return sorted[int(p*float64(len(sorted)-1))]
+	}
+	return Latencies{
+		P50: percentile(0.5),
+		P90: percentile(0.9),
+		P99: percentile(0.99),
+		Max: sorted[len(sorted)-1],
+	}
+}
+
+// Result is the outcome of a load generation run.
+type Result struct {
+	// Namespaces, Pods and Policies are the number of objects at the end of the run.
+	Namespaces int  `json:"namespaces"`
+	Pods       int  `json:"pods"`
+	Policies   int  `json:"policies"`
+	Workers    uint `json:"workers"`
+	// Events is the number of changes applied to the cluster.
+	Events int `json:"events"`
+	// Duration is the time between the first change and the last DDlog commit.
+	Duration        time.Duration `json:"duration"`
+	EventsPerSecond float64       `json:"eventsPerSecond"`
+	Commits         int           `json:"commits"`
+	OutputChanges   uint64        `json:"outputChanges"`
+	CommitLatency   Latencies     `json:"commitLatency"`
+	// PeakHeapBytes is the peak size of the Go heap. PeakRSSBytes is the peak resident set size
+	// of the process, which includes the memory allocated by DDlog. It is only available on
+	// Linux.
+	PeakHeapBytes uint64 `json:"peakHeapBytes"`
+	PeakRSSBytes  uint64 `json:"peakRSSBytes,omitempty"`
+}
+
+// Print writes a human-readable report.
+func (r *Result) Print(w io.Writer) {
+	fmt.Fprintf(w, "Cluster:          %d Namespaces, %d Pods, %d NetworkPolicies\n", r.Namespaces, r.Pods, r.Policies)
+	fmt.Fprintf(w, "DDlog workers:    %d\n", r.Workers)
+	fmt.Fprintf(w, "Events:           %d in %v (%.1f events/s)\n", r.Events, r.Duration, r.EventsPerSecond)
+	fmt.Fprintf(w, "Commits:          %d (%d output changes)\n", r.Commits, r.OutputChanges)
+	fmt.Fprintf(w, "Commit latency:   p50=%v p90=%v p99=%v max=%v\n", r.CommitLatency.P50, r.CommitLatency.P90, r.CommitLatency.P99, r.CommitLatency.Max)
+	fmt.Fprintf(w, "Peak Go heap:     %.1f MiB\n", float64(r.PeakHeapBytes)/(1<<20))
+	if r.PeakRSSBytes > 0 {
+		fmt.Fprintf(w, "Peak process RSS: %.1f MiB\n", float64(r.PeakRSSBytes)/(1<<20))
+	}
+}
+
+// PrintComparison writes a table comparing the results of several runs, e.g. with different numbers
+// of DDlog workers. The peak RSS is not included since it is the peak over the whole process.
+func PrintComparison(w io.Writer, results []*Result) {
+	tw := tabwriter.NewWriter(w, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(tw, "WORKERS\tEVENTS/S\tCOMMITS\tP50\tP90\tP99\tMAX\tPEAK HEAP (MiB)")
+	for _, r := range results {
+		fmt.Fprintf(tw, "%d\t%.1f\t%d\t%v\t%v\t%v\t%v\t%.1f\n", r.Workers, r.EventsPerSecond, r.Commits, r.CommitLatency.P50, r.CommitLatency.P90, r.CommitLatency.P99, r.CommitLatency.Max, float64(r.PeakHeapBytes)/(1<<20))
+	}
+	tw.Flush()
+}
+
+// commitRecorder is a ddlog.OutRecordHandler and an outhandler.CommitObserver which records the
+// latency of each DDlog commit.
+type commitRecorder struct {
+	// changes is first in the struct for 64-bit alignment, since it is accessed atomically.
+	changes    uint64
+	mutex      sync.Mutex
+	start      time.Time
+	commits    []time.Duration
+	lastCommit time.Time
+}
+
+func newCommitRecorder() *commitRecorder {
+	return &commitRecorder{}
+}
+
+// Handle may be called concurrently by the DDlog workers.
+func (r *commitRecorder) Handle(tableID ddlog.TableID, record ddlog.Record, outPolarity ddlog.OutPolarity) {
+	atomic.AddUint64(&r.changes, 1)
+}
+
+// CommitStarted and CommitEnded are never called concurrently, since commits are serialized by
+// program.Program.
+func (r *commitRecorder) CommitStarted(txnID uint64) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.start = time.Now()
+}
+
+func (r *commitRecorder) CommitEnded(txnID uint64, err error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.lastCommit = time.Now()
+	r.commits = append(r.commits, r.lastCommit.Sub(r.start))
+}
+
+func (r *commitRecorder) outputChanges() uint64 {
+	return atomic.LoadUint64(&r.changes)
+}
+
+// settled returns true if there was at least one commit, and no commit for settleTime.
+func (r *commitRecorder) settled(settleTime time.Duration) bool {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return len(r.commits) > 0 && time.Since(r.lastCommit) >= settleTime
+}
+
+// latencies returns the latency of each commit and the time at which the last commit ended.
+func (r *commitRecorder) latencies() ([]time.Duration, time.Time) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.commits, r.lastCommit
+}
+
+// memorySampler periodically records the size of the Go heap, to determine its peak value.
+type memorySampler struct {
+	peak uint64
+}
+
+const memorySampleInterval = 50 * time.Millisecond
+
+func newMemorySampler() *memorySampler {
+	return &memorySampler{}
+}
+
+func (s *memorySampler) sample() {
+	var stats runtime.MemStats
+	runtime.ReadMemStats(&stats)
+	for {
+		peak := atomic.LoadUint64(&s.peak)
+		if stats.HeapInuse <= peak || atomic.CompareAndSwapUint64(&s.peak, peak, stats.HeapInuse) {
+			return
+		}
+	}
+}
+
+func (s *memorySampler) run(stopCh <-chan struct{}) {
+	ticker := time.NewTicker(memorySampleInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.sample()
+		case <-stopCh:
+			return
+		}
+	}
+}
+
+func (s *memorySampler) peakHeap() uint64 {
+	return atomic.LoadUint64(&s.peak)
+}
+
+// peakRSS returns the peak resident set size of the process, as reported by the VmHWM field of
+// /proc/self/status, or 0 if it is not available.
+func peakRSS() uint64 {
+	f, err := os.Open("/proc/self/status")
+	if err != nil {
+		return 0
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 3 && fields[0] == "VmHWM:" && fields[2] == "kB" {
+			kb, err := strconv.ParseUint(fields[1], 10, 64)
+			if err != nil {
+				return 0
+			}
+			return kb * 1024
+		}
+	}
+	return 0
+}
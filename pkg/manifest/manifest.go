@@ -0,0 +1,351 @@
+// Copyright 2020 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package manifest loads Pods, Namespaces and NetworkPolicies from YAML manifests, so that policies
+// can be computed without a cluster. Since the manifests describe the desired state, the objects
+// are completed with the information which would normally be filled in by K8s: Deployments are
+// expanded into Pods, and Pods are assigned a UID, a Node and an IP address.
+package manifest
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha1"
+	"fmt"
+	"io"
+	"net"
+	"os"
+
+	appsv1 "k8s.io/api/apps/v1"
+	"k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/yaml"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+const (
+	// DefaultNamespace is used for namespaced objects with no Namespace in their metadata, like
+	// kubectl does.
+	DefaultNamespace = "default"
+	// DefaultPodCIDR is the CIDR from which synthetic Pod IPs are allocated.
+	DefaultPodCIDR = "10.10.0.0/16"
+	// DefaultNumNodes is the default number of synthetic Nodes.
+	DefaultNumNodes = 1
+)
+
+// Options configures how the objects are completed.
+type Options struct {
+	// NumNodes is the number of synthetic Nodes across which Pods with no Node name are
+	// scheduled, in a round-robin fashion. Nodes are named "node-0", "node-1", ...
+	NumNodes int
+	// PodCIDR is the CIDR from which IPs are allocated to Pods with no IP.
+	PodCIDR string
+}
+
+// Objects are the K8s objects loaded from manifests, in the order in which they were found.
+type Objects struct {
+	Pods            []*v1.Pod
+	Namespaces      []*v1.Namespace
+	NetworkPolicies []*networkingv1.NetworkPolicy
+}
+
+// LoadFiles loads all the objects from the provided manifest files. A file name of "-" means stdin.
+func LoadFiles(fileNames []string, options Options) (*Objects, error) {
+	var readers []io.Reader
+	for _, fileName := range fileNames {
+		if fileName == "-" {
+			readers = append(readers, os.Stdin)
+			continue
+		}
+		f, err := os.Open(fileName)
+		if err != nil {
+			return nil, fmt.Errorf("error when opening manifest file: %v", err)
+		}
+		defer f.Close()
+		readers = append(readers, f)
+	}
+	return Load(readers, options)
+}
+
+// Load loads all the objects from the provided multi-document YAML (or JSON) streams. Objects of
+// unsupported kinds are ignored. Namespaces which are referenced but not defined are created
+// implicitly, with no labels.
+func Load(readers []io.Reader, options Options) (*Objects, error) {
+	l, err := NewLoader(options)
+	if err != nil {
+		return nil, err
+	}
+	return l.Load(readers...)
+}
+
+// Loader loads objects from manifests in several steps, e.g. in an interactive session. Generated
+// values stay consistent across steps: Pod IPs are never re-used and Namespaces are only created
+// implicitly if they were not loaded in a previous step.
+type Loader struct {
+	options    Options
+	objects    *Objects
+	namespaces map[string]bool
+	podIPs     map[string]bool
+	nextPod    int
+	podNet     *net.IPNet
+	nextIP     net.IP
+}
+
+// NewLoader creates a new Loader.
+func NewLoader(options Options) (*Loader, error) {
+	if options.NumNodes <= 0 {
+		options.NumNodes = DefaultNumNodes
+	}
+	if options.PodCIDR == "" {
+		options.PodCIDR = DefaultPodCIDR
+	}
+	_, podNet, err := net.ParseCIDR(options.PodCIDR)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Pod CIDR '%s': %v", options.PodCIDR, err)
+	}
+	if podNet.IP.To4() == nil {
+		return nil, fmt.Errorf("Pod CIDR '%s' is not an IPv4 CIDR", options.PodCIDR)
+	}
+	return &Loader{
+		options:    options,
+		namespaces: make(map[string]bool),
+		podIPs:     make(map[string]bool),
+		podNet:     podNet,
+		nextIP:     append(net.IP(nil), podNet.IP.To4()...),
+	}, nil
+}
+
+// Load loads all the objects from the provided streams, see the Load function. It returns the
+// objects loaded in this step only.
+func (l *Loader) Load(readers ...io.Reader) (*Objects, error) {
+	var objs []interface{}
+	for _, r := range readers {
+		decoded, err := decodeAll(r)
+		if err != nil {
+			return nil, err
+		}
+		objs = append(objs, decoded...)
+	}
+	l.objects = &Objects{}
+	// Namespaces and Pod IPs are collected first, so that explicit values always take
+	// precedence over generated ones, regardless of the order of the documents.
+	for _, obj := range objs {
+		switch o := obj.(type) {
+		case *v1.Namespace:
+			l.namespaces[o.Name] = true
+		case *v1.Pod:
+			if o.Status.PodIP != "" {
+				l.podIPs[o.Status.PodIP] = true
+			}
+		}
+	}
+	for _, obj := range objs {
+		if err := l.add(obj); err != nil {
+			return nil, err
+		}
+	}
+	return l.objects, nil
+}
+
+// LoaderState is a snapshot of the state of a Loader, taken with Loader.State.
+type LoaderState struct {
+	namespaces map[string]bool
+	podIPs     map[string]bool
+	nextPod    int
+	nextIP     net.IP
+}
+
+func copySet(set map[string]bool) map[string]bool {
+	c := make(map[string]bool, len(set))
+	for k := range set {
+		c[k] = true
+	}
+	return c
+}
+
+// State returns a snapshot of the Namespaces and Pod IPs known to the Loader, so that the steps
+// loaded after it can be undone with Restore, e.g. when a transaction is rolled back.
+func (l *Loader) State() *LoaderState {
+	return &LoaderState{
+		namespaces: copySet(l.namespaces),
+		podIPs:     copySet(l.podIPs),
+		nextPod:    l.nextPod,
+		nextIP:     append(net.IP(nil), l.nextIP...),
+	}
+}
+
+// Restore restores a snapshot returned by State.
+func (l *Loader) Restore(state *LoaderState) {
+	l.namespaces = copySet(state.namespaces)
+	l.podIPs = copySet(state.podIPs)
+	l.nextPod = state.nextPod
+	l.nextIP = append(net.IP(nil), state.nextIP...)
+}
+
+// DeleteNamespace records that a Namespace was deleted, so that it is created implicitly again if
+// an object loaded in a later step belongs to it.
+func (l *Loader) DeleteNamespace(name string) {
+	delete(l.namespaces, name)
+}
+
+func decodeAll(r io.Reader) ([]interface{}, error) {
+	var objs []interface{}
+	reader := yaml.NewYAMLReader(bufio.NewReader(r))
+	decoder := scheme.Codecs.UniversalDeserializer()
+	for {
+		doc, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error when reading manifest: %v", err)
+		}
+		if len(bytes.TrimSpace(doc)) == 0 {
+			continue
+		}
+		obj, _, err := decoder.Decode(doc, nil, nil)
+		if err != nil {
+			return nil, fmt.Errorf("error when decoding manifest: %v", err)
+		}
+		if list, ok := obj.(*v1.List); ok {
+			for _, item := range list.Items {
+				obj, _, err := decoder.Decode(item.Raw, nil, nil)
+				if err != nil {
+					return nil, fmt.Errorf("error when decoding List item: %v", err)
+				}
+				objs = append(objs, obj)
+			}
+			continue
+		}
+		objs = append(objs, obj)
+	}
+	return objs, nil
+}
+
+// generateUID returns a deterministic UID for an object, so that the output for a given set of
+// manifests is always the same.
+func generateUID(kind, namespace, name string) types.UID {
+	h := sha1.Sum([]byte(kind + "/" + namespace + "/" + name))
+	return types.UID(fmt.Sprintf("%x-%x-%x-%x-%x", h[0:4], h[4:6], h[6:8], h[8:10], h[10:16]))
+}
+
+func (l *Loader) addNamespace(name string) {
+	if l.namespaces[name] {
+		return
+	}
+	l.namespaces[name] = true
+	l.objects.Namespaces = append(l.objects.Namespaces, &v1.Namespace{
+		ObjectMeta: metav1.ObjectMeta{Name: name, UID: generateUID("Namespace", "", name)},
+	})
+}
+
+func (l *Loader) completeMeta(kind string, meta *metav1.ObjectMeta) {
+	if meta.Namespace == "" {
+		meta.Namespace = DefaultNamespace
+	}
+	if meta.UID == "" {
+		meta.UID = generateUID(kind, meta.Namespace, meta.Name)
+	}
+	l.addNamespace(meta.Namespace)
+}
+
+func (l *Loader) allocateIP() (string, error) {
+	for {
+		ip := make(net.IP, len(l.nextIP))
+		copy(ip, l.nextIP)
+		// Increment nextIP.
+		for i := len(l.nextIP) - 1; i >= 0; i-- {
+			l.nextIP[i]++
+			if l.nextIP[i] != 0 {
+				break
+			}
+		}
+		if !l.podNet.Contains(ip) {
+			return "", fmt.Errorf("no more IPs available in Pod CIDR '%s'", l.options.PodCIDR)
+		}
+		// Skip the network address, as well as IPs which were assigned explicitly.
+		if ip.Equal(l.podNet.IP) || l.podIPs[ip.String()] {
+			continue
+		}
+		l.podIPs[ip.String()] = true
+		return ip.String(), nil
+	}
+}
+
+func (l *Loader) addPod(pod *v1.Pod) error {
+	l.completeMeta("Pod", &pod.ObjectMeta)
+	if pod.Spec.NodeName == "" {
+		pod.Spec.NodeName = fmt.Sprintf("node-%d", l.nextPod%l.options.NumNodes)
+	}
+	l.nextPod++
+	if pod.Status.PodIP == "" {
+		ip, err := l.allocateIP()
+		if err != nil {
+			return err
+		}
+		pod.Status.PodIP = ip
+	}
+	pod.Status.Phase = v1.PodRunning
+	l.objects.Pods = append(l.objects.Pods, pod)
+	return nil
+}
+
+// expandDeployment returns the synthetic Pods for a Deployment. Pods are named after the
+// Deployment, with their index as a suffix.
+func expandDeployment(deployment *appsv1.Deployment) []*v1.Pod {
+	replicas := int32(1)
+	if deployment.Spec.Replicas != nil {
+		replicas = *deployment.Spec.Replicas
+	}
+	namespace := deployment.Namespace
+	if namespace == "" {
+		namespace = DefaultNamespace
+	}
+	pods := make([]*v1.Pod, 0, replicas)
+	for i := int32(0); i < replicas; i++ {
+		template := deployment.Spec.Template.DeepCopy()
+		pod := &v1.Pod{
+			ObjectMeta: template.ObjectMeta,
+			Spec:       template.Spec,
+		}
+		pod.Name = fmt.Sprintf("%s-%d", deployment.Name, i)
+		pod.Namespace = namespace
+		pods = append(pods, pod)
+	}
+	return pods
+}
+
+func (l *Loader) add(obj interface{}) error {
+	switch o := obj.(type) {
+	case *v1.Namespace:
+		if o.UID == "" {
+			o.UID = generateUID("Namespace", "", o.Name)
+		}
+		l.objects.Namespaces = append(l.objects.Namespaces, o)
+	case *v1.Pod:
+		return l.addPod(o)
+	case *appsv1.Deployment:
+		for _, pod := range expandDeployment(o) {
+			if err := l.addPod(pod); err != nil {
+				return err
+			}
+		}
+	case *networkingv1.NetworkPolicy:
+		l.completeMeta("NetworkPolicy", &o.ObjectMeta)
+		l.objects.NetworkPolicies = append(l.objects.NetworkPolicies, o)
+	}
+	return nil
+}
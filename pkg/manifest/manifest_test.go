@@ -0,0 +1,170 @@
+// Copyright 2020 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package manifest
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testManifest = `
+apiVersion: networking.k8s.io/v1
+kind: NetworkPolicy
+metadata:
+  name: np1
+  namespace: ns1
+spec:
+  podSelector:
+    matchLabels:
+      app: web
+---
+apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+  namespace: ns1
+spec:
+  selector:
+    matchLabels:
+      app: web
+  replicas: 3
+  template:
+    metadata:
+      labels:
+        app: web
+    spec:
+      containers:
+      - name: nginx
+        image: nginx
+---
+apiVersion: v1
+kind: Pod
+metadata:
+  name: client
+  labels:
+    app: client
+spec:
+  nodeName: node-7
+  containers:
+  - name: busybox
+    image: busybox
+status:
+  podIP: 10.10.0.1
+---
+apiVersion: v1
+kind: Namespace
+metadata:
+  name: ns1
+  labels:
+    env: prod
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: ignored
+spec:
+  ports:
+  - port: 80
+`
+
+func TestLoad(t *testing.T) {
+	objects, err := Load([]io.Reader{strings.NewReader(testManifest)}, Options{NumNodes: 2})
+	require.NoError(t, err)
+
+	require.Len(t, objects.NetworkPolicies, 1)
+	np := objects.NetworkPolicies[0]
+	assert.Equal(t, "ns1", np.Namespace)
+	assert.NotEmpty(t, np.UID)
+
+	require.Len(t, objects.Pods, 4)
+	var names, nodes, ips []string
+	for _, pod := range objects.Pods {
+		names = append(names, pod.Namespace+"/"+pod.Name)
+		nodes = append(nodes, pod.Spec.NodeName)
+		ips = append(ips, pod.Status.PodIP)
+		assert.NotEmpty(t, pod.UID)
+	}
+	assert.Equal(t, []string{"ns1/web-0", "ns1/web-1", "ns1/web-2", "default/client"}, names)
+	assert.Equal(t, []string{"node-0", "node-1", "node-0", "node-7"}, nodes)
+	// 10.10.0.1 is assigned explicitly to the client Pod.
+	assert.Equal(t, []string{"10.10.0.2", "10.10.0.3", "10.10.0.4", "10.10.0.1"}, ips)
+	assert.Equal(t, map[string]string{"app": "web"}, objects.Pods[0].Labels)
+
+	// The "default" Namespace is created implicitly, "ns1" is defined explicitly.
+	require.Len(t, objects.Namespaces, 2)
+	assert.Equal(t, "default", objects.Namespaces[0].Name)
+	assert.Empty(t, objects.Namespaces[0].Labels)
+	assert.Equal(t, "ns1", objects.Namespaces[1].Name)
+	assert.Equal(t, map[string]string{"env": "prod"}, objects.Namespaces[1].Labels)
+
+	// UIDs are deterministic.
+	objects2, err := Load([]io.Reader{strings.NewReader(testManifest)}, Options{NumNodes: 2})
+	require.NoError(t, err)
+	assert.Equal(t, np.UID, objects2.NetworkPolicies[0].UID)
+}
+
+func TestLoadInvalid(t *testing.T) {
+	_, err := Load([]io.Reader{strings.NewReader("kind: Foo\napiVersion: v1\n")}, Options{})
+	assert.Error(t, err)
+}
+
+func TestLoaderSteps(t *testing.T) {
+	l, err := NewLoader(Options{})
+	require.NoError(t, err)
+
+	objects, err := l.Load(strings.NewReader("apiVersion: v1\nkind: Namespace\nmetadata:\n  name: ns1\n  labels:\n    env: prod\n"))
+	require.NoError(t, err)
+	require.Len(t, objects.Namespaces, 1)
+
+	pod := "apiVersion: v1\nkind: Pod\nmetadata:\n  name: %s\n  namespace: ns1\n"
+	objects, err = l.Load(strings.NewReader(strings.Replace(pod, "%s", "pod1", 1)))
+	require.NoError(t, err)
+	// ns1 was loaded in the previous step, it must not be created again with no labels.
+	assert.Empty(t, objects.Namespaces)
+	require.Len(t, objects.Pods, 1)
+	assert.Equal(t, "10.10.0.1", objects.Pods[0].Status.PodIP)
+
+	objects, err = l.Load(strings.NewReader(strings.Replace(pod, "%s", "pod2", 1)))
+	require.NoError(t, err)
+	require.Len(t, objects.Pods, 1)
+	assert.Equal(t, "10.10.0.2", objects.Pods[0].Status.PodIP)
+}
+
+func TestLoaderRestore(t *testing.T) {
+	l, err := NewLoader(Options{})
+	require.NoError(t, err)
+	pod := "apiVersion: v1\nkind: Pod\nmetadata:\n  name: pod1\n  namespace: ns1\n"
+
+	state := l.State()
+	objects, err := l.Load(strings.NewReader(pod))
+	require.NoError(t, err)
+	require.Len(t, objects.Namespaces, 1)
+	l.Restore(state)
+	// The Namespace and the Pod IP generated in the step which was undone are generated again.
+	objects, err = l.Load(strings.NewReader(pod))
+	require.NoError(t, err)
+	require.Len(t, objects.Namespaces, 1)
+	require.Len(t, objects.Pods, 1)
+	assert.Equal(t, "10.10.0.1", objects.Pods[0].Status.PodIP)
+
+	l.DeleteNamespace("ns1")
+	objects, err = l.Load(strings.NewReader(pod))
+	require.NoError(t, err)
+	assert.Len(t, objects.Namespaces, 1)
+}
@@ -0,0 +1,117 @@
+// Copyright 2020 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outhandler
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+
+	"github.com/antoninbas/antrea-k8s-to-ddlog/pkg/ddlogk8s"
+	"github.com/vmware/differential-datalog/go/pkg/ddlog"
+)
+
+// CollectedRecord is an output record, with its text representation and its decoded value. The
+// value is the text representation if the record cannot be decoded.
+type CollectedRecord struct {
+	Text  string
+	Value interface{}
+}
+
+// Collector is a ddlog.OutRecordHandler which maintains the current contents of all the output
+// relations.
+type Collector struct {
+	mutex     sync.Mutex
+	relations map[string]map[string]interface{}
+}
+
+// NewCollector creates a new Collector.
+func NewCollector() *Collector {
+	return &Collector{relations: make(map[string]map[string]interface{})}
+}
+
+// Handle implements ddlog.OutRecordHandler.
+func (c *Collector) Handle(tableID ddlog.TableID, r ddlog.Record, outPolarity ddlog.OutPolarity) {
+	relation := ddlog.GetTableName(tableID)
+	text := r.Dump()
+	var value interface{} = text
+	if decoded, err := ddlogk8s.RecordToOutput(tableID, r); err == nil {
+		value = decoded
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	records, ok := c.relations[relation]
+	if !ok {
+		records = make(map[string]interface{})
+		c.relations[relation] = records
+	}
+	if outPolarity == ddlog.OutPolarityInsert {
+		records[text] = value
+	} else {
+		delete(records, text)
+	}
+}
+
+// List returns the non-empty relations, sorted by name, and the records of each relation, sorted
+// by text representation.
+func (c *Collector) List() ([]string, map[string][]CollectedRecord) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	relations := make([]string, 0, len(c.relations))
+	records := make(map[string][]CollectedRecord, len(c.relations))
+	for relation, recordsByText := range c.relations {
+		if len(recordsByText) == 0 {
+			continue
+		}
+		relations = append(relations, relation)
+		for text, value := range recordsByText {
+			records[relation] = append(records[relation], CollectedRecord{Text: text, Value: value})
+		}
+		sort.Slice(records[relation], func(i, j int) bool {
+			return records[relation][i].Text < records[relation][j].Text
+		})
+	}
+	sort.Strings(relations)
+	return relations, records
+}
+
+// Print writes the contents of all the relations, in "text" or "json" format.
+func (c *Collector) Print(w io.Writer, format string) error {
+	relations, records := c.List()
+	switch format {
+	case "text":
+		for _, relation := range relations {
+			fmt.Fprintf(w, "%s:\n", relation)
+			for _, record := range records[relation] {
+				fmt.Fprintf(w, "  %s\n", record.Text)
+			}
+		}
+	case "json":
+		values := make(map[string][]interface{}, len(relations))
+		for _, relation := range relations {
+			for _, record := range records[relation] {
+				values[relation] = append(values[relation], record.Value)
+			}
+		}
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(values)
+	default:
+		return fmt.Errorf("invalid output format '%s', must be one of 'text' or 'json'", format)
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+// Copyright 2020 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outhandler
+
+// CommitObserver is implemented by output handlers which need to know about transaction
+// boundaries. DDlog only reports changes to output relations when a transaction is committed, so
+// all the calls to Handle between CommitStarted and CommitEnded belong to the same transaction.
+type CommitObserver interface {
+	// CommitStarted is called right before the transaction identified by txnID is committed.
+	// Transaction IDs are assigned in increasing order, starting at 1.
+	CommitStarted(txnID uint64)
+	// CommitEnded is called once the commit has completed. err is not nil if the commit
+	// failed, in which case the changes received since CommitStarted may be incomplete.
+	CommitEnded(txnID uint64, err error)
+}
+
+// CommitStarted forwards the notification to all the handlers which implement CommitObserver.
+func (m *Multi) CommitStarted(txnID uint64) {
+	for _, h := range m.handlers {
+		if o, ok := h.(CommitObserver); ok {
+			o.CommitStarted(txnID)
+		}
+	}
+}
+
+// CommitEnded forwards the notification to all the handlers which implement CommitObserver.
+func (m *Multi) CommitEnded(txnID uint64, err error) {
+	for _, h := range m.handlers {
+		if o, ok := h.(CommitObserver); ok {
+			o.CommitEnded(txnID, err)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+// Copyright 2020 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outhandler
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"k8s.io/klog"
+
+	"github.com/antoninbas/antrea-k8s-to-ddlog/pkg/ddlogk8s"
+	"github.com/vmware/differential-datalog/go/pkg/ddlog"
+)
+
+// JSONLEntryType is the type of a line written by JSONLDumper.
+type JSONLEntryType string
+
+const (
+	JSONLEntryBegin  JSONLEntryType = "begin"
+	JSONLEntryChange JSONLEntryType = "change"
+	JSONLEntryEnd    JSONLEntryType = "end"
+)
+
+// JSONLPolarity indicates whether an output record was inserted or deleted.
+type JSONLPolarity string
+
+const (
+	JSONLPolarityInsert JSONLPolarity = "insert"
+	JSONLPolarityDelete JSONLPolarity = "delete"
+)
+
+// JSONLEntry is the JSON object written on each line by JSONLDumper. Each transaction is written
+// as a "begin" entry, followed by one "change" entry per output change, followed by an "end"
+// entry. All entries for a transaction have the same commit sequence number (Seq).
+type JSONLEntry struct {
+	Type JSONLEntryType `json:"type"`
+	Seq  uint64         `json:"seq"`
+	// The following fields are only set for "change" entries.
+	Relation string        `json:"relation,omitempty"`
+	Polarity JSONLPolarity `json:"polarity,omitempty"`
+	// Value is the decoded output record (see ddlogk8s.RecordToOutput). If the record cannot
+	// be decoded, Value is omitted and Raw is set to the DDlog text representation instead.
+	Value interface{} `json:"value,omitempty"`
+	Raw   string      `json:"raw,omitempty"`
+	// Error is only set for "end" entries, if the commit failed.
+	Error string `json:"error,omitempty"`
+}
+
+// JSONLDumper implements the ddlog.OutRecordHandler and CommitObserver interfaces: it writes all
+// the changes received from DDlog as JSON Lines, with explicit transaction boundaries. Errors
+// occurring when writing are logged and otherwise ignored.
+type JSONLDumper struct {
+	// mutex is used to serialize all the writes, as Handle may be called concurrently.
+	mutex  sync.Mutex
+	w      *bufio.Writer
+	closer io.Closer
+	seq    uint64
+}
+
+// NewJSONLDumper creates a JSONLDumper which writes to w.
+func NewJSONLDumper(w io.Writer) *JSONLDumper {
+	return &JSONLDumper{w: bufio.NewWriter(w)}
+}
+
+// NewJSONLFileDumper creates a JSONLDumper which writes to a new file with the provided name. If the
+// file already exists, it will be truncated.
+func NewJSONLFileDumper(changesFileName string) (*JSONLDumper, error) {
+	changesFile, err := os.Create(changesFileName)
+	if err != nil {
+		return nil, fmt.Errorf("error when creating file '%s' to dump changes: %v", changesFileName, err)
+	}
+	d := NewJSONLDumper(changesFile)
+	d.closer = changesFile
+	return d, nil
+}
+
+func (d *JSONLDumper) writeEntry(entry *JSONLEntry) {
+	b, err := json.Marshal(entry)
+	if err != nil {
+		klog.Errorf("Error when encoding change as JSON: %v", err)
+		return
+	}
+	b = append(b, '\n')
+	if _, err := d.w.Write(b); err != nil {
+		klog.Errorf("Error when writing change: %v", err)
+	}
+}
+
+// Handle writes a "change" entry for the output record.
+func (d *JSONLDumper) Handle(tableID ddlog.TableID, r ddlog.Record, outPolarity ddlog.OutPolarity) {
+	entry := &JSONLEntry{
+		Type:     JSONLEntryChange,
+		Relation: ddlog.GetTableName(tableID),
+		Polarity: JSONLPolarityInsert,
+	}
+	if outPolarity == ddlog.OutPolarityDelete {
+		entry.Polarity = JSONLPolarityDelete
+	}
+	if value, err := ddlogk8s.RecordToOutput(tableID, r); err == nil {
+		entry.Value = value
+	} else {
+		entry.Raw = r.Dump()
+	}
+	d.writeChange(entry)
+}
+
+func (d *JSONLDumper) writeChange(entry *JSONLEntry) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	entry.Seq = d.seq
+	d.writeEntry(entry)
+}
+
+// CommitStarted writes a "begin" entry.
+func (d *JSONLDumper) CommitStarted(txnID uint64) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.seq = txnID
+	d.writeEntry(&JSONLEntry{Type: JSONLEntryBegin, Seq: txnID})
+}
+
+// CommitEnded writes an "end" entry and flushes all buffered entries.
+func (d *JSONLDumper) CommitEnded(txnID uint64, err error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	entry := &JSONLEntry{Type: JSONLEntryEnd, Seq: txnID}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	d.writeEntry(entry)
+	if err := d.w.Flush(); err != nil {
+		klog.Errorf("Error when flushing changes: %v", err)
+	}
+}
+
+// Close flushes all buffered entries and closes the underlying file, if the JSONLDumper was created
+// with NewJSONLFileDumper.
+func (d *JSONLDumper) Close() error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if err := d.w.Flush(); err != nil {
+		return err
+	}
+	if d.closer != nil {
+		return d.closer.Close()
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+// Copyright 2020 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outhandler
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/antoninbas/antrea-k8s-to-ddlog/pkg/ddlogk8s"
+)
+
+func TestJSONLDumper(t *testing.T) {
+	var b bytes.Buffer
+	d := NewJSONLDumper(&b)
+
+	d.CommitStarted(1)
+	d.writeChange(&JSONLEntry{
+		Type:     JSONLEntryChange,
+		Relation: "AddressGroupAddress",
+		Polarity: JSONLPolarityInsert,
+		Value:    &ddlogk8s.AddressGroupAddress{AddressGroup: "group1", Address: "10.0.0.1"},
+	})
+	// Nothing is written until the end of the transaction.
+	assert.Empty(t, b.String())
+	d.CommitEnded(1, nil)
+	d.CommitStarted(2)
+	d.CommitEnded(2, fmt.Errorf("failed"))
+	require.Nil(t, d.Close())
+
+	lines := strings.Split(strings.TrimSpace(b.String()), "\n")
+	assert.Equal(t, []string{
+		`{"type":"begin","seq":1}`,
+		`{"type":"change","seq":1,"relation":"AddressGroupAddress","polarity":"insert","value":{"addressGroup":"group1","address":"10.0.0.1"}}`,
+		`{"type":"end","seq":1}`,
+		`{"type":"begin","seq":2}`,
+		`{"type":"end","seq":2,"error":"failed"}`,
+	}, lines)
+}
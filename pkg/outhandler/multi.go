@@ -0,0 +1,46 @@
+// Copyright 2020 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package outhandler provides implementations of the ddlog.OutRecordHandler interface, which
+// consume the changes to the output relations reported by DDlog.
+package outhandler
+
+import (
+	"github.com/vmware/differential-datalog/go/pkg/ddlog"
+)
+
+// Multi implements the ddlog.OutRecordHandler interface: it forwards every change received from
+// DDlog to a list of handlers, in order.
+type Multi struct {
+	handlers []ddlog.OutRecordHandler
+}
+
+// NewMulti creates a Multi instance which forwards changes to the provided handlers. nil handlers
+// are ignored.
+func NewMulti(handlers ...ddlog.OutRecordHandler) *Multi {
+	m := &Multi{}
+	for _, h := range handlers {
+		if h != nil {
+			m.handlers = append(m.handlers, h)
+		}
+	}
+	return m
+}
+
+// Handle forwards the change to all the handlers.
+func (m *Multi) Handle(tableID ddlog.TableID, r ddlog.Record, outPolarity ddlog.OutPolarity) {
+	for _, h := range m.handlers {
+		h.Handle(tableID, r, outPolarity)
+	}
+}
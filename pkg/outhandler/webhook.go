@@ -0,0 +1,331 @@
+// Copyright 2020 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outhandler
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"k8s.io/klog"
+
+	"github.com/antoninbas/antrea-k8s-to-ddlog/pkg/ddlogk8s"
+	"github.com/vmware/differential-datalog/go/pkg/ddlog"
+)
+
+const (
+	defaultWebhookQueueSize      = 100
+	defaultWebhookMaxRetries     = 5
+	defaultWebhookInitialBackoff = 100 * time.Millisecond
+	defaultWebhookMaxBackoff     = 10 * time.Second
+	defaultWebhookTimeout        = 10 * time.Second
+)
+
+// WebhookChange is a single output change in a WebhookBatch.
+type WebhookChange struct {
+	Relation string        `json:"relation"`
+	Polarity JSONLPolarity `json:"polarity"`
+	// Value is the decoded output record (see ddlogk8s.RecordToOutput). If the record cannot
+	// be decoded, Value is omitted and Raw is set to the DDlog text representation instead.
+	Value interface{} `json:"value,omitempty"`
+	Raw   string      `json:"raw,omitempty"`
+}
+
+// WebhookBatch is the body of the POST requests sent by Webhook: all the output changes for a
+// committed transaction. Batches are delivered in order for each URL.
+type WebhookBatch struct {
+	Seq     uint64          `json:"seq"`
+	Changes []WebhookChange `json:"changes"`
+}
+
+// WebhookOptions configures a Webhook. Zero values are replaced with defaults.
+type WebhookOptions struct {
+	// URLs is the list of URLs to which batches are POSTed.
+	URLs []string
+	// QueueSize is the maximum number of batches queued for each URL. When the queue is full,
+	// new batches are dropped for that URL.
+	QueueSize int
+	// MaxRetries is the number of times the delivery of a batch is retried before giving up. A
+	// negative value disables retries.
+	MaxRetries int
+	// InitialBackoff is the delay before the first retry; the delay is doubled for each
+	// subsequent retry, up to MaxBackoff.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+	// Timeout is the timeout for each POST request.
+	Timeout time.Duration
+}
+
+// WebhookMetrics are the delivery metrics for one URL.
+type WebhookMetrics struct {
+	URL string `json:"url"`
+	// Delivered is the number of batches delivered successfully.
+	Delivered uint64 `json:"delivered"`
+	// Failed is the number of batches which could not be delivered after MaxRetries retries.
+	Failed uint64 `json:"failed"`
+	// Dropped is the number of batches which were dropped because the queue was full.
+	Dropped uint64 `json:"dropped"`
+	// Retries is the total number of retries.
+	Retries uint64 `json:"retries"`
+	// QueueLength is the number of batches currently queued.
+	QueueLength int `json:"queueLength"`
+}
+
+type webhookEndpoint struct {
+	// The counters are accessed atomically and are kept first in the struct to guarantee 64-bit
+	// alignment.
+	delivered uint64
+	failed    uint64
+	dropped   uint64
+	retries   uint64
+	// pending is the number of batches which are queued or being delivered.
+	pending int64
+	url     string
+	queue   chan []byte
+}
+
+// Webhook implements the ddlog.OutRecordHandler and CommitObserver interfaces: it batches the output
+// changes for each transaction and, once the transaction has been committed successfully, POSTs
+// them as a JSON WebhookBatch to each configured URL. Delivery is asynchronous and never blocks
+// the DDlog commit path.
+type Webhook struct {
+	options   WebhookOptions
+	client    *http.Client
+	endpoints []*webhookEndpoint
+
+	changesMutex sync.Mutex
+	changes      []WebhookChange
+}
+
+// NewWebhook creates a new Webhook. Batches are only delivered once Run has been called.
+func NewWebhook(options WebhookOptions) *Webhook {
+	if options.QueueSize <= 0 {
+		options.QueueSize = defaultWebhookQueueSize
+	}
+	if options.MaxRetries == 0 {
+		options.MaxRetries = defaultWebhookMaxRetries
+	} else if options.MaxRetries < 0 {
+		options.MaxRetries = 0
+	}
+	if options.InitialBackoff <= 0 {
+		options.InitialBackoff = defaultWebhookInitialBackoff
+	}
+	if options.MaxBackoff <= 0 {
+		options.MaxBackoff = defaultWebhookMaxBackoff
+	}
+	if options.Timeout <= 0 {
+		options.Timeout = defaultWebhookTimeout
+	}
+	w := &Webhook{
+		options: options,
+		client:  &http.Client{Timeout: options.Timeout},
+	}
+	for _, url := range options.URLs {
+		w.endpoints = append(w.endpoints, &webhookEndpoint{
+			url:   url,
+			queue: make(chan []byte, options.QueueSize),
+		})
+	}
+	return w
+}
+
+// Handle decodes the record received from DDlog and adds it to the current batch.
+func (w *Webhook) Handle(tableID ddlog.TableID, r ddlog.Record, outPolarity ddlog.OutPolarity) {
+	change := WebhookChange{
+		Relation: ddlog.GetTableName(tableID),
+		Polarity: JSONLPolarityInsert,
+	}
+	if outPolarity == ddlog.OutPolarityDelete {
+		change.Polarity = JSONLPolarityDelete
+	}
+	if value, err := ddlogk8s.RecordToOutput(tableID, r); err == nil {
+		change.Value = value
+	} else {
+		change.Raw = r.Dump()
+	}
+	w.addChange(change)
+}
+
+func (w *Webhook) addChange(change WebhookChange) {
+	w.changesMutex.Lock()
+	defer w.changesMutex.Unlock()
+	w.changes = append(w.changes, change)
+}
+
+// CommitStarted resets the batch for the new transaction.
+func (w *Webhook) CommitStarted(txnID uint64) {
+	w.changesMutex.Lock()
+	defer w.changesMutex.Unlock()
+	w.changes = nil
+}
+
+// CommitEnded queues the batch for delivery to each URL, unless the commit failed or the
+// transaction did not produce any change.
+func (w *Webhook) CommitEnded(txnID uint64, err error) {
+	w.changesMutex.Lock()
+	changes := w.changes
+	w.changes = nil
+	w.changesMutex.Unlock()
+
+	if err != nil || len(changes) == 0 {
+		return
+	}
+	body, err := json.Marshal(&WebhookBatch{Seq: txnID, Changes: changes})
+	if err != nil {
+		klog.Errorf("Error when encoding webhook batch for transaction %d: %v", txnID, err)
+		return
+	}
+	for _, e := range w.endpoints {
+		atomic.AddInt64(&e.pending, 1)
+		select {
+		case e.queue <- body:
+		default:
+			atomic.AddInt64(&e.pending, -1)
+			atomic.AddUint64(&e.dropped, 1)
+			klog.Warningf("Webhook queue for '%s' is full, dropping batch for transaction %d", e.url, txnID)
+		}
+	}
+}
+
+// Metrics returns the delivery metrics for each URL.
+func (w *Webhook) Metrics() []WebhookMetrics {
+	metrics := make([]WebhookMetrics, len(w.endpoints))
+	for i, e := range w.endpoints {
+		metrics[i] = WebhookMetrics{
+			URL:         e.url,
+			Delivered:   atomic.LoadUint64(&e.delivered),
+			Failed:      atomic.LoadUint64(&e.failed),
+			Dropped:     atomic.LoadUint64(&e.dropped),
+			Retries:     atomic.LoadUint64(&e.retries),
+			QueueLength: len(e.queue),
+		}
+	}
+	return metrics
+}
+
+// ServeHTTP returns the delivery metrics for each URL as JSON, for GET requests.
+func (w *Webhook) ServeHTTP(rw http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		rw.Header().Set("Allow", http.MethodGet)
+		http.Error(rw, "only GET is supported", http.StatusMethodNotAllowed)
+		return
+	}
+	rw.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(rw).Encode(w.Metrics()); err != nil {
+		klog.Errorf("Error when writing webhook metrics: %v", err)
+	}
+}
+
+func (w *Webhook) post(url string, body []byte) error {
+	resp, err := w.client.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	// Drain the body so that the connection can be reused.
+	io.Copy(ioutil.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// deliver POSTs the batch, retrying with exponential backoff. It returns false if stopCh was
+// closed before the batch could be delivered.
+func (w *Webhook) deliver(e *webhookEndpoint, body []byte, stopCh <-chan struct{}) bool {
+	backoff := w.options.InitialBackoff
+	for attempt := 0; ; attempt++ {
+		err := w.post(e.url, body)
+		if err == nil {
+			atomic.AddUint64(&e.delivered, 1)
+			return true
+		}
+		if attempt >= w.options.MaxRetries {
+			atomic.AddUint64(&e.failed, 1)
+			klog.Errorf("Giving up on delivering webhook batch to '%s' after %d attempts: %v", e.url, attempt+1, err)
+			return true
+		}
+		klog.V(2).Infof("Error when delivering webhook batch to '%s', retrying in %v: %v", e.url, backoff, err)
+		select {
+		case <-time.After(backoff):
+		case <-stopCh:
+			return false
+		}
+		atomic.AddUint64(&e.retries, 1)
+		backoff *= 2
+		if backoff > w.options.MaxBackoff {
+			backoff = w.options.MaxBackoff
+		}
+	}
+}
+
+func (w *Webhook) runEndpoint(e *webhookEndpoint, stopCh <-chan struct{}) {
+	for {
+		select {
+		case body := <-e.queue:
+			delivered := w.deliver(e, body, stopCh)
+			atomic.AddInt64(&e.pending, -1)
+			if !delivered {
+				return
+			}
+		case <-stopCh:
+			return
+		}
+	}
+}
+
+// Flush waits until all the queued batches have been delivered (or have failed after MaxRetries
+// retries), for at most timeout. It returns false if some batches were still pending when the
+// timeout expired. Run must be running for batches to be delivered.
+func (w *Webhook) Flush(timeout time.Duration) bool {
+	deadline := time.Now().Add(timeout)
+	for {
+		pending := int64(0)
+		for _, e := range w.endpoints {
+			pending += atomic.LoadInt64(&e.pending)
+		}
+		if pending == 0 {
+			return true
+		}
+		if time.Now().After(deadline) {
+			klog.Warningf("Timed out when flushing webhooks, %d batch(es) not delivered", pending)
+			return false
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// Run delivers the queued batches until stopCh is closed. Batches which are still queued when
+// stopCh is closed are not delivered.
+func (w *Webhook) Run(stopCh <-chan struct{}) {
+	var wg sync.WaitGroup
+	for _, e := range w.endpoints {
+		wg.Add(1)
+		go func(e *webhookEndpoint) {
+			defer wg.Done()
+			w.runEndpoint(e, stopCh)
+		}(e)
+	}
+	wg.Wait()
+	for _, m := range w.Metrics() {
+		klog.Infof("Webhook metrics for '%s': %d delivered, %d failed, %d dropped, %d retries", m.URL, m.Delivered, m.Failed, m.Dropped, m.Retries)
+	}
+}
@@ -0,0 +1,219 @@
+// Copyright 2020 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package outhandler
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// batchRecorder is an HTTP handler which records the batches it receives. The first N requests,
+// where N is the value of failures, fail with a 503 status code.
+type batchRecorder struct {
+	mutex    sync.Mutex
+	batches  []WebhookBatch
+	failures int32
+	requests int32
+}
+
+func (r *batchRecorder) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if atomic.AddInt32(&r.requests, 1) <= atomic.LoadInt32(&r.failures) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	var batch WebhookBatch
+	if err := json.NewDecoder(req.Body).Decode(&batch); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.batches = append(r.batches, batch)
+}
+
+func (r *batchRecorder) getBatches() []WebhookBatch {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return append([]WebhookBatch(nil), r.batches...)
+}
+
+func commitTestTransaction(w *Webhook, txnID uint64, numChanges int, err error) {
+	w.CommitStarted(txnID)
+	for i := 0; i < numChanges; i++ {
+		w.addChange(WebhookChange{
+			Relation: "AddressGroupAddress",
+			Polarity: JSONLPolarityInsert,
+			Value:    map[string]string{"addressGroup": "group1", "address": fmt.Sprintf("10.0.0.%d", i)},
+		})
+	}
+	w.CommitEnded(txnID, err)
+}
+
+func waitForMetrics(t *testing.T, w *Webhook, condition func(m []WebhookMetrics) bool) {
+	err := wait.PollImmediate(10*time.Millisecond, 5*time.Second, func() (bool, error) {
+		return condition(w.Metrics()), nil
+	})
+	require.NoError(t, err, "Timeout when waiting for webhook metrics, last: %+v", w.Metrics())
+}
+
+func TestWebhookDelivery(t *testing.T) {
+	recorder1, recorder2 := &batchRecorder{}, &batchRecorder{}
+	server1, server2 := httptest.NewServer(recorder1), httptest.NewServer(recorder2)
+	defer server1.Close()
+	defer server2.Close()
+
+	w := NewWebhook(WebhookOptions{URLs: []string{server1.URL, server2.URL}})
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	go w.Run(stopCh)
+
+	commitTestTransaction(w, 1, 2, nil)
+	// Failed commits and empty transactions are not delivered.
+	commitTestTransaction(w, 2, 1, fmt.Errorf("failed"))
+	commitTestTransaction(w, 3, 0, nil)
+	commitTestTransaction(w, 4, 1, nil)
+
+	waitForMetrics(t, w, func(m []WebhookMetrics) bool {
+		return m[0].Delivered == 2 && m[1].Delivered == 2
+	})
+	for _, recorder := range []*batchRecorder{recorder1, recorder2} {
+		batches := recorder.getBatches()
+		require.Len(t, batches, 2)
+		assert.Equal(t, uint64(1), batches[0].Seq)
+		assert.Len(t, batches[0].Changes, 2)
+		assert.Equal(t, "AddressGroupAddress", batches[0].Changes[0].Relation)
+		assert.Equal(t, JSONLPolarityInsert, batches[0].Changes[0].Polarity)
+		assert.Equal(t, uint64(4), batches[1].Seq)
+	}
+}
+
+func TestWebhookRetry(t *testing.T) {
+	recorder := &batchRecorder{failures: 2}
+	server := httptest.NewServer(recorder)
+	defer server.Close()
+
+	w := NewWebhook(WebhookOptions{
+		URLs:           []string{server.URL},
+		MaxRetries:     3,
+		InitialBackoff: time.Millisecond,
+	})
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	go w.Run(stopCh)
+
+	commitTestTransaction(w, 1, 1, nil)
+	waitForMetrics(t, w, func(m []WebhookMetrics) bool { return m[0].Delivered == 1 })
+	m := w.Metrics()[0]
+	assert.Equal(t, uint64(2), m.Retries)
+	assert.Equal(t, uint64(0), m.Failed)
+	assert.Len(t, recorder.getBatches(), 1)
+}
+
+func TestWebhookGiveUp(t *testing.T) {
+	recorder := &batchRecorder{failures: 100}
+	server := httptest.NewServer(recorder)
+	defer server.Close()
+
+	w := NewWebhook(WebhookOptions{
+		URLs:           []string{server.URL},
+		MaxRetries:     2,
+		InitialBackoff: time.Millisecond,
+	})
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	go w.Run(stopCh)
+
+	commitTestTransaction(w, 1, 1, nil)
+	waitForMetrics(t, w, func(m []WebhookMetrics) bool { return m[0].Failed == 1 })
+	assert.Equal(t, uint64(2), w.Metrics()[0].Retries)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&recorder.requests))
+}
+
+func TestWebhookQueueFull(t *testing.T) {
+	recorder := &batchRecorder{}
+	server := httptest.NewServer(recorder)
+	defer server.Close()
+
+	// Batches are only dequeued once Run is called.
+	w := NewWebhook(WebhookOptions{URLs: []string{server.URL}, QueueSize: 2})
+	for i := uint64(1); i <= 3; i++ {
+		commitTestTransaction(w, i, 1, nil)
+	}
+	m := w.Metrics()[0]
+	assert.Equal(t, uint64(1), m.Dropped)
+	assert.Equal(t, 2, m.QueueLength)
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	go w.Run(stopCh)
+	waitForMetrics(t, w, func(m []WebhookMetrics) bool { return m[0].Delivered == 2 })
+	batches := recorder.getBatches()
+	require.Len(t, batches, 2)
+	assert.Equal(t, uint64(1), batches[0].Seq)
+	assert.Equal(t, uint64(2), batches[1].Seq)
+}
+
+func TestWebhookServeHTTP(t *testing.T) {
+	// Batches are only dequeued once Run is called.
+	w := NewWebhook(WebhookOptions{URLs: []string{"http://127.0.0.1:1"}, QueueSize: 1})
+	for i := uint64(1); i <= 2; i++ {
+		commitTestTransaction(w, i, 1, nil)
+	}
+
+	rr := httptest.NewRecorder()
+	w.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/webhooks", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+	var metrics []WebhookMetrics
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &metrics))
+	assert.Equal(t, []WebhookMetrics{{URL: "http://127.0.0.1:1", Dropped: 1, QueueLength: 1}}, metrics)
+
+	rr = httptest.NewRecorder()
+	w.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/webhooks", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rr.Code)
+}
+
+func TestWebhookFlush(t *testing.T) {
+	recorder := &batchRecorder{failures: 1}
+	server := httptest.NewServer(recorder)
+	defer server.Close()
+
+	w := NewWebhook(WebhookOptions{
+		URLs:           []string{server.URL},
+		InitialBackoff: time.Millisecond,
+	})
+	// Without Run, nothing is delivered and Flush times out.
+	commitTestTransaction(w, 1, 1, nil)
+	assert.False(t, w.Flush(50*time.Millisecond))
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	go w.Run(stopCh)
+	commitTestTransaction(w, 2, 1, nil)
+	require.True(t, w.Flush(5*time.Second))
+	m := w.Metrics()[0]
+	assert.Equal(t, uint64(2), m.Delivered)
+	assert.Equal(t, uint64(1), m.Retries)
+	assert.Len(t, recorder.getBatches(), 2)
+}
@@ -0,0 +1,98 @@
+// Copyright 2020 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package profiling serves the DDlog profiling endpoints, next to the Go net/http/pprof endpoints,
+// to help determine whether time is spent in Go (e.g. building DDlog records with cgo) or in DDlog
+// evaluation.
+package profiling
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/pprof"
+	"strconv"
+
+	"k8s.io/klog"
+)
+
+const (
+	// PathPrefix is the path prefix under which all the profiling endpoints are served.
+	PathPrefix = "/debug/"
+
+	cpuProfilingPath = "/debug/ddlog/cpu-profiling"
+	profilePath      = "/debug/ddlog/profile"
+	pprofPath        = "/debug/pprof/"
+	enableParam      = "enable"
+)
+
+// Profiler is implemented by program.Program.
+type Profiler interface {
+	EnableCPUProfiling(enable bool) error
+	Profile() (string, error)
+}
+
+// NewHandler returns a handler serving:
+//   - POST /debug/ddlog/cpu-profiling?enable=<bool>, to enable or disable DDlog CPU profiling;
+//   - GET /debug/ddlog/profile, which returns the DDlog profile as text;
+//   - the net/http/pprof endpoints under /debug/pprof/.
+func NewHandler(profiler Profiler) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(cpuProfilingPath, func(w http.ResponseWriter, r *http.Request) {
+		handleCPUProfiling(profiler, w, r)
+	})
+	mux.HandleFunc(profilePath, func(w http.ResponseWriter, r *http.Request) {
+		handleProfile(profiler, w, r)
+	})
+	mux.HandleFunc(pprofPath, pprof.Index)
+	mux.HandleFunc(pprofPath+"cmdline", pprof.Cmdline)
+	mux.HandleFunc(pprofPath+"profile", pprof.Profile)
+	mux.HandleFunc(pprofPath+"symbol", pprof.Symbol)
+	mux.HandleFunc(pprofPath+"trace", pprof.Trace)
+	return mux
+}
+
+func handleCPUProfiling(profiler Profiler, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "only POST is supported", http.StatusMethodNotAllowed)
+		return
+	}
+	enable, err := strconv.ParseBool(r.URL.Query().Get(enableParam))
+	if err != nil {
+		http.Error(w, fmt.Sprintf("invalid or missing '%s' parameter", enableParam), http.StatusBadRequest)
+		return
+	}
+	if err := profiler.EnableCPUProfiling(enable); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	klog.Infof("DDlog CPU profiling enabled: %t", enable)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func handleProfile(profiler Profiler, w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		w.Header().Set("Allow", http.MethodGet)
+		http.Error(w, "only GET is supported", http.StatusMethodNotAllowed)
+		return
+	}
+	profile, err := profiler.Profile()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	io.WriteString(w, profile)
+}
@@ -0,0 +1,64 @@
+// Copyright 2020 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package profiling
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeProfiler struct {
+	cpuProfiling bool
+}
+
+func (p *fakeProfiler) EnableCPUProfiling(enable bool) error {
+	p.cpuProfiling = enable
+	return nil
+}
+
+func (p *fakeProfiler) Profile() (string, error) {
+	if p.cpuProfiling {
+		return "CPU profile", nil
+	}
+	return "arrangements", nil
+}
+
+func TestHandler(t *testing.T) {
+	profiler := &fakeProfiler{}
+	handler := NewHandler(profiler)
+	do := func(method, url string) *httptest.ResponseRecorder {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(method, url, nil))
+		return rr
+	}
+
+	rr := do(http.MethodGet, "/debug/ddlog/profile")
+	assert.Equal(t, http.StatusOK, rr.Code)
+	assert.Equal(t, "arrangements", rr.Body.String())
+
+	assert.Equal(t, http.StatusMethodNotAllowed, do(http.MethodGet, "/debug/ddlog/cpu-profiling?enable=true").Code)
+	assert.Equal(t, http.StatusBadRequest, do(http.MethodPost, "/debug/ddlog/cpu-profiling").Code)
+	assert.Equal(t, http.StatusNoContent, do(http.MethodPost, "/debug/ddlog/cpu-profiling?enable=true").Code)
+	assert.True(t, profiler.cpuProfiling)
+
+	rr = do(http.MethodGet, "/debug/ddlog/profile")
+	assert.Equal(t, "CPU profile", rr.Body.String())
+
+	assert.Equal(t, http.StatusOK, do(http.MethodGet, "/debug/pprof/").Code)
+	assert.Equal(t, http.StatusOK, do(http.MethodGet, "/debug/pprof/cmdline").Code)
+}
@@ -0,0 +1,83 @@
+// Copyright 2020 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package program
+
+/*
+#include "ddlog.h"
+*/
+import "C"
+
+import (
+	"fmt"
+	"reflect"
+	"unsafe"
+)
+
+// prog returns the handle of the DDlog program. The ddlog package does not expose it, nor the
+// profiling functions of the DDlog C API, so we read it from the unexported field. Because the
+// field is not part of the ddlog API, we check that it still exists and still has the expected type
+// before reading it, so that a change in the ddlog package results in an error rather than in an
+// invalid handle being passed to the C library.
+func (p *Program) prog() (C.ddlog_prog, error) {
+	if p.Program == nil {
+		return nil, fmt.Errorf("DDlog program is not running")
+	}
+	field := reflect.ValueOf(p.Program).Elem().FieldByName("ptr")
+	if !field.IsValid() {
+		return nil, fmt.Errorf("ddlog.Program has no 'ptr' field")
+	}
+	expectedType := reflect.TypeOf(C.ddlog_prog(nil))
+	if field.Kind() != expectedType.Kind() || field.Type().Name() != expectedType.Name() ||
+		field.Type().Size() != expectedType.Size() {
+		return nil, fmt.Errorf("ddlog.Program 'ptr' field has type %v, expected %v", field.Type(), expectedType)
+	}
+	if !field.CanAddr() {
+		return nil, fmt.Errorf("ddlog.Program 'ptr' field is not addressable")
+	}
+	prog := *(*C.ddlog_prog)(unsafe.Pointer(field.UnsafeAddr()))
+	if prog == nil {
+		return nil, fmt.Errorf("DDlog program handle is NULL")
+	}
+	return prog, nil
+}
+
+// EnableCPUProfiling enables or disables the recording of the CPU usage of each DDlog operator.
+// When disabled, the profile recorded so far is preserved.
+func (p *Program) EnableCPUProfiling(enable bool) error {
+	prog, err := p.prog()
+	if err != nil {
+		return err
+	}
+	rc := C.ddlog_enable_cpu_profiling(prog, C.bool(enable))
+	if rc != 0 {
+		return fmt.Errorf("ddlog_enable_cpu_profiling returned error code %d", rc)
+	}
+	return nil
+}
+
+// Profile returns the DDlog runtime profile, which includes the size of the arrangements and, if
+// CPU profiling is enabled, the CPU usage of each operator.
+func (p *Program) Profile() (string, error) {
+	prog, err := p.prog()
+	if err != nil {
+		return "", err
+	}
+	cProfile := C.ddlog_profile(prog)
+	if cProfile == nil {
+		return "", fmt.Errorf("ddlog_profile returned NULL")
+	}
+	defer C.ddlog_string_free(cProfile)
+	return C.GoString(cProfile), nil
+}
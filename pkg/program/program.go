@@ -0,0 +1,95 @@
+// Copyright 2020 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package program wraps ddlog.Program to keep track of committed transactions.
+package program
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/antoninbas/antrea-k8s-to-ddlog/pkg/outhandler"
+	"github.com/vmware/differential-datalog/go/pkg/ddlog"
+)
+
+// Program embeds a *ddlog.Program and assigns an increasing ID to each transaction commit. If the
+// output handler implements outhandler.CommitObserver, it is notified before and after each commit,
+// which lets it group the output changes by transaction.
+type Program struct {
+	*ddlog.Program
+	observer  outhandler.CommitObserver
+	lastTxnID uint64
+	// commitMutex serializes commits and input snapshots, so that a snapshot never observes a
+	// partially committed transaction.
+	commitMutex sync.Mutex
+}
+
+// NewProgram creates a new instance of the DDlog program. See ddlog.NewProgram for the meaning of
+// the parameters.
+func NewProgram(workers uint, outRecordHandler ddlog.OutRecordHandler) (*Program, error) {
+	ddlogProgram, err := ddlog.NewProgram(workers, outRecordHandler)
+	if err != nil {
+		return nil, err
+	}
+	observer, _ := outRecordHandler.(outhandler.CommitObserver)
+	return &Program{
+		Program:  ddlogProgram,
+		observer: observer,
+	}, nil
+}
+
+// CommitTransaction commits the current transaction and notifies the CommitObserver, if any.
+func (p *Program) CommitTransaction() error {
+	p.commitMutex.Lock()
+	defer p.commitMutex.Unlock()
+	txnID := atomic.AddUint64(&p.lastTxnID, 1)
+	if p.observer != nil {
+		p.observer.CommitStarted(txnID)
+	}
+	err := p.Program.CommitTransaction()
+	if p.observer != nil {
+		p.observer.CommitEnded(txnID, err)
+	}
+	return err
+}
+
+// DumpInputSnapshot writes the committed contents of all the input relations to the provided file,
+// as a sequence of insert commands. It waits for any ongoing commit to complete.
+func (p *Program) DumpInputSnapshot(name string) error {
+	p.commitMutex.Lock()
+	defer p.commitMutex.Unlock()
+	return p.Program.DumpInputSnapshot(name)
+}
+
+// ApplyUpdatesAsTransaction starts a transaction, applies updates to DDlog tables and commits the
+// transaction. The transaction is rolled back if the updates cannot be applied.
+func (p *Program) ApplyUpdatesAsTransaction(commands ...ddlog.Command) error {
+	if err := p.StartTransaction(); err != nil {
+		return err
+	}
+	if err := p.ApplyUpdates(commands...); err != nil {
+		if rbErr := p.RollbackTransaction(); rbErr != nil {
+			return fmt.Errorf("%v (and error when rolling back transaction: %v)", err, rbErr)
+		}
+		return err
+	}
+	return p.CommitTransaction()
+}
+
+// LastTransactionID returns the ID of the last transaction for which a commit was attempted, or 0
+// if no transaction was ever committed.
+func (p *Program) LastTransactionID() uint64 {
+	return atomic.LoadUint64(&p.lastTxnID)
+}
@@ -0,0 +1,369 @@
+// Copyright 2020 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package reference is a pure-Go implementation of the computation performed by Antrea's
+// NetworkPolicy controller. It is used as an oracle to validate the output of the DDlog program.
+//
+// Since the group names chosen by DDlog are an implementation detail, Compute resolves each internal
+// NetworkPolicy: the AppliedToGroups are replaced by the Pods they select, and the AddressGroups
+// referenced by a rule are replaced by the union of their addresses. ComputeGroups computes the
+// groups themselves, with their members and their span, identified by a key derived from their
+// selector instead of a name.
+package reference
+
+import (
+	"fmt"
+	"sort"
+
+	"k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/klog"
+)
+
+// MatchAllCIDR is the IPBlock used for rules with no peer, which match all traffic, like in Antrea.
+const MatchAllCIDR = "0.0.0.0/0"
+
+// Direction is the direction of a rule.
+type Direction string
+
+const (
+	DirectionIn  Direction = "In"
+	DirectionOut Direction = "Out"
+)
+
+// PodReference identifies a Pod by name and Namespace.
+type PodReference struct {
+	Name      string `json:"name"`
+	Namespace string `json:"namespace"`
+}
+
+// Rule is an internal NetworkPolicy rule, with its peer resolved. For ingress rules the peer is
+// the source of the traffic, for egress rules the destination.
+type Rule struct {
+	Direction Direction                        `json:"direction"`
+	Addresses []string                         `json:"addresses,omitempty"`
+	IPBlocks  []networkingv1.IPBlock           `json:"ipBlocks,omitempty"`
+	Services  []networkingv1.NetworkPolicyPort `json:"services,omitempty"`
+}
+
+// NetworkPolicy is an internal NetworkPolicy, with its AppliedToGroups and AddressGroups resolved.
+type NetworkPolicy struct {
+	UID       types.UID `json:"uid"`
+	Name      string    `json:"name"`
+	Namespace string    `json:"namespace"`
+	// PodsByNode is the set of Pods the policy applies to, indexed by Node name.
+	PodsByNode  map[string][]PodReference `json:"podsByNode"`
+	Span        []string                  `json:"span"`
+	Rules       []Rule                    `json:"rules"`
+	PolicyTypes []networkingv1.PolicyType `json:"policyTypes"`
+}
+
+// Inputs is the set of K8s objects from which NetworkPolicies are computed.
+type Inputs struct {
+	Pods            []*v1.Pod
+	Namespaces      []*v1.Namespace
+	NetworkPolicies []*networkingv1.NetworkPolicy
+}
+
+// Compute returns the internal NetworkPolicies for the provided inputs, indexed by UID. Like in
+// Antrea, Pods which have not been scheduled yet do not belong to any AppliedToGroup and Pods which
+// do not have an IP yet do not belong to any AddressGroup.
+func Compute(inputs *Inputs) map[types.UID]*NetworkPolicy {
+	c := newComputation(inputs)
+	policies := make(map[types.UID]*NetworkPolicy, len(inputs.NetworkPolicies))
+	for _, np := range inputs.NetworkPolicies {
+		policies[np.UID] = c.computeNetworkPolicy(np)
+	}
+	return policies
+}
+
+type computation struct {
+	podsByNamespace map[string][]*v1.Pod
+	namespaces      []*v1.Namespace
+}
+
+func newComputation(inputs *Inputs) *computation {
+	c := &computation{
+		podsByNamespace: make(map[string][]*v1.Pod),
+		namespaces:      inputs.Namespaces,
+	}
+	for _, pod := range inputs.Pods {
+		c.podsByNamespace[pod.Namespace] = append(c.podsByNamespace[pod.Namespace], pod)
+	}
+	return c
+}
+
+func toSelector(labelSelector *metav1.LabelSelector) labels.Selector {
+	selector, err := metav1.LabelSelectorAsSelector(labelSelector)
+	if err != nil {
+		klog.Errorf("Invalid label selector %v: %v", labelSelector, err)
+		return labels.Nothing()
+	}
+	return selector
+}
+
+// selectPods returns the Pods in the provided Namespace matching podSelector. A nil podSelector
+// matches all Pods.
+func (c *computation) selectPods(namespace string, podSelector *metav1.LabelSelector) []*v1.Pod {
+	selector := labels.Everything()
+	if podSelector != nil {
+		selector = toSelector(podSelector)
+	}
+	var pods []*v1.Pod
+	for _, pod := range c.podsByNamespace[namespace] {
+		if selector.Matches(labels.Set(pod.Labels)) {
+			pods = append(pods, pod)
+		}
+	}
+	return pods
+}
+
+func (c *computation) selectNamespaces(namespaceSelector *metav1.LabelSelector) []string {
+	selector := toSelector(namespaceSelector)
+	var namespaces []string
+	for _, ns := range c.namespaces {
+		if selector.Matches(labels.Set(ns.Labels)) {
+			namespaces = append(namespaces, ns.Name)
+		}
+	}
+	return namespaces
+}
+
+// selectPeerPods returns the Pods selected by a NetworkPolicyPeer with a podSelector and / or a
+// namespaceSelector, following the K8s semantics.
+func (c *computation) selectPeerPods(policyNamespace string, peer *networkingv1.NetworkPolicyPeer) []*v1.Pod {
+	if peer.NamespaceSelector == nil {
+		return c.selectPods(policyNamespace, peer.PodSelector)
+	}
+	var pods []*v1.Pod
+	for _, namespace := range c.selectNamespaces(peer.NamespaceSelector) {
+		pods = append(pods, c.selectPods(namespace, peer.PodSelector)...)
+	}
+	return pods
+}
+
+// Selector selects Pods from a set of inputs, following the K8s NetworkPolicy semantics.
+type Selector struct {
+	c *computation
+}
+
+// NewSelector creates a Selector for the provided inputs.
+func NewSelector(inputs *Inputs) *Selector {
+	return &Selector{c: newComputation(inputs)}
+}
+
+// SelectPods returns the Pods in the provided Namespace matching podSelector. A nil podSelector
+// matches all Pods.
+func (s *Selector) SelectPods(namespace string, podSelector *metav1.LabelSelector) []*v1.Pod {
+	return s.c.selectPods(namespace, podSelector)
+}
+
+// SelectPeerPods returns the Pods selected by a NetworkPolicyPeer with a podSelector and / or a
+// namespaceSelector, for a NetworkPolicy in policyNamespace.
+func (s *Selector) SelectPeerPods(policyNamespace string, peer *networkingv1.NetworkPolicyPeer) []*v1.Pod {
+	return s.c.selectPeerPods(policyNamespace, peer)
+}
+
+func (c *computation) computeRule(policyNamespace string, direction Direction, peers []networkingv1.NetworkPolicyPeer, ports []networkingv1.NetworkPolicyPort) Rule {
+	rule := Rule{
+		Direction: direction,
+		Services:  ports,
+	}
+	if len(peers) == 0 {
+		rule.IPBlocks = []networkingv1.IPBlock{{CIDR: MatchAllCIDR}}
+		return rule
+	}
+	addresses := sets.NewString()
+	for i := range peers {
+		peer := &peers[i]
+		if peer.IPBlock != nil {
+			rule.IPBlocks = append(rule.IPBlocks, *peer.IPBlock)
+			continue
+		}
+		for _, pod := range c.selectPeerPods(policyNamespace, peer) {
+			if pod.Status.PodIP != "" {
+				addresses.Insert(pod.Status.PodIP)
+			}
+		}
+	}
+	if addresses.Len() > 0 {
+		rule.Addresses = addresses.List()
+	}
+	return rule
+}
+
+// computePodsByNode returns the scheduled Pods selected by the podSelector of a NetworkPolicy,
+// indexed by Node name, and the sorted list of these Nodes.
+func (c *computation) computePodsByNode(np *networkingv1.NetworkPolicy) (map[string][]PodReference, []string) {
+	podsByNode := make(map[string][]PodReference)
+	for _, pod := range c.selectPods(np.Namespace, &np.Spec.PodSelector) {
+		if pod.Spec.NodeName == "" {
+			continue
+		}
+		podsByNode[pod.Spec.NodeName] = append(podsByNode[pod.Spec.NodeName], PodReference{Name: pod.Name, Namespace: pod.Namespace})
+	}
+	span := make([]string, 0, len(podsByNode))
+	for nodeName, pods := range podsByNode {
+		SortPodReferences(pods)
+		span = append(span, nodeName)
+	}
+	sort.Strings(span)
+	return podsByNode, span
+}
+
+func (c *computation) computeNetworkPolicy(np *networkingv1.NetworkPolicy) *NetworkPolicy {
+	podsByNode, span := c.computePodsByNode(np)
+	rules := make([]Rule, 0, len(np.Spec.Ingress)+len(np.Spec.Egress))
+	for _, ingress := range np.Spec.Ingress {
+		rules = append(rules, c.computeRule(np.Namespace, DirectionIn, ingress.From, ingress.Ports))
+	}
+	for _, egress := range np.Spec.Egress {
+		rules = append(rules, c.computeRule(np.Namespace, DirectionOut, egress.To, egress.Ports))
+	}
+
+	return &NetworkPolicy{
+		UID:         np.UID,
+		Name:        np.Name,
+		Namespace:   np.Namespace,
+		PodsByNode:  podsByNode,
+		Span:        span,
+		Rules:       rules,
+		PolicyTypes: np.Spec.PolicyTypes,
+	}
+}
+
+// SortPodReferences sorts Pod references by Namespace, then by name.
+func SortPodReferences(pods []PodReference) {
+	sort.Slice(pods, func(i, j int) bool {
+		if pods[i].Namespace != pods[j].Namespace {
+			return pods[i].Namespace < pods[j].Namespace
+		}
+		return pods[i].Name < pods[j].Name
+	})
+}
+
+// AppliedToGroup is an AppliedToGroup: the Pods selected by the podSelector of a NetworkPolicy, in
+// the NetworkPolicy's Namespace.
+type AppliedToGroup struct {
+	// PodsByNode is the set of Pods in the group, indexed by Node name.
+	PodsByNode map[string][]PodReference `json:"podsByNode"`
+	// Span is the sorted list of Nodes on which the Pods in the group run.
+	Span []string `json:"span"`
+}
+
+// AddressGroup is an AddressGroup: the Pods selected by a NetworkPolicyPeer with a podSelector and /
+// or a namespaceSelector.
+type AddressGroup struct {
+	Addresses []string `json:"addresses"`
+	// Span is the sorted union of the spans of the NetworkPolicies which use the group.
+	Span []string `json:"span"`
+}
+
+// PolicyGroups are the keys of the groups used by a NetworkPolicy.
+type PolicyGroups struct {
+	AppliedToGroup string
+	// AddressGroups are the keys of the AddressGroups used by each rule, in the same order as
+	// NetworkPolicy.Rules. There is one group for each peer of the rule which is not an IPBlock.
+	AddressGroups [][]string
+}
+
+// Groups are the AppliedToGroups and AddressGroups for a set of inputs, indexed by key. Like in
+// Antrea, NetworkPolicies with the same selectors share the same groups.
+type Groups struct {
+	AppliedToGroups map[string]*AppliedToGroup
+	AddressGroups   map[string]*AddressGroup
+	// Policies are the groups used by each NetworkPolicy, indexed by UID.
+	Policies map[types.UID]*PolicyGroups
+}
+
+// selectorKey returns a canonical representation of a label selector. A nil selector matches
+// everything.
+func selectorKey(labelSelector *metav1.LabelSelector) string {
+	if labelSelector == nil {
+		return labels.Everything().String()
+	}
+	return toSelector(labelSelector).String()
+}
+
+func appliedToGroupKey(np *networkingv1.NetworkPolicy) string {
+	return fmt.Sprintf("namespace=%s,pods=[%s]", np.Namespace, selectorKey(&np.Spec.PodSelector))
+}
+
+func addressGroupKey(policyNamespace string, peer *networkingv1.NetworkPolicyPeer) string {
+	if peer.NamespaceSelector == nil {
+		return fmt.Sprintf("namespace=%s,pods=[%s]", policyNamespace, selectorKey(peer.PodSelector))
+	}
+	return fmt.Sprintf("namespaces=[%s],pods=[%s]", selectorKey(peer.NamespaceSelector), selectorKey(peer.PodSelector))
+}
+
+// ComputeGroups returns the AppliedToGroups and AddressGroups for the provided inputs. The same
+// rules as in Compute apply to Pods which are not scheduled or do not have an IP.
+func ComputeGroups(inputs *Inputs) *Groups {
+	c := newComputation(inputs)
+	groups := &Groups{
+		AppliedToGroups: make(map[string]*AppliedToGroup),
+		AddressGroups:   make(map[string]*AddressGroup),
+		Policies:        make(map[types.UID]*PolicyGroups, len(inputs.NetworkPolicies)),
+	}
+	addressGroupSpans := make(map[string]sets.String)
+	addAddressGroups := func(np *networkingv1.NetworkPolicy, span []string, peers []networkingv1.NetworkPolicyPeer) []string {
+		keys := sets.NewString()
+		for i := range peers {
+			peer := &peers[i]
+			if peer.IPBlock != nil {
+				continue
+			}
+			key := addressGroupKey(np.Namespace, peer)
+			keys.Insert(key)
+			if _, ok := groups.AddressGroups[key]; !ok {
+				addresses := sets.NewString()
+				for _, pod := range c.selectPeerPods(np.Namespace, peer) {
+					if pod.Status.PodIP != "" {
+						addresses.Insert(pod.Status.PodIP)
+					}
+				}
+				groups.AddressGroups[key] = &AddressGroup{Addresses: addresses.List()}
+				addressGroupSpans[key] = sets.NewString()
+			}
+			addressGroupSpans[key].Insert(span...)
+		}
+		return keys.List()
+	}
+
+	for _, np := range inputs.NetworkPolicies {
+		key := appliedToGroupKey(np)
+		group, ok := groups.AppliedToGroups[key]
+		if !ok {
+			podsByNode, span := c.computePodsByNode(np)
+			group = &AppliedToGroup{PodsByNode: podsByNode, Span: span}
+			groups.AppliedToGroups[key] = group
+		}
+		policyGroups := &PolicyGroups{AppliedToGroup: key}
+		for _, ingress := range np.Spec.Ingress {
+			policyGroups.AddressGroups = append(policyGroups.AddressGroups, addAddressGroups(np, group.Span, ingress.From))
+		}
+		for _, egress := range np.Spec.Egress {
+			policyGroups.AddressGroups = append(policyGroups.AddressGroups, addAddressGroups(np, group.Span, egress.To))
+		}
+		groups.Policies[np.UID] = policyGroups
+	}
+	for key, span := range addressGroupSpans {
+		groups.AddressGroups[key].Span = span.List()
+	}
+	return groups
+}
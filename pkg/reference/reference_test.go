@@ -0,0 +1,176 @@
+// Copyright 2020 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reference
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+func newPod(namespace, name, nodeName, podIP string, labels map[string]string) *v1.Pod {
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name, Labels: labels},
+		Spec:       v1.PodSpec{NodeName: nodeName},
+		Status:     v1.PodStatus{PodIP: podIP},
+	}
+}
+
+func newNamespace(name string, labels map[string]string) *v1.Namespace {
+	return &v1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name, Labels: labels}}
+}
+
+func TestCompute(t *testing.T) {
+	inputs := &Inputs{
+		Pods: []*v1.Pod{
+			newPod("ns1", "web2", "node-1", "10.0.0.2", map[string]string{"app": "web"}),
+			newPod("ns1", "web1", "node-1", "10.0.0.1", map[string]string{"app": "web"}),
+			newPod("ns1", "web3", "node-2", "10.0.1.1", map[string]string{"app": "web"}),
+			// Not scheduled yet.
+			newPod("ns1", "web4", "", "", map[string]string{"app": "web"}),
+			newPod("ns1", "db1", "node-2", "10.0.1.2", map[string]string{"app": "db"}),
+			newPod("ns2", "client1", "node-1", "10.0.0.3", map[string]string{"app": "client"}),
+			newPod("ns3", "client2", "node-2", "10.0.1.3", map[string]string{"app": "client"}),
+			// No IP yet.
+			newPod("ns2", "client3", "node-2", "", map[string]string{"app": "client"}),
+		},
+		Namespaces: []*v1.Namespace{
+			newNamespace("ns1", nil),
+			newNamespace("ns2", map[string]string{"env": "prod"}),
+			newNamespace("ns3", map[string]string{"env": "dev"}),
+		},
+		NetworkPolicies: []*networkingv1.NetworkPolicy{{
+			ObjectMeta: metav1.ObjectMeta{Namespace: "ns1", Name: "np1", UID: "uid1"},
+			Spec: networkingv1.NetworkPolicySpec{
+				PodSelector: metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}},
+				Ingress: []networkingv1.NetworkPolicyIngressRule{
+					{
+						From: []networkingv1.NetworkPolicyPeer{
+							{NamespaceSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"env": "prod"}}},
+							{PodSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "db"}}},
+							{IPBlock: &networkingv1.IPBlock{CIDR: "192.168.0.0/16", Except: []string{"192.168.1.0/24"}}},
+						},
+					},
+					{},
+				},
+				Egress: []networkingv1.NetworkPolicyEgressRule{{
+					To: []networkingv1.NetworkPolicyPeer{{
+						NamespaceSelector: &metav1.LabelSelector{},
+						PodSelector:       &metav1.LabelSelector{MatchLabels: map[string]string{"app": "client"}},
+					}},
+				}},
+				PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress, networkingv1.PolicyTypeEgress},
+			},
+		}},
+	}
+
+	policies := Compute(inputs)
+	require.Len(t, policies, 1)
+	assert.Equal(t, &NetworkPolicy{
+		UID:       "uid1",
+		Name:      "np1",
+		Namespace: "ns1",
+		PodsByNode: map[string][]PodReference{
+			"node-1": {{Name: "web1", Namespace: "ns1"}, {Name: "web2", Namespace: "ns1"}},
+			"node-2": {{Name: "web3", Namespace: "ns1"}},
+		},
+		Span: []string{"node-1", "node-2"},
+		Rules: []Rule{
+			{
+				Direction: DirectionIn,
+				Addresses: []string{"10.0.0.3", "10.0.1.2"},
+				IPBlocks:  []networkingv1.IPBlock{{CIDR: "192.168.0.0/16", Except: []string{"192.168.1.0/24"}}},
+			},
+			{
+				Direction: DirectionIn,
+				IPBlocks:  []networkingv1.IPBlock{{CIDR: MatchAllCIDR}},
+			},
+			{
+				Direction: DirectionOut,
+				Addresses: []string{"10.0.0.3", "10.0.1.3"},
+			},
+		},
+		PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress, networkingv1.PolicyTypeEgress},
+	}, policies["uid1"])
+}
+
+func TestComputeGroups(t *testing.T) {
+	webSelector := metav1.LabelSelector{MatchLabels: map[string]string{"app": "web"}}
+	clientSelector := &metav1.LabelSelector{MatchLabels: map[string]string{"app": "client"}}
+	inputs := &Inputs{
+		Pods: []*v1.Pod{
+			newPod("ns1", "web1", "node-1", "10.0.0.1", map[string]string{"app": "web"}),
+			newPod("ns1", "db1", "node-2", "10.0.1.1", map[string]string{"app": "db"}),
+			newPod("ns1", "client1", "node-3", "10.0.2.1", map[string]string{"app": "client"}),
+		},
+		Namespaces: []*v1.Namespace{newNamespace("ns1", nil)},
+		NetworkPolicies: []*networkingv1.NetworkPolicy{
+			{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "ns1", Name: "np1", UID: "uid1"},
+				Spec: networkingv1.NetworkPolicySpec{
+					PodSelector: webSelector,
+					Ingress: []networkingv1.NetworkPolicyIngressRule{{
+						From: []networkingv1.NetworkPolicyPeer{
+							{PodSelector: clientSelector},
+							{IPBlock: &networkingv1.IPBlock{CIDR: "192.168.0.0/16"}},
+						},
+					}},
+				},
+			},
+			{
+				// Shares its AddressGroup with np1.
+				ObjectMeta: metav1.ObjectMeta{Namespace: "ns1", Name: "np2", UID: "uid2"},
+				Spec: networkingv1.NetworkPolicySpec{
+					PodSelector: metav1.LabelSelector{MatchLabels: map[string]string{"app": "db"}},
+					Ingress: []networkingv1.NetworkPolicyIngressRule{{
+						From: []networkingv1.NetworkPolicyPeer{{PodSelector: clientSelector}},
+					}},
+				},
+			},
+			{
+				// Shares its AppliedToGroup with np1.
+				ObjectMeta: metav1.ObjectMeta{Namespace: "ns1", Name: "np3", UID: "uid3"},
+				Spec:       networkingv1.NetworkPolicySpec{PodSelector: webSelector},
+			},
+		},
+	}
+
+	groups := ComputeGroups(inputs)
+	webKey, dbKey, clientKey := "namespace=ns1,pods=[app=web]", "namespace=ns1,pods=[app=db]", "namespace=ns1,pods=[app=client]"
+	assert.Equal(t, map[string]*AppliedToGroup{
+		webKey: {
+			PodsByNode: map[string][]PodReference{"node-1": {{Name: "web1", Namespace: "ns1"}}},
+			Span:       []string{"node-1"},
+		},
+		dbKey: {
+			PodsByNode: map[string][]PodReference{"node-2": {{Name: "db1", Namespace: "ns1"}}},
+			Span:       []string{"node-2"},
+		},
+	}, groups.AppliedToGroups)
+	// The span of the AddressGroup is the union of the spans of np1 and np2.
+	assert.Equal(t, map[string]*AddressGroup{
+		clientKey: {Addresses: []string{"10.0.2.1"}, Span: []string{"node-1", "node-2"}},
+	}, groups.AddressGroups)
+	assert.Equal(t, map[types.UID]*PolicyGroups{
+		"uid1": {AppliedToGroup: webKey, AddressGroups: [][]string{{clientKey}}},
+		"uid2": {AppliedToGroup: dbKey, AddressGroups: [][]string{{clientKey}}},
+		"uid3": {AppliedToGroup: webKey},
+	}, groups.Policies)
+}
@@ -0,0 +1,561 @@
+// Copyright 2020 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package repl implements an interactive session in which K8s objects are converted to DDlog
+// input records and applied to a DDlog program, without a cluster, and in which the contents of
+// the input and output relations can be inspected. Sessions can also be scripted, with the same
+// syntax.
+package repl
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/antoninbas/antrea-k8s-to-ddlog/pkg/ddlogk8s"
+	"github.com/antoninbas/antrea-k8s-to-ddlog/pkg/ddlogtext"
+	"github.com/antoninbas/antrea-k8s-to-ddlog/pkg/manifest"
+	"github.com/antoninbas/antrea-k8s-to-ddlog/pkg/outhandler"
+	"github.com/antoninbas/antrea-k8s-to-ddlog/pkg/snapshot"
+	"github.com/vmware/differential-datalog/go/pkg/ddlog"
+)
+
+const (
+	prompt             = "> "
+	continuationPrompt = "... "
+	// endOfBlock terminates a pasted YAML block, blank lines terminate it as well.
+	endOfBlock = "."
+)
+
+const helpText = `Commands:
+  <YAML>                            Paste a Namespace, Pod, Deployment or NetworkPolicy manifest,
+                                    starting with "apiVersion:", "kind:" or "---" and terminated
+                                    by an empty line or a line with a single "."
+  apply <file>...                   Apply the objects defined in manifest files
+  delete pod <namespace>/<name>     Delete a Pod by key
+  delete namespace <name>           Delete a Namespace by key
+  delete networkpolicy <ns>/<name>  Delete a NetworkPolicy by key
+  start                             Start a transaction, changes are committed immediately otherwise
+  commit                            Commit the current transaction
+  rollback                          Roll back the current transaction
+  print inputs [relation]           Print the committed contents of the input relations
+  print outputs [relation]          Print the contents of the output relations
+  source <file>                     Run the commands from a file
+  history                           Print the command history
+  !!                                Run the last command again
+  !<n>                              Run command <n> from the history again
+  help                              Print this help
+  quit, exit                        End the session
+`
+
+// Program is the subset of the ddlog.Program methods used by the session. It is implemented by
+// program.Program.
+type Program interface {
+	StartTransaction() error
+	ApplyUpdates(commands ...ddlog.Command) error
+	CommitTransaction() error
+	RollbackTransaction() error
+	DumpInputSnapshot(name string) error
+}
+
+const (
+	namespaceRelation     = "k8spolicy.Namespace"
+	podRelation           = "k8spolicy.Pod"
+	networkPolicyRelation = "k8spolicy.NetworkPolicy"
+)
+
+// inputRelations are the names of the input relations, with the aliases accepted by the "print"
+// and "delete" commands.
+var inputRelations = []struct {
+	name    string
+	aliases []string
+}{
+	{namespaceRelation, []string{"namespace", "namespaces", "ns"}},
+	{podRelation, []string{"pod", "pods", "po"}},
+	{networkPolicyRelation, []string{"networkpolicy", "networkpolicies", "netpol"}},
+}
+
+func inputRelation(name string) (string, bool) {
+	for _, r := range inputRelations {
+		if strings.EqualFold(name, r.name) {
+			return r.name, true
+		}
+		for _, alias := range r.aliases {
+			if strings.EqualFold(name, alias) {
+				return r.name, true
+			}
+		}
+	}
+	return "", false
+}
+
+// errQuit is returned by execute when the session must end.
+var errQuit = fmt.Errorf("quit")
+
+// Session is a REPL session. The contents of the input relations are read from a snapshot dumped
+// by the DDlog program, and the contents of the output relations are collected by the output
+// record handler.
+type Session struct {
+	program Program
+	outputs *outhandler.Collector
+	loader  *manifest.Loader
+	out     io.Writer
+	inTxn   bool
+	// pending is the number of updates applied in the current transaction.
+	pending int
+	// loaderState is the state of the loader when the current transaction was started, which is
+	// restored if the transaction is not committed.
+	loaderState *manifest.LoaderState
+	history     []string
+	historyFile io.Writer
+}
+
+// NewSession creates a new session. outputs must be the output record handler of program. Output
+// and error messages are written to out.
+func NewSession(program Program, outputs *outhandler.Collector, loader *manifest.Loader, out io.Writer) *Session {
+	return &Session{
+		program: program,
+		outputs: outputs,
+		loader:  loader,
+		out:     out,
+	}
+}
+
+// SetHistoryFile loads the command history from a file and appends the commands of the session to
+// it. The file is created if it does not exist. The returned function closes the file.
+func (s *Session) SetHistoryFile(name string) (func() error, error) {
+	f, err := os.OpenFile(name, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("error when opening history file: %v", err)
+	}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if line := scanner.Text(); line != "" {
+			s.history = append(s.history, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("error when reading history file: %v", err)
+	}
+	s.historyFile = f
+	return f.Close, nil
+}
+
+// lineReader reads the input line by line and prints prompts in interactive mode.
+type lineReader struct {
+	scanner     *bufio.Scanner
+	out         io.Writer
+	interactive bool
+	// line is the number of the last line read.
+	line int
+}
+
+func (r *lineReader) next(prompt string) (string, bool) {
+	if r.interactive {
+		fmt.Fprint(r.out, prompt)
+	}
+	if !r.scanner.Scan() {
+		return "", false
+	}
+	r.line++
+	return r.scanner.Text(), true
+}
+
+// Run reads and executes commands until the end of the input or until the "quit" command. In
+// interactive mode, prompts are printed and errors are reported without ending the session.
+// Otherwise, the first error is returned, with the line at which it occurred. If a transaction is
+// still in progress at the end of the input, it is rolled back.
+func (s *Session) Run(in io.Reader, interactive bool) error {
+	err := s.run(in, interactive)
+	if s.inTxn {
+		if rbErr := s.rollback(); rbErr != nil && err == nil {
+			err = rbErr
+		}
+	}
+	return err
+}
+
+func (s *Session) run(in io.Reader, interactive bool) error {
+	r := &lineReader{scanner: bufio.NewScanner(in), out: s.out, interactive: interactive}
+	r.scanner.Buffer(nil, 1024*1024)
+	for {
+		line, ok := r.next(prompt)
+		if !ok {
+			if interactive {
+				fmt.Fprintln(s.out)
+			}
+			return r.scanner.Err()
+		}
+		startLine := r.line
+		err := s.execute(line, r)
+		if err == errQuit {
+			return nil
+		}
+		if err != nil {
+			if !interactive {
+				return fmt.Errorf("line %d: %v", startLine, err)
+			}
+			fmt.Fprintf(s.out, "Error: %v\n", err)
+		}
+	}
+}
+
+func isYAMLStart(line string) bool {
+	return line == "---" || strings.HasPrefix(line, "apiVersion:") || strings.HasPrefix(line, "kind:")
+}
+
+// execute executes a single command. Pasted YAML blocks are read from r.
+func (s *Session) execute(line string, r *lineReader) error {
+	trimmed := strings.TrimSpace(line)
+	if trimmed == "" || strings.HasPrefix(trimmed, "#") {
+		return nil
+	}
+	if isYAMLStart(trimmed) {
+		lines := []string{line}
+		for {
+			next, ok := r.next(continuationPrompt)
+			if !ok || strings.TrimSpace(next) == "" || strings.TrimSpace(next) == endOfBlock {
+				break
+			}
+			lines = append(lines, next)
+		}
+		// Pasted YAML is not recorded in the history: it can be long and it would make the
+		// history file impossible to read back line by line.
+		return s.apply(strings.NewReader(strings.Join(lines, "\n") + "\n"))
+	}
+	if strings.HasPrefix(trimmed, "!") {
+		expanded, err := s.expandHistory(trimmed)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintln(s.out, expanded)
+		trimmed = expanded
+	}
+	s.addHistory(trimmed)
+
+	fields := strings.Fields(trimmed)
+	switch cmd, args := fields[0], fields[1:]; cmd {
+	case "help":
+		fmt.Fprint(s.out, helpText)
+		return nil
+	case "quit", "exit":
+		return errQuit
+	case "start":
+		return s.start()
+	case "commit":
+		return s.commit()
+	case "rollback":
+		return s.rollback()
+	case "apply":
+		return s.applyFiles(args)
+	case "delete":
+		return s.delete(args)
+	case "print":
+		return s.print(args)
+	case "source":
+		return s.source(args)
+	case "history":
+		for i, h := range s.history {
+			fmt.Fprintf(s.out, "%5d  %s\n", i+1, h)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown command '%s', run 'help' for the list of commands", cmd)
+	}
+}
+
+func (s *Session) addHistory(line string) {
+	s.history = append(s.history, line)
+	if s.historyFile != nil {
+		fmt.Fprintln(s.historyFile, line)
+	}
+}
+
+func (s *Session) expandHistory(cmd string) (string, error) {
+	if len(s.history) == 0 {
+		return "", fmt.Errorf("history is empty")
+	}
+	if cmd == "!!" {
+		return s.history[len(s.history)-1], nil
+	}
+	n, err := strconv.Atoi(cmd[1:])
+	if err != nil || n < 1 || n > len(s.history) {
+		return "", fmt.Errorf("invalid history reference '%s'", cmd)
+	}
+	return s.history[n-1], nil
+}
+
+func (s *Session) start() error {
+	if s.inTxn {
+		return fmt.Errorf("transaction already in progress")
+	}
+	if err := s.program.StartTransaction(); err != nil {
+		return fmt.Errorf("error when starting transaction: %v", err)
+	}
+	s.inTxn = true
+	s.loaderState = s.loader.State()
+	return nil
+}
+
+func (s *Session) commit() error {
+	if !s.inTxn {
+		return fmt.Errorf("no transaction in progress")
+	}
+	if err := s.program.CommitTransaction(); err != nil {
+		// The transaction is over either way.
+		s.inTxn = false
+		s.pending = 0
+		s.loader.Restore(s.loaderState)
+		return fmt.Errorf("error when committing transaction: %v", err)
+	}
+	s.inTxn = false
+	fmt.Fprintf(s.out, "Committed %d update(s)\n", s.pending)
+	s.pending = 0
+	return nil
+}
+
+func (s *Session) rollback() error {
+	if !s.inTxn {
+		return fmt.Errorf("no transaction in progress")
+	}
+	s.inTxn = false
+	s.pending = 0
+	s.loader.Restore(s.loaderState)
+	if err := s.program.RollbackTransaction(); err != nil {
+		return fmt.Errorf("error when rolling back transaction: %v", err)
+	}
+	return nil
+}
+
+// update applies commands to the program. Outside of a transaction, the commands are applied in
+// their own transaction.
+func (s *Session) update(cmds []ddlog.Command) error {
+	if len(cmds) == 0 {
+		return nil
+	}
+	autoCommit := !s.inTxn
+	if autoCommit {
+		if err := s.start(); err != nil {
+			return err
+		}
+	}
+	if err := s.program.ApplyUpdates(cmds...); err != nil {
+		err = fmt.Errorf("error when applying updates: %v", err)
+		if autoCommit {
+			if rbErr := s.rollback(); rbErr != nil {
+				return fmt.Errorf("%v (and %v)", err, rbErr)
+			}
+		}
+		return err
+	}
+	s.pending += len(cmds)
+	if autoCommit {
+		return s.commit()
+	}
+	return nil
+}
+
+func (s *Session) applyFiles(fileNames []string) error {
+	if len(fileNames) == 0 {
+		return fmt.Errorf("usage: apply <file>...")
+	}
+	var readers []io.Reader
+	for _, fileName := range fileNames {
+		f, err := os.Open(fileName)
+		if err != nil {
+			return fmt.Errorf("error when opening manifest file: %v", err)
+		}
+		defer f.Close()
+		readers = append(readers, f)
+	}
+	return s.apply(readers...)
+}
+
+func (s *Session) apply(readers ...io.Reader) error {
+	state := s.loader.State()
+	objects, err := s.loader.Load(readers...)
+	if err != nil {
+		s.loader.Restore(state)
+		return err
+	}
+	var cmds []ddlog.Command
+	for _, ns := range objects.Namespaces {
+		cmds = append(cmds, ddlog.NewInsertOrUpdateCommand(ddlogk8s.NamespaceTableID, ddlogk8s.NewRecordNamespace(ns)))
+	}
+	for _, pod := range objects.Pods {
+		cmds = append(cmds, ddlog.NewInsertOrUpdateCommand(ddlogk8s.PodTableID, ddlogk8s.NewRecordPod(pod)))
+	}
+	for _, np := range objects.NetworkPolicies {
+		cmds = append(cmds, ddlog.NewInsertOrUpdateCommand(ddlogk8s.NetworkPolicyTableID, ddlogk8s.NewRecordNetworkPolicy(np)))
+	}
+	if len(cmds) == 0 {
+		return fmt.Errorf("no supported object found")
+	}
+	// The objects are unknown to the program if the updates fail, including when they are
+	// committed in their own transaction.
+	if err := s.update(cmds); err != nil {
+		s.loader.Restore(state)
+		return err
+	}
+	return nil
+}
+
+func splitKey(key string) (string, string, error) {
+	parts := strings.Split(key, "/")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", "", fmt.Errorf("invalid key '%s', must be <namespace>/<name>", key)
+	}
+	return parts[0], parts[1], nil
+}
+
+func (s *Session) delete(args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("usage: delete <pod|namespace|networkpolicy> <key>")
+	}
+	relation, ok := inputRelation(args[0])
+	if !ok {
+		return fmt.Errorf("unknown relation '%s'", args[0])
+	}
+	key := args[1]
+	var tableID ddlog.TableID
+	var keyRecord ddlog.Record
+	switch relation {
+	case namespaceRelation:
+		tableID, keyRecord = ddlogk8s.NamespaceTableID, ddlogk8s.NewRecordNamespaceKey(key)
+	case podRelation, networkPolicyRelation:
+		namespace, name, err := splitKey(key)
+		if err != nil {
+			return err
+		}
+		if relation == podRelation {
+			tableID, keyRecord = ddlogk8s.PodTableID, ddlogk8s.NewRecordPodKey(namespace, name)
+		} else {
+			tableID, keyRecord = ddlogk8s.NetworkPolicyTableID, ddlogk8s.NewRecordNetworkPolicyKey(namespace, name)
+		}
+	}
+	if err := s.update([]ddlog.Command{ddlog.NewDeleteKeyCommand(tableID, keyRecord)}); err != nil {
+		return err
+	}
+	if relation == namespaceRelation {
+		s.loader.DeleteNamespace(key)
+	}
+	return nil
+}
+
+func (s *Session) print(args []string) error {
+	if len(args) < 1 || len(args) > 2 || (args[0] != "inputs" && args[0] != "outputs") {
+		return fmt.Errorf("usage: print <inputs|outputs> [relation]")
+	}
+	filter := ""
+	if len(args) == 2 {
+		filter = args[1]
+	}
+	if args[0] == "inputs" {
+		return s.printInputs(filter)
+	}
+	return s.printOutputs(filter)
+}
+
+// dumpInputs returns the committed contents of the input relations, as the text representation of
+// the records by relation name. They are obtained from a snapshot dumped by the DDlog program to a
+// temporary file.
+func (s *Session) dumpInputs() (map[string][]string, error) {
+	f, err := ioutil.TempFile("", "ddlog-repl-inputs-")
+	if err != nil {
+		return nil, fmt.Errorf("error when creating snapshot file: %v", err)
+	}
+	f.Close()
+	defer os.Remove(f.Name())
+	if err := s.program.DumpInputSnapshot(f.Name()); err != nil {
+		return nil, fmt.Errorf("error when dumping input snapshot: %v", err)
+	}
+	txn, err := snapshot.ParseFile(f.Name())
+	if err != nil {
+		return nil, fmt.Errorf("error when parsing input snapshot: %v", err)
+	}
+	records := make(map[string][]string)
+	for _, cmd := range txn.Updates {
+		records[cmd.Relation] = append(records[cmd.Relation], ddlogtext.Format(cmd.Value))
+	}
+	return records, nil
+}
+
+func (s *Session) printInputs(filter string) error {
+	if s.inTxn && s.pending > 0 {
+		fmt.Fprintf(s.out, "(%d uncommitted update(s) not shown)\n", s.pending)
+	}
+	relation := ""
+	if filter != "" {
+		var ok bool
+		if relation, ok = inputRelation(filter); !ok {
+			return fmt.Errorf("unknown input relation '%s'", filter)
+		}
+	}
+	inputs, err := s.dumpInputs()
+	if err != nil {
+		return err
+	}
+	for _, r := range inputRelations {
+		if relation != "" && r.name != relation {
+			continue
+		}
+		records := inputs[r.name]
+		sort.Strings(records)
+		fmt.Fprintf(s.out, "%s:\n", r.name)
+		for _, record := range records {
+			fmt.Fprintf(s.out, "  %s\n", record)
+		}
+	}
+	return nil
+}
+
+func (s *Session) printOutputs(filter string) error {
+	relations, records := s.outputs.List()
+	found := false
+	for _, relation := range relations {
+		if filter != "" && !strings.EqualFold(relation, filter) {
+			continue
+		}
+		found = true
+		fmt.Fprintf(s.out, "%s:\n", relation)
+		for _, record := range records[relation] {
+			fmt.Fprintf(s.out, "  %s\n", record.Text)
+		}
+	}
+	if filter != "" && !found {
+		fmt.Fprintf(s.out, "%s is empty\n", filter)
+	}
+	return nil
+}
+
+func (s *Session) source(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: source <file>")
+	}
+	f, err := os.Open(args[0])
+	if err != nil {
+		return fmt.Errorf("error when opening script: %v", err)
+	}
+	defer f.Close()
+	if err := s.run(f, false); err != nil {
+		return fmt.Errorf("%s: %v", args[0], err)
+	}
+	return nil
+}
@@ -0,0 +1,223 @@
+// Copyright 2020 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package repl
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/antoninbas/antrea-k8s-to-ddlog/pkg/manifest"
+	"github.com/antoninbas/antrea-k8s-to-ddlog/pkg/outhandler"
+	"github.com/vmware/differential-datalog/go/pkg/ddlog"
+)
+
+// fakeProgram records the calls made by the session. DumpInputSnapshot writes inputs.
+type fakeProgram struct {
+	calls     []string
+	updates   int
+	inputs    string
+	commitErr error
+}
+
+func (p *fakeProgram) StartTransaction() error {
+	p.calls = append(p.calls, "start")
+	return nil
+}
+
+func (p *fakeProgram) ApplyUpdates(commands ...ddlog.Command) error {
+	p.calls = append(p.calls, "update")
+	p.updates += len(commands)
+	return nil
+}
+
+func (p *fakeProgram) CommitTransaction() error {
+	p.calls = append(p.calls, "commit")
+	return p.commitErr
+}
+
+func (p *fakeProgram) RollbackTransaction() error {
+	p.calls = append(p.calls, "rollback")
+	return nil
+}
+
+func (p *fakeProgram) DumpInputSnapshot(name string) error {
+	return ioutil.WriteFile(name, []byte(p.inputs), 0644)
+}
+
+func newTestSession(t *testing.T) (*Session, *fakeProgram, *bytes.Buffer) {
+	loader, err := manifest.NewLoader(manifest.Options{})
+	require.NoError(t, err)
+	program := &fakeProgram{}
+	var out bytes.Buffer
+	return NewSession(program, outhandler.NewCollector(), loader, &out), program, &out
+}
+
+const testScript = `# Pasted objects are committed immediately outside of a transaction.
+apiVersion: v1
+kind: Pod
+metadata:
+  name: pod1
+  namespace: ns1
+.
+start
+kind: Pod
+apiVersion: v1
+metadata:
+  name: pod2
+  namespace: ns1
+
+delete pod ns1/pod1
+commit
+start
+delete namespace ns1
+rollback
+history
+`
+
+func TestRun(t *testing.T) {
+	s, program, out := newTestSession(t)
+	require.NoError(t, s.Run(strings.NewReader(testScript), false))
+
+	assert.Equal(t, []string{
+		"start", "update", "commit",
+		"start", "update", "update", "commit",
+		"start", "update", "rollback",
+	}, program.calls)
+	// Namespace ns1 is created implicitly with pod1, and it is not created again with pod2.
+	assert.Equal(t, 5, program.updates)
+	assert.Contains(t, out.String(), "    6  rollback\n")
+	assert.Equal(t, []string{"start", "delete pod ns1/pod1", "commit", "start", "delete namespace ns1", "rollback", "history"}, s.history)
+}
+
+func TestLoaderStateRestored(t *testing.T) {
+	const pod = "apiVersion: v1\nkind: Pod\nmetadata:\n  name: pod1\n  namespace: ns1\n.\n"
+	for _, tc := range []struct {
+		name      string
+		script    string
+		commitErr bool
+		// updates is the number of updates sent by the script, before pod is applied again.
+		updates int
+	}{
+		{"rollback", "start\n" + pod + "rollback\n", false, 2},
+		{"failed auto-commit", pod, true, 2},
+		{"delete namespace", pod + "delete namespace ns1\n", false, 3},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s, program, _ := newTestSession(t)
+			if tc.commitErr {
+				program.commitErr = fmt.Errorf("injected failure")
+				require.Error(t, s.Run(strings.NewReader(tc.script), false))
+				program.commitErr = nil
+			} else {
+				require.NoError(t, s.Run(strings.NewReader(tc.script), false))
+			}
+			require.Equal(t, tc.updates, program.updates)
+			// Namespace ns1 does not exist anymore, it must be created again with pod1.
+			require.NoError(t, s.Run(strings.NewReader(pod), false))
+			assert.Equal(t, tc.updates+2, program.updates)
+		})
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name   string
+		script string
+		line   int
+	}{
+		{"unknown command", "start\nfoo\n", 2},
+		{"commit outside of transaction", "commit\n", 1},
+		{"nested transaction", "start\nstart\n", 2},
+		{"invalid key", "delete pod pod1\n", 1},
+		{"unknown relation", "delete service ns1/svc1\n", 1},
+		{"empty history", "!!\n", 1},
+		{"invalid manifest", "kind: Pod\n  - foo: [\n\n", 1},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			s, _, _ := newTestSession(t)
+			err := s.Run(strings.NewReader(tc.script), false)
+			require.Error(t, err)
+			assert.True(t, strings.HasPrefix(err.Error(), fmt.Sprintf("line %d:", tc.line)), err.Error())
+			// Transactions left open are rolled back.
+			assert.False(t, s.inTxn)
+		})
+	}
+}
+
+func TestInteractive(t *testing.T) {
+	s, program, out := newTestSession(t)
+	script := "foo\nstart\n!!\nrollback\nquit\nstart\n"
+	require.NoError(t, s.Run(strings.NewReader(script), true))
+	// Errors do not end an interactive session, commands after "quit" are not run.
+	assert.Equal(t, []string{"start", "rollback"}, program.calls)
+	assert.Contains(t, out.String(), "Error: unknown command 'foo'")
+	assert.Contains(t, out.String(), "Error: transaction already in progress")
+}
+
+func TestPrintInputs(t *testing.T) {
+	s, program, out := newTestSession(t)
+	program.inputs = "insert k8spolicy.Pod[k8spolicy.Pod{\"pod2\", \"ns1\"}],\n" +
+		"insert k8spolicy.Namespace[k8spolicy.Namespace{\"ns1\"}],\n" +
+		"insert k8spolicy.Pod[k8spolicy.Pod{\"pod1\", \"ns1\"}],\n"
+	require.NoError(t, s.Run(strings.NewReader("print inputs\nstart\ndelete pod ns1/pod1\nprint inputs pods\n"), false))
+
+	// The records are read from the snapshot dumped by the program, which only includes the
+	// committed updates.
+	assert.Equal(t, "k8spolicy.Namespace:\n"+
+		"  k8spolicy.Namespace{\"ns1\"}\n"+
+		"k8spolicy.Pod:\n"+
+		"  k8spolicy.Pod{\"pod1\", \"ns1\"}\n"+
+		"  k8spolicy.Pod{\"pod2\", \"ns1\"}\n"+
+		"k8spolicy.NetworkPolicy:\n"+
+		"(1 uncommitted update(s) not shown)\n"+
+		"k8spolicy.Pod:\n"+
+		"  k8spolicy.Pod{\"pod1\", \"ns1\"}\n"+
+		"  k8spolicy.Pod{\"pod2\", \"ns1\"}\n", out.String())
+}
+
+func TestSourceAndHistoryFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "repl")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	manifestFile := filepath.Join(dir, "ns.yaml")
+	require.NoError(t, ioutil.WriteFile(manifestFile, []byte("apiVersion: v1\nkind: Namespace\nmetadata:\n  name: ns2\n"), 0644))
+	scriptFile := filepath.Join(dir, "script")
+	require.NoError(t, ioutil.WriteFile(scriptFile, []byte("apply "+manifestFile+"\nprint inputs ns\n"), 0644))
+	historyFile := filepath.Join(dir, "history")
+	require.NoError(t, ioutil.WriteFile(historyFile, []byte("print outputs\n"), 0644))
+
+	s, program, out := newTestSession(t)
+	program.inputs = "insert k8spolicy.Namespace[k8spolicy.Namespace{\"ns2\"}],\n"
+	closeHistory, err := s.SetHistoryFile(historyFile)
+	require.NoError(t, err)
+	require.NoError(t, s.Run(strings.NewReader("source "+scriptFile+"\n!1\n"), false))
+	require.NoError(t, closeHistory())
+
+	assert.Equal(t, []string{"start", "update", "commit"}, program.calls)
+	assert.Contains(t, out.String(), "k8spolicy.Namespace:\n  k8spolicy.Namespace{\"ns2\"}\n")
+	b, err := ioutil.ReadFile(historyFile)
+	require.NoError(t, err)
+	expected := "print outputs\nsource " + scriptFile + "\napply " + manifestFile + "\nprint inputs ns\nprint outputs\n"
+	assert.Equal(t, expected, string(b))
+}
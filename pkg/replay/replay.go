@@ -0,0 +1,141 @@
+// Copyright 2020 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package replay reads the files written by ddlog.Program.StartRecordingCommands and re-applies the
+// recorded commands to a DDlog program, with the original transaction boundaries.
+package replay
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	"github.com/antoninbas/antrea-k8s-to-ddlog/pkg/ddlogtext"
+	"github.com/antoninbas/antrea-k8s-to-ddlog/pkg/ddlogtext/ddlogconv"
+	"github.com/vmware/differential-datalog/go/pkg/ddlog"
+)
+
+// Transaction is a recorded transaction: all the updates between "start;" and "commit;" (or
+// "rollback;").
+type Transaction struct {
+	// Line is the line of the "start;" command.
+	Line     int
+	Updates  []*ddlogtext.Command
+	Rollback bool
+}
+
+// ParseFile parses a recorded command file.
+func ParseFile(name string) ([]*Transaction, error) {
+	b, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, fmt.Errorf("error when reading file '%s': %v", name, err)
+	}
+	return Parse(string(b))
+}
+
+// Parse parses the contents of a recorded command file and groups the commands into transactions.
+func Parse(input string) ([]*Transaction, error) {
+	cmds, err := ddlogtext.ParseCommands(input)
+	if err != nil {
+		return nil, err
+	}
+	var transactions []*Transaction
+	var txn *Transaction
+	for _, cmd := range cmds {
+		switch cmd.Kind {
+		case ddlogtext.CommandStart:
+			if txn != nil {
+				return nil, fmt.Errorf("line %d: transaction already in progress", cmd.Line)
+			}
+			txn = &Transaction{Line: cmd.Line}
+		case ddlogtext.CommandCommit, ddlogtext.CommandRollback:
+			if txn == nil {
+				return nil, fmt.Errorf("line %d: no transaction in progress", cmd.Line)
+			}
+			txn.Rollback = cmd.Kind == ddlogtext.CommandRollback
+			transactions = append(transactions, txn)
+			txn = nil
+		default:
+			if txn == nil {
+				return nil, fmt.Errorf("line %d: update outside of a transaction", cmd.Line)
+			}
+			txn.Updates = append(txn.Updates, cmd)
+		}
+	}
+	if txn != nil {
+		return nil, fmt.Errorf("line %d: transaction is never committed", txn.Line)
+	}
+	return transactions, nil
+}
+
+// Program is the subset of the ddlog.Program methods used to replay transactions. It is
+// implemented by program.Program.
+type Program interface {
+	StartTransaction() error
+	ApplyUpdates(commands ...ddlog.Command) error
+	ClearRelation(tableID ddlog.TableID) error
+	CommitTransaction() error
+	RollbackTransaction() error
+}
+
+// Apply applies a single recorded transaction to the program.
+func (t *Transaction) Apply(p Program) error {
+	if err := p.StartTransaction(); err != nil {
+		return fmt.Errorf("error when starting transaction: %v", err)
+	}
+	abort := func(err error) error {
+		if rbErr := p.RollbackTransaction(); rbErr != nil {
+			return fmt.Errorf("%v (and rollback failed: %v)", err, rbErr)
+		}
+		return err
+	}
+	// Consecutive updates are applied together, clears are applied in between.
+	var cmds []ddlog.Command
+	flush := func() error {
+		if len(cmds) == 0 {
+			return nil
+		}
+		err := p.ApplyUpdates(cmds...)
+		cmds = nil
+		return err
+	}
+	for _, u := range t.Updates {
+		if u.Kind == ddlogtext.CommandClear {
+			if err := flush(); err != nil {
+				return abort(fmt.Errorf("error when applying updates: %v", err))
+			}
+			if err := p.ClearRelation(ddlog.GetTableID(u.Relation)); err != nil {
+				return abort(fmt.Errorf("line %d: error when clearing relation '%s': %v", u.Line, u.Relation, err))
+			}
+			continue
+		}
+		cmd, err := ddlogconv.ToCommand(u)
+		if err != nil {
+			return abort(err)
+		}
+		cmds = append(cmds, cmd)
+	}
+	if err := flush(); err != nil {
+		return abort(fmt.Errorf("error when applying updates: %v", err))
+	}
+	if t.Rollback {
+		if err := p.RollbackTransaction(); err != nil {
+			return fmt.Errorf("error when rolling back transaction: %v", err)
+		}
+		return nil
+	}
+	if err := p.CommitTransaction(); err != nil {
+		return fmt.Errorf("error when committing transaction: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+// Copyright 2020 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package replay
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/antoninbas/antrea-k8s-to-ddlog/pkg/ddlogtext"
+)
+
+const testCommands = `start;
+insert k8spolicy.Namespace[k8spolicy.Namespace{"ns1"}],
+insert_or_update k8spolicy.Pod[k8spolicy.Pod{"pod1", "ns1"}];
+commit dump_changes;
+echo ignored;
+start;
+delete_key k8spolicy.Pod ("ns1", "pod1"),
+clear k8spolicy.NetworkPolicy;
+rollback;
+`
+
+func TestParse(t *testing.T) {
+	transactions, err := Parse(testCommands)
+	require.NoError(t, err)
+	require.Len(t, transactions, 2)
+
+	txn := transactions[0]
+	assert.Equal(t, 1, txn.Line)
+	assert.False(t, txn.Rollback)
+	require.Len(t, txn.Updates, 2)
+	assert.Equal(t, ddlogtext.CommandInsert, txn.Updates[0].Kind)
+	assert.Equal(t, "k8spolicy.Namespace", txn.Updates[0].Relation)
+	assert.Equal(t, ddlogtext.CommandInsertOrUpdate, txn.Updates[1].Kind)
+
+	txn = transactions[1]
+	assert.Equal(t, 6, txn.Line)
+	assert.True(t, txn.Rollback)
+	require.Len(t, txn.Updates, 2)
+	assert.Equal(t, &ddlogtext.Command{
+		Line:     7,
+		Kind:     ddlogtext.CommandDeleteKey,
+		Relation: "k8spolicy.Pod",
+		Value:    ddlogtext.Tuple{ddlogtext.String("ns1"), ddlogtext.String("pod1")},
+	}, txn.Updates[0])
+	assert.Equal(t, &ddlogtext.Command{Line: 8, Kind: ddlogtext.CommandClear, Relation: "k8spolicy.NetworkPolicy"}, txn.Updates[1])
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		input string
+	}{
+		{"update outside of transaction", `insert R[1];`},
+		{"nested transaction", "start;\nstart;"},
+		{"commit outside of transaction", "commit;"},
+		{"missing commit", "start;\ninsert R[1];"},
+		{"syntax error", `start; insert R[S{1, 2]; commit;`},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := Parse(tc.input)
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,146 @@
+// Copyright 2020 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package scenario runs the DDlog program on scenarios, for regression testing. A scenario is a
+// directory with one or more YAML manifests (*.yml or *.yaml files) defining Pods, Deployments,
+// Namespaces and NetworkPolicies, and a golden file (ExpectedOutputsFile) with the expected
+// contents of the output relations, in canonical form (see Outputs).
+package scenario
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+
+	"k8s.io/klog"
+
+	"github.com/antoninbas/antrea-k8s-to-ddlog/pkg/ddlogk8s"
+	"github.com/antoninbas/antrea-k8s-to-ddlog/pkg/manifest"
+	"github.com/antoninbas/antrea-k8s-to-ddlog/pkg/outhandler"
+	"github.com/antoninbas/antrea-k8s-to-ddlog/pkg/program"
+)
+
+// ExpectedOutputsFile is the name of the golden file in a scenario directory.
+const ExpectedOutputsFile = "expected.json"
+
+// Outputs are the contents of the output relations in canonical form: for each non-empty relation,
+// the JSON encoding of its records (see ddlogk8s.RecordToOutput), sorted.
+type Outputs map[string][]json.RawMessage
+
+// Marshal returns the indented JSON encoding of the outputs, with the relations sorted by name, as
+// stored in golden files.
+func (o Outputs) Marshal() ([]byte, error) {
+	b, err := json.MarshalIndent(o, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append(b, '\n'), nil
+}
+
+// List returns the scenario directories under root, sorted.
+func List(root string) ([]string, error) {
+	entries, err := ioutil.ReadDir(root)
+	if err != nil {
+		return nil, fmt.Errorf("error when listing scenarios: %v", err)
+	}
+	var dirs []string
+	for _, entry := range entries {
+		if entry.IsDir() {
+			dirs = append(dirs, filepath.Join(root, entry.Name()))
+		}
+	}
+	return dirs, nil
+}
+
+func manifestFiles(dir string) ([]string, error) {
+	var fileNames []string
+	for _, pattern := range []string{"*.yml", "*.yaml"} {
+		matches, err := filepath.Glob(filepath.Join(dir, pattern))
+		if err != nil {
+			return nil, err
+		}
+		fileNames = append(fileNames, matches...)
+	}
+	if len(fileNames) == 0 {
+		return nil, fmt.Errorf("no manifest file in scenario '%s'", dir)
+	}
+	sort.Strings(fileNames)
+	return fileNames, nil
+}
+
+// Run loads the manifests of the scenario in dir, applies them to a new DDlog program in a single
+// transaction, and returns the resulting outputs.
+func Run(dir string) (Outputs, error) {
+	fileNames, err := manifestFiles(dir)
+	if err != nil {
+		return nil, err
+	}
+	objects, err := manifest.LoadFiles(fileNames, manifest.Options{})
+	if err != nil {
+		return nil, err
+	}
+
+	collector := outhandler.NewCollector()
+	ddlogProgram, err := program.NewProgram(1, collector)
+	if err != nil {
+		return nil, fmt.Errorf("error when creating DDLog program: %v", err)
+	}
+	defer func() {
+		if err := ddlogProgram.Stop(); err != nil {
+			klog.Errorf("Error when stopping DDLog program: %v", err)
+		}
+	}()
+	cmds := ddlogk8s.NewInsertOrUpdateCommands(objects.Namespaces, objects.Pods, objects.NetworkPolicies)
+	if err := ddlogProgram.ApplyUpdatesAsTransaction(cmds...); err != nil {
+		return nil, fmt.Errorf("error when applying updates: %v", err)
+	}
+
+	relations, records := collector.List()
+	outputs := make(Outputs, len(relations))
+	for _, relation := range relations {
+		values := make([]json.RawMessage, 0, len(records[relation]))
+		for _, record := range records[relation] {
+			b, err := json.Marshal(record.Value)
+			if err != nil {
+				return nil, fmt.Errorf("error when encoding record '%s': %v", record.Text, err)
+			}
+			values = append(values, b)
+		}
+		sort.Slice(values, func(i, j int) bool {
+			return bytes.Compare(values[i], values[j]) < 0
+		})
+		outputs[relation] = values
+	}
+	return outputs, nil
+}
+
+// ReadExpected returns the contents of the golden file of the scenario in dir.
+func ReadExpected(dir string) ([]byte, error) {
+	return ioutil.ReadFile(filepath.Join(dir, ExpectedOutputsFile))
+}
+
+// WriteExpected replaces the golden file of the scenario in dir with outputs.
+func WriteExpected(dir string, outputs Outputs) error {
+	b, err := outputs.Marshal()
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, ExpectedOutputsFile), b, 0644); err != nil {
+		return fmt.Errorf("error when writing expected outputs: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+// Copyright 2020 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scenario
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var update = flag.Bool("update", false, "Regenerate the expected outputs of the scenarios in testdata")
+
+// TestScenarios runs each scenario in testdata and compares the outputs with the golden file. Run
+// 'go test ./pkg/scenario -update' (or 'make update-scenarios') to regenerate the golden files
+// after an intended change of the outputs, and review the diff.
+func TestScenarios(t *testing.T) {
+	dirs, err := List("testdata")
+	require.NoError(t, err)
+	require.NotEmpty(t, dirs)
+	for _, dir := range dirs {
+		dir := dir
+		t.Run(filepath.Base(dir), func(t *testing.T) {
+			outputs, err := Run(dir)
+			require.NoError(t, err)
+			actual, err := outputs.Marshal()
+			require.NoError(t, err)
+			if *update {
+				require.NoError(t, WriteExpected(dir, outputs))
+				return
+			}
+			expected, err := ReadExpected(dir)
+			if os.IsNotExist(err) {
+				t.Fatalf("No %s for scenario, run with -update to generate it", ExpectedOutputsFile)
+			}
+			require.NoError(t, err)
+			assert.Equal(t, string(expected), string(actual), "Outputs differ from %s, run with -update to regenerate it if the change is intended", filepath.Join(dir, ExpectedOutputsFile))
+		})
+	}
+}
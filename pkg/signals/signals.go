@@ -17,6 +17,7 @@ package signals
 import (
 	"os"
 	"os/signal"
+	"sync"
 	"syscall"
 
 	"k8s.io/klog"
@@ -45,3 +46,51 @@ func RegisterSignalHandlers() <-chan struct{} {
 
 	return stopCh
 }
+
+// Dispatcher calls the handlers registered by components for a given signal, every time the signal
+// is received. Handlers are called sequentially from a single goroutine, and signals received while
+// a handler is running may be coalesced.
+type Dispatcher struct {
+	mutex    sync.Mutex
+	handlers map[os.Signal][]func()
+	notifyCh chan os.Signal
+}
+
+// NewDispatcher returns a new Dispatcher. Signals are only dispatched once Run has been called.
+func NewDispatcher() *Dispatcher {
+	return &Dispatcher{
+		handlers: make(map[os.Signal][]func()),
+		notifyCh: make(chan os.Signal, 4),
+	}
+}
+
+// Handle registers handler for sig. Several handlers can be registered for the same signal, in
+// which case they are called in the order in which they were registered.
+func (d *Dispatcher) Handle(sig os.Signal, handler func()) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.handlers[sig] = append(d.handlers[sig], handler)
+	signal.Notify(d.notifyCh, sig)
+}
+
+func (d *Dispatcher) getHandlers(sig os.Signal) []func() {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	return d.handlers[sig]
+}
+
+// Run dispatches the signals until stopCh is closed.
+func (d *Dispatcher) Run(stopCh <-chan struct{}) {
+	defer signal.Stop(d.notifyCh)
+	for {
+		select {
+		case sig := <-d.notifyCh:
+			klog.Infof("Received signal %v", sig)
+			for _, handler := range d.getHandlers(sig) {
+				handler()
+			}
+		case <-stopCh:
+			return
+		}
+	}
+}
@@ -0,0 +1,44 @@
+// Copyright 2020 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package signals
+
+import (
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+func TestDispatcher(t *testing.T) {
+	var calls1, calls2, calls3 int32
+	d := NewDispatcher()
+	d.Handle(syscall.SIGUSR2, func() { atomic.AddInt32(&calls1, 1) })
+	d.Handle(syscall.SIGUSR2, func() { atomic.AddInt32(&calls2, 1) })
+	d.Handle(syscall.SIGHUP, func() { atomic.AddInt32(&calls3, 1) })
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	go d.Run(stopCh)
+
+	require.NoError(t, syscall.Kill(syscall.Getpid(), syscall.SIGUSR2))
+	err := wait.PollImmediate(10*time.Millisecond, 5*time.Second, func() (bool, error) {
+		return atomic.LoadInt32(&calls1) == 1 && atomic.LoadInt32(&calls2) == 1, nil
+	})
+	require.NoError(t, err, "Timeout when waiting for the SIGUSR2 handlers to be called")
+	assert.Equal(t, int32(0), atomic.LoadInt32(&calls3))
+}
@@ -0,0 +1,146 @@
+// Copyright 2020 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package snapshot writes snapshots of the DDlog input relations on demand and restores them into a
+// fresh DDlog program.
+package snapshot
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"k8s.io/klog"
+
+	"github.com/antoninbas/antrea-k8s-to-ddlog/pkg/ddlogtext"
+	"github.com/antoninbas/antrea-k8s-to-ddlog/pkg/replay"
+)
+
+const (
+	filePrefix = "ddlog-input-snapshot-"
+	fileSuffix = ".txt"
+	// timeFormat is used for the file names. It sorts lexicographically and does not include
+	// any character which is invalid in file names.
+	timeFormat = "20060102T150405.000000000Z"
+)
+
+// Program is the subset of the program.Program methods used to take snapshots.
+type Program interface {
+	DumpInputSnapshot(name string) error
+}
+
+// Snapshotter writes timestamped snapshots of the input relations of a DDlog program to a
+// directory.
+type Snapshotter struct {
+	program Program
+	dir     string
+	now     func() time.Time
+}
+
+// NewSnapshotter creates a new Snapshotter which writes snapshots of program to dir. The directory
+// is created when the first snapshot is taken if it does not exist.
+func NewSnapshotter(program Program, dir string) *Snapshotter {
+	return &Snapshotter{
+		program: program,
+		dir:     dir,
+		now:     time.Now,
+	}
+}
+
+// Take writes a new snapshot and returns the path of the snapshot file.
+func (s *Snapshotter) Take() (string, error) {
+	if err := os.MkdirAll(s.dir, 0755); err != nil {
+		return "", fmt.Errorf("error when creating snapshot directory '%s': %v", s.dir, err)
+	}
+	name := filepath.Join(s.dir, filePrefix+s.now().UTC().Format(timeFormat)+fileSuffix)
+	if err := s.program.DumpInputSnapshot(name); err != nil {
+		return "", fmt.Errorf("error when dumping input snapshot: %v", err)
+	}
+	klog.Infof("Wrote DDlog input snapshot to %s", name)
+	return name, nil
+}
+
+// snapshotResponse is the body of a successful response to a snapshot request.
+type snapshotResponse struct {
+	Path string `json:"path"`
+}
+
+// ServeHTTP takes a snapshot for each POST request and returns the path of the snapshot file.
+func (s *Snapshotter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "only POST is supported", http.StatusMethodNotAllowed)
+		return
+	}
+	name, err := s.Take()
+	if err != nil {
+		klog.Errorf("Error when taking snapshot: %v", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(snapshotResponse{Path: name}); err != nil {
+		klog.Errorf("Error when writing snapshot response: %v", err)
+	}
+}
+
+// ParseFile parses a snapshot file.
+func ParseFile(name string) (*replay.Transaction, error) {
+	b, err := ioutil.ReadFile(name)
+	if err != nil {
+		return nil, fmt.Errorf("error when reading file '%s': %v", name, err)
+	}
+	return Parse(string(b))
+}
+
+// Parse parses the contents of a snapshot and returns a single transaction which inserts all the
+// records. DDlog writes snapshots as a chain of insert commands separated by ',', with a trailing
+// ',' instead of a terminating ';'. Files which include "start;" and "commit;", e.g. because they
+// were edited by hand, are accepted as well.
+func Parse(input string) (*replay.Transaction, error) {
+	input = strings.TrimSpace(input)
+	if strings.HasSuffix(input, ",") {
+		input = strings.TrimSuffix(input, ",") + ";"
+	}
+	cmds, err := ddlogtext.ParseCommands(input)
+	if err != nil {
+		return nil, err
+	}
+	txn := &replay.Transaction{Line: 1}
+	for _, cmd := range cmds {
+		switch {
+		case cmd.Kind == ddlogtext.CommandStart || cmd.Kind == ddlogtext.CommandCommit:
+		case cmd.Kind.IsUpdate():
+			txn.Updates = append(txn.Updates, cmd)
+		default:
+			return nil, fmt.Errorf("line %d: unexpected command '%s' in snapshot", cmd.Line, cmd.Kind)
+		}
+	}
+	return txn, nil
+}
+
+// Restore loads a snapshot file into a program, in a single transaction. The program is expected
+// to be fresh: records from the snapshot are added to any existing contents.
+func Restore(p replay.Program, name string) error {
+	txn, err := ParseFile(name)
+	if err != nil {
+		return err
+	}
+	return txn.Apply(p)
+}
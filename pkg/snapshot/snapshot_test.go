@@ -0,0 +1,120 @@
+// Copyright 2020 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package snapshot
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/antoninbas/antrea-k8s-to-ddlog/pkg/ddlogtext"
+)
+
+// fakeProgram writes the provided contents when asked for a snapshot.
+type fakeProgram struct {
+	contents string
+	err      error
+}
+
+func (p *fakeProgram) DumpInputSnapshot(name string) error {
+	if p.err != nil {
+		return p.err
+	}
+	return ioutil.WriteFile(name, []byte(p.contents), 0644)
+}
+
+// newTestSnapshotter returns a Snapshotter writing to a new temporary directory, and a function
+// to remove the directory.
+func newTestSnapshotter(t *testing.T, p Program) (*Snapshotter, func()) {
+	dir, err := ioutil.TempDir("", "snapshot")
+	require.NoError(t, err)
+	s := NewSnapshotter(p, filepath.Join(dir, "snapshots"))
+	s.now = func() time.Time { return time.Date(2020, 4, 1, 10, 30, 0, 5, time.UTC) }
+	return s, func() { os.RemoveAll(dir) }
+}
+
+func TestTake(t *testing.T) {
+	p := &fakeProgram{contents: "insert R[1],\n"}
+	s, cleanup := newTestSnapshotter(t, p)
+	defer cleanup()
+	name, err := s.Take()
+	require.NoError(t, err)
+	assert.Equal(t, filepath.Join(s.dir, "ddlog-input-snapshot-20200401T103000.000000005Z.txt"), name)
+	b, err := ioutil.ReadFile(name)
+	require.NoError(t, err)
+	assert.Equal(t, p.contents, string(b))
+
+	p.err = fmt.Errorf("dump failed")
+	_, err = s.Take()
+	assert.Error(t, err)
+}
+
+func TestServeHTTP(t *testing.T) {
+	p := &fakeProgram{}
+	s, cleanup := newTestSnapshotter(t, p)
+	defer cleanup()
+
+	rr := httptest.NewRecorder()
+	s.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/snapshot", nil))
+	assert.Equal(t, http.StatusMethodNotAllowed, rr.Code)
+
+	rr = httptest.NewRecorder()
+	s.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/snapshot", nil))
+	require.Equal(t, http.StatusOK, rr.Code)
+	var response snapshotResponse
+	require.NoError(t, json.Unmarshal(rr.Body.Bytes(), &response))
+	assert.FileExists(t, response.Path)
+
+	p.err = fmt.Errorf("dump failed")
+	rr = httptest.NewRecorder()
+	s.ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/snapshot", nil))
+	assert.Equal(t, http.StatusInternalServerError, rr.Code)
+}
+
+func TestParse(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		input string
+	}{
+		{"ddlog format", "insert k8spolicy.Namespace[k8spolicy.Namespace{\"ns1\"}],\ninsert k8spolicy.Pod[k8spolicy.Pod{\"pod1\", \"ns1\"}],\n"},
+		{"with transaction", "start;\ninsert k8spolicy.Namespace[k8spolicy.Namespace{\"ns1\"}],\ninsert k8spolicy.Pod[k8spolicy.Pod{\"pod1\", \"ns1\"}];\ncommit;\n"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			txn, err := Parse(tc.input)
+			require.NoError(t, err)
+			assert.False(t, txn.Rollback)
+			require.Len(t, txn.Updates, 2)
+			assert.Equal(t, ddlogtext.CommandInsert, txn.Updates[0].Kind)
+			assert.Equal(t, "k8spolicy.Namespace", txn.Updates[0].Relation)
+			assert.Equal(t, "k8spolicy.Pod", txn.Updates[1].Relation)
+		})
+	}
+
+	txn, err := Parse("")
+	require.NoError(t, err)
+	assert.Empty(t, txn.Updates)
+
+	_, err = Parse("insert R[1];\nrollback;")
+	assert.Error(t, err)
+}
@@ -0,0 +1,268 @@
+// Copyright 2020 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package subscription lets Go code subscribe to the changes to the DDlog output relations, using
+// typed callbacks which are invoked once per committed transaction.
+package subscription
+
+import (
+	"sync"
+
+	"k8s.io/klog"
+
+	"github.com/antoninbas/antrea-k8s-to-ddlog/pkg/ddlogk8s"
+	"github.com/vmware/differential-datalog/go/pkg/ddlog"
+)
+
+const (
+	// DefaultBufferSize is the default number of committed transactions which can be buffered
+	// for a subscriber before it is considered too slow.
+	DefaultBufferSize = 256
+)
+
+// ChangeType indicates whether an output record was added or deleted. DDlog has no notion of
+// modified output records: a modification is reported as a deletion followed by an addition, or
+// the other way around.
+type ChangeType string
+
+const (
+	ChangeAdd    ChangeType = "add"
+	ChangeDelete ChangeType = "delete"
+)
+
+// Handlers is the set of callbacks for a subscriber. All callbacks are optional. For a given
+// subscriber, callbacks are invoked sequentially from a dedicated goroutine: first the callbacks
+// for each change in the transaction, in the order in which DDlog reported them, then
+// OnTransactionEnd. Objects passed to the callbacks are shared between subscribers and must not be
+// modified.
+type Handlers struct {
+	OnAppliedToGroup           func(txnID uint64, change ChangeType, group *ddlogk8s.AppliedToGroup)
+	OnAppliedToGroupPodsByNode func(txnID uint64, change ChangeType, pods *ddlogk8s.AppliedToGroupPodsByNode)
+	OnAppliedToGroupSpan       func(txnID uint64, change ChangeType, span *ddlogk8s.AppliedToGroupSpan)
+	OnAddressGroup             func(txnID uint64, change ChangeType, group *ddlogk8s.AddressGroup)
+	OnAddressGroupAddress      func(txnID uint64, change ChangeType, address *ddlogk8s.AddressGroupAddress)
+	OnAddressGroupSpan         func(txnID uint64, change ChangeType, span *ddlogk8s.AddressGroupSpan)
+	OnNetworkPolicy            func(txnID uint64, change ChangeType, policy *ddlogk8s.InternalNetworkPolicy)
+	OnNetworkPolicySpan        func(txnID uint64, change ChangeType, span *ddlogk8s.NetworkPolicySpan)
+	// OnTransactionEnd is called after all the changes for a transaction have been delivered.
+	OnTransactionEnd func(txnID uint64)
+	// OnOverflow is called if the subscriber could not keep up with DDlog and its buffer
+	// overflowed. It is the last callback to be invoked: the subscription is terminated and
+	// the subscriber needs to resynchronize, e.g. with a view.View.
+	OnOverflow func()
+}
+
+type change struct {
+	changeType ChangeType
+	obj        interface{}
+}
+
+type transaction struct {
+	id      uint64
+	changes []change
+}
+
+// Subscription represents a registered subscriber.
+type Subscription struct {
+	broker   *Broker
+	handlers *Handlers
+	txnCh    chan *transaction
+	// closed and unsubscribed are protected by the broker mutex.
+	closed       bool
+	unsubscribed bool
+	doneCh       chan struct{}
+}
+
+// Broker implements the ddlog.OutRecordHandler and outhandler.CommitObserver interfaces. It decodes
+// all the output changes for a transaction and, once the transaction has been committed
+// successfully, hands them over to each subscriber. Changes for a failed commit are discarded.
+type Broker struct {
+	// changes accumulates the changes for the transaction being committed. It is only accessed
+	// from the DDlog commit path, but Handle may be called concurrently from several DDlog
+	// worker threads.
+	changesMutex sync.Mutex
+	changes      []change
+
+	mutex         sync.Mutex
+	subscriptions map[*Subscription]bool
+}
+
+// NewBroker creates a Broker with no subscribers.
+func NewBroker() *Broker {
+	return &Broker{
+		subscriptions: make(map[*Subscription]bool),
+	}
+}
+
+// Handle decodes the record received from DDlog and adds it to the current transaction.
+func (b *Broker) Handle(tableID ddlog.TableID, r ddlog.Record, outPolarity ddlog.OutPolarity) {
+	obj, err := ddlogk8s.RecordToOutput(tableID, r)
+	if err != nil {
+		klog.Errorf("Error when decoding record for table '%s': %v", ddlog.GetTableName(tableID), err)
+		return
+	}
+	changeType := ChangeAdd
+	if outPolarity == ddlog.OutPolarityDelete {
+		changeType = ChangeDelete
+	}
+	b.addChange(changeType, obj)
+}
+
+func (b *Broker) addChange(changeType ChangeType, obj interface{}) {
+	b.changesMutex.Lock()
+	defer b.changesMutex.Unlock()
+	b.changes = append(b.changes, change{changeType: changeType, obj: obj})
+}
+
+// CommitStarted resets the list of changes for the new transaction.
+func (b *Broker) CommitStarted(txnID uint64) {
+	b.changesMutex.Lock()
+	defer b.changesMutex.Unlock()
+	b.changes = nil
+}
+
+// CommitEnded delivers the changes for the transaction to all the subscribers, unless the commit
+// failed. It never blocks: subscribers whose buffer is full are terminated.
+func (b *Broker) CommitEnded(txnID uint64, err error) {
+	b.changesMutex.Lock()
+	changes := b.changes
+	b.changes = nil
+	b.changesMutex.Unlock()
+
+	if err != nil {
+		klog.Errorf("Dropping output changes for transaction %d because commit failed: %v", txnID, err)
+		return
+	}
+	txn := &transaction{id: txnID, changes: changes}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	for s := range b.subscriptions {
+		select {
+		case s.txnCh <- txn:
+		default:
+			klog.Warningf("Subscriber buffer is full, terminating subscription")
+			b.closeLocked(s)
+		}
+	}
+}
+
+// Subscribe registers a new subscriber, which will receive all the changes for transactions
+// committed from now on. bufferSize is the maximum number of transactions which can be buffered
+// for the subscriber; if it is 0, DefaultBufferSize is used.
+func (b *Broker) Subscribe(handlers *Handlers, bufferSize int) *Subscription {
+	if bufferSize <= 0 {
+		bufferSize = DefaultBufferSize
+	}
+	s := &Subscription{
+		broker:   b,
+		handlers: handlers,
+		txnCh:    make(chan *transaction, bufferSize),
+		doneCh:   make(chan struct{}),
+	}
+	b.mutex.Lock()
+	b.subscriptions[s] = true
+	b.mutex.Unlock()
+	go s.run()
+	return s
+}
+
+// closeLocked must be called with the broker mutex held.
+func (b *Broker) closeLocked(s *Subscription) {
+	if s.closed {
+		return
+	}
+	s.closed = true
+	delete(b.subscriptions, s)
+	close(s.txnCh)
+}
+
+// Unsubscribe terminates the subscription. Transactions which were already buffered are still
+// delivered. It is safe to call Unsubscribe several times, including from a callback.
+func (s *Subscription) Unsubscribe() {
+	s.broker.mutex.Lock()
+	defer s.broker.mutex.Unlock()
+	if !s.closed {
+		s.unsubscribed = true
+	}
+	s.broker.closeLocked(s)
+}
+
+// Done returns a channel which is closed once the last callback for the subscription has returned.
+func (s *Subscription) Done() <-chan struct{} {
+	return s.doneCh
+}
+
+func (s *Subscription) run() {
+	defer close(s.doneCh)
+	for txn := range s.txnCh {
+		for _, c := range txn.changes {
+			s.dispatch(txn.id, c)
+		}
+		if s.handlers.OnTransactionEnd != nil {
+			s.handlers.OnTransactionEnd(txn.id)
+		}
+	}
+	// The channel is closed either because of Unsubscribe or because of an overflow. In the
+	// latter case, the subscription was not explicitly terminated by the subscriber.
+	if s.overflowed() && s.handlers.OnOverflow != nil {
+		s.handlers.OnOverflow()
+	}
+}
+
+// overflowed returns true if the subscription was terminated by the broker rather than by
+// Unsubscribe.
+func (s *Subscription) overflowed() bool {
+	s.broker.mutex.Lock()
+	defer s.broker.mutex.Unlock()
+	return !s.unsubscribed
+}
+
+func (s *Subscription) dispatch(txnID uint64, c change) {
+	h := s.handlers
+	switch o := c.obj.(type) {
+	case *ddlogk8s.AppliedToGroup:
+		if h.OnAppliedToGroup != nil {
+			h.OnAppliedToGroup(txnID, c.changeType, o)
+		}
+	case *ddlogk8s.AppliedToGroupPodsByNode:
+		if h.OnAppliedToGroupPodsByNode != nil {
+			h.OnAppliedToGroupPodsByNode(txnID, c.changeType, o)
+		}
+	case *ddlogk8s.AppliedToGroupSpan:
+		if h.OnAppliedToGroupSpan != nil {
+			h.OnAppliedToGroupSpan(txnID, c.changeType, o)
+		}
+	case *ddlogk8s.AddressGroup:
+		if h.OnAddressGroup != nil {
+			h.OnAddressGroup(txnID, c.changeType, o)
+		}
+	case *ddlogk8s.AddressGroupAddress:
+		if h.OnAddressGroupAddress != nil {
+			h.OnAddressGroupAddress(txnID, c.changeType, o)
+		}
+	case *ddlogk8s.AddressGroupSpan:
+		if h.OnAddressGroupSpan != nil {
+			h.OnAddressGroupSpan(txnID, c.changeType, o)
+		}
+	case *ddlogk8s.InternalNetworkPolicy:
+		if h.OnNetworkPolicy != nil {
+			h.OnNetworkPolicy(txnID, c.changeType, o)
+		}
+	case *ddlogk8s.NetworkPolicySpan:
+		if h.OnNetworkPolicySpan != nil {
+			h.OnNetworkPolicySpan(txnID, c.changeType, o)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+// Copyright 2020 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package subscription
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/antoninbas/antrea-k8s-to-ddlog/pkg/ddlogk8s"
+)
+
+func commit(b *Broker, txnID uint64, err error, changes ...change) {
+	b.CommitStarted(txnID)
+	for _, c := range changes {
+		b.addChange(c.changeType, c.obj)
+	}
+	b.CommitEnded(txnID, err)
+}
+
+func waitDone(t *testing.T, s *Subscription) {
+	select {
+	case <-s.Done():
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "timeout when waiting for subscription to terminate")
+	}
+}
+
+func TestSubscriptionOrder(t *testing.T) {
+	b := NewBroker()
+	var events []string
+	s := b.Subscribe(&Handlers{
+		OnAppliedToGroup: func(txnID uint64, change ChangeType, group *ddlogk8s.AppliedToGroup) {
+			events = append(events, fmt.Sprintf("%d %s AppliedToGroup %s", txnID, change, group.Name))
+		},
+		OnAddressGroupAddress: func(txnID uint64, change ChangeType, address *ddlogk8s.AddressGroupAddress) {
+			events = append(events, fmt.Sprintf("%d %s AddressGroupAddress %s", txnID, change, address.Address))
+		},
+		OnTransactionEnd: func(txnID uint64) {
+			events = append(events, fmt.Sprintf("%d end", txnID))
+		},
+	}, 0)
+
+	commit(b, 1, nil,
+		change{ChangeAdd, &ddlogk8s.AppliedToGroup{Name: "group1"}},
+		change{ChangeAdd, &ddlogk8s.AddressGroupAddress{AddressGroup: "group2", Address: "10.0.0.1"}},
+	)
+	// failed commit: changes are dropped
+	commit(b, 2, fmt.Errorf("commit error"),
+		change{ChangeAdd, &ddlogk8s.AppliedToGroup{Name: "group3"}},
+	)
+	// no typed callback for this change
+	commit(b, 3, nil,
+		change{ChangeAdd, &ddlogk8s.AddressGroup{Name: "group2"}},
+		change{ChangeDelete, &ddlogk8s.AppliedToGroup{Name: "group1"}},
+	)
+	s.Unsubscribe()
+	waitDone(t, s)
+
+	assert.Equal(t, []string{
+		"1 add AppliedToGroup group1",
+		"1 add AddressGroupAddress 10.0.0.1",
+		"1 end",
+		"3 delete AppliedToGroup group1",
+		"3 end",
+	}, events)
+}
+
+func TestSubscriptionOverflow(t *testing.T) {
+	b := NewBroker()
+	blockCh := make(chan struct{})
+	overflowCh := make(chan struct{})
+	var fastTxns []uint64
+	slow := b.Subscribe(&Handlers{
+		OnTransactionEnd: func(txnID uint64) { <-blockCh },
+		OnOverflow:       func() { close(overflowCh) },
+	}, 1)
+	fast := b.Subscribe(&Handlers{
+		OnTransactionEnd: func(txnID uint64) { fastTxns = append(fastTxns, txnID) },
+	}, 10)
+
+	// The slow subscriber blocks on the first transaction, buffers the second one and
+	// overflows on the third one. This must not block the commit path.
+	for i := uint64(1); i <= 3; i++ {
+		commit(b, i, nil)
+	}
+	close(blockCh)
+	waitDone(t, slow)
+	select {
+	case <-overflowCh:
+	default:
+		assert.Fail(t, "OnOverflow was not called")
+	}
+
+	fast.Unsubscribe()
+	waitDone(t, fast)
+	assert.Equal(t, []uint64{1, 2, 3}, fastTxns)
+}
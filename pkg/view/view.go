@@ -0,0 +1,439 @@
+// Copyright 2020 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package view provides an in-memory materialized view of the DDlog output relations, which can be
+// queried concurrently with DDlog updates.
+package view
+
+import (
+	"reflect"
+	"sort"
+	"sync"
+
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/sets"
+	"k8s.io/klog"
+
+	"github.com/antoninbas/antrea-k8s-to-ddlog/pkg/ddlogk8s"
+	"github.com/vmware/differential-datalog/go/pkg/ddlog"
+)
+
+// AppliedToGroup is the current state of an AppliedToGroup, assembled from the AppliedToGroup,
+// AppliedToGroupPodsByNode and AppliedToGroupSpan output relations.
+type AppliedToGroup struct {
+	Name string `json:"name"`
+	// PodsByNode maps Node names to the Pods in the group which are scheduled on that Node.
+	PodsByNode map[string][]ddlogk8s.PodReference `json:"podsByNode"`
+	// Span is the sorted list of Nodes which need to know about this group.
+	Span []string `json:"span"`
+}
+
+// AddressGroup is the current state of an AddressGroup, assembled from the AddressGroup,
+// AddressGroupAddress and AddressGroupSpan output relations.
+type AddressGroup struct {
+	Name      string   `json:"name"`
+	Addresses []string `json:"addresses"`
+	Span      []string `json:"span"`
+}
+
+// NetworkPolicy is the current state of an internal NetworkPolicy, assembled from the NetworkPolicy
+// and NetworkPolicySpan output relations.
+type NetworkPolicy struct {
+	ddlogk8s.InternalNetworkPolicy
+	Span []string `json:"span"`
+}
+
+// DDlog reports changes for each relation independently, and within a transaction there is no
+// ordering guarantee between relations. For example, the AppliedToGroupPodsByNode records for a
+// group may be received before the AppliedToGroup record. This is why each state below keeps track
+// of whether the "main" record has been received, and why a state is only removed when it is
+// empty.
+
+type appliedToGroupState struct {
+	exists     bool
+	podsByNode map[string]*ddlogk8s.AppliedToGroupPodsByNode
+	span       sets.String
+}
+
+func (s *appliedToGroupState) empty() bool {
+	return !s.exists && len(s.podsByNode) == 0 && len(s.span) == 0
+}
+
+type addressGroupState struct {
+	exists    bool
+	addresses sets.String
+	span      sets.String
+}
+
+func (s *addressGroupState) empty() bool {
+	return !s.exists && len(s.addresses) == 0 && len(s.span) == 0
+}
+
+type networkPolicyState struct {
+	policy *ddlogk8s.InternalNetworkPolicy
+	span   sets.String
+}
+
+func (s *networkPolicyState) empty() bool {
+	return s.policy == nil && len(s.span) == 0
+}
+
+// Kind identifies the type of an object in the view.
+type Kind string
+
+const (
+	KindAppliedToGroup Kind = "AppliedToGroup"
+	KindAddressGroup   Kind = "AddressGroup"
+	KindNetworkPolicy  Kind = "NetworkPolicy"
+)
+
+// EventHandler is called every time an object in the view may have changed. name is the object
+// name for groups and the UID for NetworkPolicies. EventHandlers are called synchronously from the
+// DDlog commit path and must not block.
+type EventHandler func(kind Kind, name string)
+
+// View implements the ddlog.OutRecordHandler interface: it maintains the current contents of all
+// the DDlog output relations, decoded into Go types. All the query methods are thread-safe and
+// return copies which can be freely modified by the caller. Note that queries are not isolated from
+// in-progress DDlog transactions: a query may observe part of the changes of a transaction which is
+// being committed.
+type View struct {
+	mutex           sync.RWMutex
+	appliedToGroups map[string]*appliedToGroupState
+	addressGroups   map[string]*addressGroupState
+	networkPolicies map[types.UID]*networkPolicyState
+
+	handlersMutex sync.RWMutex
+	handlers      map[int]EventHandler
+	nextHandlerID int
+}
+
+// NewView creates an empty View.
+func NewView() *View {
+	return &View{
+		appliedToGroups: make(map[string]*appliedToGroupState),
+		addressGroups:   make(map[string]*addressGroupState),
+		networkPolicies: make(map[types.UID]*networkPolicyState),
+		handlers:        make(map[int]EventHandler),
+	}
+}
+
+// AddEventHandler registers a handler which will be notified of changes to the view. It returns a
+// function which can be called to unregister the handler.
+func (v *View) AddEventHandler(handler EventHandler) func() {
+	v.handlersMutex.Lock()
+	defer v.handlersMutex.Unlock()
+	id := v.nextHandlerID
+	v.nextHandlerID++
+	v.handlers[id] = handler
+	return func() {
+		v.handlersMutex.Lock()
+		defer v.handlersMutex.Unlock()
+		delete(v.handlers, id)
+	}
+}
+
+func (v *View) notify(kind Kind, name string) {
+	v.handlersMutex.RLock()
+	defer v.handlersMutex.RUnlock()
+	for _, handler := range v.handlers {
+		handler(kind, name)
+	}
+}
+
+// Handle decodes the record received from DDlog and updates the view accordingly. Records which
+// cannot be decoded are logged and ignored.
+func (v *View) Handle(tableID ddlog.TableID, r ddlog.Record, outPolarity ddlog.OutPolarity) {
+	obj, err := ddlogk8s.RecordToOutput(tableID, r)
+	if err != nil {
+		klog.Errorf("Error when decoding record for table '%s': %v", ddlog.GetTableName(tableID), err)
+		return
+	}
+	v.HandleOutput(obj, outPolarity == ddlog.OutPolarityInsert)
+}
+
+func (v *View) getAppliedToGroup(name string) *appliedToGroupState {
+	s, ok := v.appliedToGroups[name]
+	if !ok {
+		s = &appliedToGroupState{
+			podsByNode: make(map[string]*ddlogk8s.AppliedToGroupPodsByNode),
+			span:       sets.NewString(),
+		}
+		v.appliedToGroups[name] = s
+	}
+	return s
+}
+
+func (v *View) getAddressGroup(name string) *addressGroupState {
+	s, ok := v.addressGroups[name]
+	if !ok {
+		s = &addressGroupState{
+			addresses: sets.NewString(),
+			span:      sets.NewString(),
+		}
+		v.addressGroups[name] = s
+	}
+	return s
+}
+
+func (v *View) getNetworkPolicy(uid types.UID) *networkPolicyState {
+	s, ok := v.networkPolicies[uid]
+	if !ok {
+		s = &networkPolicyState{
+			span: sets.NewString(),
+		}
+		v.networkPolicies[uid] = s
+	}
+	return s
+}
+
+// HandleOutput applies a decoded output record, as returned by ddlogk8s.RecordToOutput, to the view
+// and notifies the event handlers. insert is false if the record is being deleted. The handlers
+// are called after the view lock has been released, so that they can query the view.
+func (v *View) HandleOutput(obj interface{}, insert bool) {
+	kind, name, ok := v.applyOutput(obj, insert)
+	if ok {
+		v.notify(kind, name)
+	}
+}
+
+// applyOutput applies a decoded output record to the view and returns the kind and name of the
+// affected object. For relations in which a subset of the columns acts as a key (e.g. the group
+// and Node names for AppliedToGroupPodsByNode), a deletion is only applied if the deleted record
+// matches the stored one, as the insertion of the new record may have been received first.
+func (v *View) applyOutput(obj interface{}, insert bool) (Kind, string, bool) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	switch o := obj.(type) {
+	case *ddlogk8s.AppliedToGroup:
+		s := v.getAppliedToGroup(o.Name)
+		s.exists = insert
+		if s.empty() {
+			delete(v.appliedToGroups, o.Name)
+		}
+		return KindAppliedToGroup, o.Name, true
+	case *ddlogk8s.AppliedToGroupPodsByNode:
+		s := v.getAppliedToGroup(o.AppliedToGroup)
+		if insert {
+			s.podsByNode[o.NodeName] = o
+		} else if reflect.DeepEqual(s.podsByNode[o.NodeName], o) {
+			delete(s.podsByNode, o.NodeName)
+		}
+		if s.empty() {
+			delete(v.appliedToGroups, o.AppliedToGroup)
+		}
+		return KindAppliedToGroup, o.AppliedToGroup, true
+	case *ddlogk8s.AppliedToGroupSpan:
+		s := v.getAppliedToGroup(o.AppliedToGroup)
+		updateSet(s.span, o.NodeName, insert)
+		if s.empty() {
+			delete(v.appliedToGroups, o.AppliedToGroup)
+		}
+		return KindAppliedToGroup, o.AppliedToGroup, true
+	case *ddlogk8s.AddressGroup:
+		s := v.getAddressGroup(o.Name)
+		s.exists = insert
+		if s.empty() {
+			delete(v.addressGroups, o.Name)
+		}
+		return KindAddressGroup, o.Name, true
+	case *ddlogk8s.AddressGroupAddress:
+		s := v.getAddressGroup(o.AddressGroup)
+		updateSet(s.addresses, o.Address, insert)
+		if s.empty() {
+			delete(v.addressGroups, o.AddressGroup)
+		}
+		return KindAddressGroup, o.AddressGroup, true
+	case *ddlogk8s.AddressGroupSpan:
+		s := v.getAddressGroup(o.AddressGroup)
+		updateSet(s.span, o.NodeName, insert)
+		if s.empty() {
+			delete(v.addressGroups, o.AddressGroup)
+		}
+		return KindAddressGroup, o.AddressGroup, true
+	case *ddlogk8s.InternalNetworkPolicy:
+		s := v.getNetworkPolicy(o.UID)
+		if insert {
+			s.policy = o
+		} else if reflect.DeepEqual(s.policy, o) {
+			s.policy = nil
+		}
+		if s.empty() {
+			delete(v.networkPolicies, o.UID)
+		}
+		return KindNetworkPolicy, string(o.UID), true
+	case *ddlogk8s.NetworkPolicySpan:
+		s := v.getNetworkPolicy(o.NetworkPolicy)
+		updateSet(s.span, o.NodeName, insert)
+		if s.empty() {
+			delete(v.networkPolicies, o.NetworkPolicy)
+		}
+		return KindNetworkPolicy, string(o.NetworkPolicy), true
+	default:
+		klog.Errorf("Unexpected output object type %T", obj)
+	}
+	return "", "", false
+}
+
+func updateSet(s sets.String, item string, insert bool) {
+	if insert {
+		s.Insert(item)
+	} else {
+		s.Delete(item)
+	}
+}
+
+func (s *appliedToGroupState) toAppliedToGroup(name string) *AppliedToGroup {
+	group := &AppliedToGroup{
+		Name:       name,
+		PodsByNode: make(map[string][]ddlogk8s.PodReference, len(s.podsByNode)),
+		Span:       s.span.List(),
+	}
+	for nodeName, podsByNode := range s.podsByNode {
+		pods := make([]ddlogk8s.PodReference, len(podsByNode.Pods))
+		copy(pods, podsByNode.Pods)
+		sort.Slice(pods, func(i, j int) bool {
+			if pods[i].Namespace != pods[j].Namespace {
+				return pods[i].Namespace < pods[j].Namespace
+			}
+			return pods[i].Name < pods[j].Name
+		})
+		group.PodsByNode[nodeName] = pods
+	}
+	return group
+}
+
+func (s *addressGroupState) toAddressGroup(name string) *AddressGroup {
+	return &AddressGroup{
+		Name:      name,
+		Addresses: s.addresses.List(),
+		Span:      s.span.List(),
+	}
+}
+
+func (s *networkPolicyState) toNetworkPolicy() *NetworkPolicy {
+	// The decoded policy is never modified once stored, so a shallow copy is enough as long
+	// as we copy the slices which callers are likely to modify.
+	policy := *s.policy
+	policy.Rules = append([]ddlogk8s.InternalNetworkPolicyRule(nil), s.policy.Rules...)
+	policy.AppliedToGroups = append([]string(nil), s.policy.AppliedToGroups...)
+	return &NetworkPolicy{
+		InternalNetworkPolicy: policy,
+		Span:                  s.span.List(),
+	}
+}
+
+// GetAppliedToGroup returns the AppliedToGroup with the provided name, if it exists.
+func (v *View) GetAppliedToGroup(name string) (*AppliedToGroup, bool) {
+	v.mutex.RLock()
+	defer v.mutex.RUnlock()
+	s, ok := v.appliedToGroups[name]
+	if !ok || !s.exists {
+		return nil, false
+	}
+	return s.toAppliedToGroup(name), true
+}
+
+// ListAppliedToGroups returns all the AppliedToGroups, sorted by name.
+func (v *View) ListAppliedToGroups() []*AppliedToGroup {
+	v.mutex.RLock()
+	defer v.mutex.RUnlock()
+	groups := make([]*AppliedToGroup, 0, len(v.appliedToGroups))
+	for name, s := range v.appliedToGroups {
+		if s.exists {
+			groups = append(groups, s.toAppliedToGroup(name))
+		}
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].Name < groups[j].Name })
+	return groups
+}
+
+// GetAddressGroup returns the AddressGroup with the provided name, if it exists.
+func (v *View) GetAddressGroup(name string) (*AddressGroup, bool) {
+	v.mutex.RLock()
+	defer v.mutex.RUnlock()
+	s, ok := v.addressGroups[name]
+	if !ok || !s.exists {
+		return nil, false
+	}
+	return s.toAddressGroup(name), true
+}
+
+// ListAddressGroupAddresses returns the sorted list of addresses in the AddressGroup with the
+// provided name. The second return value is false if the group does not exist.
+func (v *View) ListAddressGroupAddresses(name string) ([]string, bool) {
+	v.mutex.RLock()
+	defer v.mutex.RUnlock()
+	s, ok := v.addressGroups[name]
+	if !ok || !s.exists {
+		return nil, false
+	}
+	return s.addresses.List(), true
+}
+
+// ListAddressGroups returns all the AddressGroups, sorted by name.
+func (v *View) ListAddressGroups() []*AddressGroup {
+	v.mutex.RLock()
+	defer v.mutex.RUnlock()
+	groups := make([]*AddressGroup, 0, len(v.addressGroups))
+	for name, s := range v.addressGroups {
+		if s.exists {
+			groups = append(groups, s.toAddressGroup(name))
+		}
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].Name < groups[j].Name })
+	return groups
+}
+
+// GetNetworkPolicy returns the internal NetworkPolicy computed for the K8s NetworkPolicy with the
+// provided UID, if it exists.
+func (v *View) GetNetworkPolicy(uid types.UID) (*NetworkPolicy, bool) {
+	v.mutex.RLock()
+	defer v.mutex.RUnlock()
+	s, ok := v.networkPolicies[uid]
+	if !ok || s.policy == nil {
+		return nil, false
+	}
+	return s.toNetworkPolicy(), true
+}
+
+func (v *View) listNetworkPolicies(filter func(s *networkPolicyState) bool) []*NetworkPolicy {
+	v.mutex.RLock()
+	defer v.mutex.RUnlock()
+	policies := make([]*NetworkPolicy, 0)
+	for _, s := range v.networkPolicies {
+		if s.policy != nil && filter(s) {
+			policies = append(policies, s.toNetworkPolicy())
+		}
+	}
+	sort.Slice(policies, func(i, j int) bool {
+		if policies[i].Namespace != policies[j].Namespace {
+			return policies[i].Namespace < policies[j].Namespace
+		}
+		return policies[i].Name < policies[j].Name
+	})
+	return policies
+}
+
+// ListNetworkPolicies returns all the internal NetworkPolicies, sorted by Namespace and name.
+func (v *View) ListNetworkPolicies() []*NetworkPolicy {
+	return v.listNetworkPolicies(func(s *networkPolicyState) bool { return true })
+}
+
+// ListNetworkPoliciesByNode returns the internal NetworkPolicies which span the provided Node,
+// sorted by Namespace and name.
+func (v *View) ListNetworkPoliciesByNode(nodeName string) []*NetworkPolicy {
+	return v.listNetworkPolicies(func(s *networkPolicyState) bool { return s.span.Has(nodeName) })
+}
@@ -0,0 +1,109 @@
+// Copyright 2020 Antrea Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package view
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/antoninbas/antrea-k8s-to-ddlog/pkg/ddlogk8s"
+)
+
+func TestAppliedToGroup(t *testing.T) {
+	v := NewView()
+	pods1 := &ddlogk8s.AppliedToGroupPodsByNode{
+		AppliedToGroup: "group1",
+		NodeName:       "node-1",
+		Pods:           []ddlogk8s.PodReference{{Name: "pod2", Namespace: "ns1"}, {Name: "pod1", Namespace: "ns1"}},
+	}
+	// Membership records can be received before the group record.
+	v.HandleOutput(pods1, true)
+	_, ok := v.GetAppliedToGroup("group1")
+	assert.False(t, ok)
+
+	v.HandleOutput(&ddlogk8s.AppliedToGroup{Name: "group1"}, true)
+	v.HandleOutput(&ddlogk8s.AppliedToGroupSpan{AppliedToGroup: "group1", NodeName: "node-1"}, true)
+	group, ok := v.GetAppliedToGroup("group1")
+	require.True(t, ok)
+	assert.Equal(t, &AppliedToGroup{
+		Name: "group1",
+		PodsByNode: map[string][]ddlogk8s.PodReference{
+			"node-1": {{Name: "pod1", Namespace: "ns1"}, {Name: "pod2", Namespace: "ns1"}},
+		},
+		Span: []string{"node-1"},
+	}, group)
+
+	// The insertion of the new membership record is received before the deletion of the old
+	// one: the deletion must be ignored.
+	pods2 := &ddlogk8s.AppliedToGroupPodsByNode{
+		AppliedToGroup: "group1",
+		NodeName:       "node-1",
+		Pods:           []ddlogk8s.PodReference{{Name: "pod1", Namespace: "ns1"}},
+	}
+	v.HandleOutput(pods2, true)
+	v.HandleOutput(pods1, false)
+	group, ok = v.GetAppliedToGroup("group1")
+	require.True(t, ok)
+	assert.Equal(t, []ddlogk8s.PodReference{{Name: "pod1", Namespace: "ns1"}}, group.PodsByNode["node-1"])
+
+	v.HandleOutput(pods2, false)
+	v.HandleOutput(&ddlogk8s.AppliedToGroupSpan{AppliedToGroup: "group1", NodeName: "node-1"}, false)
+	v.HandleOutput(&ddlogk8s.AppliedToGroup{Name: "group1"}, false)
+	assert.Empty(t, v.ListAppliedToGroups())
+	assert.Empty(t, v.appliedToGroups)
+}
+
+func TestAddressGroup(t *testing.T) {
+	v := NewView()
+	v.HandleOutput(&ddlogk8s.AddressGroup{Name: "group1"}, true)
+	v.HandleOutput(&ddlogk8s.AddressGroupAddress{AddressGroup: "group1", Address: "10.0.0.2"}, true)
+	v.HandleOutput(&ddlogk8s.AddressGroupAddress{AddressGroup: "group1", Address: "10.0.0.1"}, true)
+	addresses, ok := v.ListAddressGroupAddresses("group1")
+	require.True(t, ok)
+	assert.Equal(t, []string{"10.0.0.1", "10.0.0.2"}, addresses)
+
+	v.HandleOutput(&ddlogk8s.AddressGroupAddress{AddressGroup: "group1", Address: "10.0.0.2"}, false)
+	addresses, ok = v.ListAddressGroupAddresses("group1")
+	require.True(t, ok)
+	assert.Equal(t, []string{"10.0.0.1"}, addresses)
+
+	_, ok = v.ListAddressGroupAddresses("group2")
+	assert.False(t, ok)
+}
+
+func TestNetworkPoliciesByNode(t *testing.T) {
+	v := NewView()
+	np1 := &ddlogk8s.InternalNetworkPolicy{UID: "uid1", Name: "np1", Namespace: "ns1"}
+	np2 := &ddlogk8s.InternalNetworkPolicy{UID: "uid2", Name: "np2", Namespace: "ns1"}
+	v.HandleOutput(np1, true)
+	v.HandleOutput(np2, true)
+	v.HandleOutput(&ddlogk8s.NetworkPolicySpan{NetworkPolicy: "uid1", NodeName: "node-1"}, true)
+	v.HandleOutput(&ddlogk8s.NetworkPolicySpan{NetworkPolicy: "uid2", NodeName: "node-1"}, true)
+	v.HandleOutput(&ddlogk8s.NetworkPolicySpan{NetworkPolicy: "uid2", NodeName: "node-2"}, true)
+
+	policies := v.ListNetworkPoliciesByNode("node-1")
+	require.Len(t, policies, 2)
+	assert.Equal(t, "np1", policies[0].Name)
+	assert.Equal(t, "np2", policies[1].Name)
+
+	policies = v.ListNetworkPoliciesByNode("node-2")
+	require.Len(t, policies, 1)
+	assert.Equal(t, "np2", policies[0].Name)
+	assert.Equal(t, []string{"node-1", "node-2"}, policies[0].Span)
+
+	assert.Empty(t, v.ListNetworkPoliciesByNode("node-3"))
+}